@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/scheduler"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+// newScheduler registers the background jobs, each is enabled and scheduled by its JOB_<NAME>_*
+// config
+func newScheduler() (*scheduler.Scheduler, error) {
+	log := logger.Get()
+	jobs := scheduler.New()
+
+	cleanupUC := usecase.NewCleanupUsecase(repository.NewAuthRepository(), repository.NewExpiredRowRepository())
+	err := jobs.Register("purge_refresh_tokens", func(ctx context.Context) error {
+		deleted, err := cleanupUC.PurgeExpiredRefreshTokens(ctx, config.GetUint("JOB_PURGE_REFRESH_TOKENS_BATCH_SIZE"))
+		log.Infof("purged expired refresh tokens | deleted=%d", deleted)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range repository.ExpiringTables {
+		err = jobs.Register("purge_"+table, func(ctx context.Context) error {
+			batchSize := config.GetUint("JOB_PURGE_" + strings.ToUpper(table) + "_BATCH_SIZE")
+			deleted, err := cleanupUC.PurgeExpired(ctx, table, batchSize)
+			log.Infof("purged expired rows | table=%s | deleted=%d", table, deleted)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	auditUC := usecase.NewAuditUsecase(repository.NewAuditRepository())
+	err = jobs.Register("audit_checkpoint", func(ctx context.Context) error {
+		checkpoint, err := auditUC.Checkpoint()
+		if checkpoint != nil {
+			log.Infof("signed audit checkpoint | last_event_id=%d", checkpoint.LastEventId)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
@@ -1,27 +1,121 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth"
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/router.go"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/scheduler"
 	"github.com/fazriegi/go-boilerplate/internal/middleware"
 	"github.com/fazriegi/go-boilerplate/internal/pkg"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+const (
+	// how long requests and jobs in flight may take to finish on shutdown
+	shutdownTimeout = 30 * time.Second
+	// how long the second step of a login may take when JWT_MFA_EXP_MINUTE is not set
+	defaultMFATokenExp = 5 * time.Minute
 )
 
 func main() {
 	config.NewViper()
 	database.NewMysql()
-	jwt := pkg.InitJWT(config.GetString("JWT_SECRET"), config.GetUint("JWT_ACCESS_EXP_MINUTE"), config.GetUint("JWT_REFRESH_EXP_DAY"))
+
+	// tokens are signed with JWT_SECRET (HS256) unless a keyring of asymmetric keys is configured
+	keyring := pkg.NewHMACKeyring(config.GetString("JWT_SECRET"))
+	if keyringFile := config.GetString("JWT_KEYRING_FILE"); keyringFile != "" {
+		var err error
+		gracePeriod := time.Duration(config.GetUint("JWT_KEY_GRACE_PERIOD_HOUR")) * time.Hour
+		if keyring, err = pkg.LoadKeyring(keyringFile, gracePeriod); err != nil {
+			log.Fatal("failed to load jwt keyring:", err)
+		}
+	}
+
+	mfaTokenExp := time.Duration(config.GetUint("JWT_MFA_EXP_MINUTE")) * time.Minute
+	if mfaTokenExp == 0 {
+		mfaTokenExp = defaultMFATokenExp
+	}
+
+	jwt, err := pkg.InitJWT(pkg.JWTConfig{
+		Keyring:         keyring,
+		Issuer:          config.GetString("JWT_ISSUER"),
+		Audience:        strings.Split(config.GetString("JWT_AUDIENCE"), ","),
+		Leeway:          time.Duration(config.GetUint("JWT_LEEWAY_SECOND")) * time.Second,
+		AccessTokenExp:  time.Duration(config.GetUint("JWT_ACCESS_EXP_MINUTE")) * time.Minute,
+		RefreshTokenExp: time.Duration(config.GetUint("JWT_REFRESH_EXP_DAY")) * 24 * time.Hour,
+		MFATokenExp:     mfaTokenExp,
+	})
+	if err != nil {
+		log.Fatal("failed to init jwt:", err)
+	}
+
+	// new passwords are hashed with PASSWORD_HASH_ALGORITHM, stored hashes of the other
+	// supported formats still verify and are replaced on the next login
+	passwordHasher, err := pkg.NewPasswordHasher(pkg.PasswordHashConfig{
+		Algorithm:         config.GetString("PASSWORD_HASH_ALGORITHM"),
+		Argon2Memory:      uint32(config.GetUint("PASSWORD_ARGON2_MEMORY_KIB")),
+		Argon2Iterations:  uint32(config.GetUint("PASSWORD_ARGON2_ITERATIONS")),
+		Argon2Parallelism: uint8(config.GetUint("PASSWORD_ARGON2_PARALLELISM")),
+		BcryptCost:        config.GetInt("PASSWORD_BCRYPT_COST"),
+	})
+	if err != nil {
+		log.Fatal("failed to init password hasher:", err)
+	}
+	pkg.SetPasswordHasher(passwordHasher)
+
+	breached, err := pkg.LoadBreachedPasswords(config.GetString("PASSWORD_BREACHED_FORMAT"), config.GetString("PASSWORD_BREACHED_FILE"))
+	if err != nil {
+		log.Fatal("failed to load breached passwords:", err)
+	}
+
+	// PASSWORD_MAX_LENGTH counts characters, a multibyte password within it can still be
+	// longer than bcrypt takes
+	var passwordMaxBytes int
+	if _, ok := passwordHasher.(pkg.BcryptHasher); ok {
+		passwordMaxBytes = pkg.BcryptMaxPasswordBytes
+	}
+
+	passwordPolicy, err := pkg.NewPasswordPolicy(pkg.PasswordPolicyConfig{
+		MinLength:        config.GetInt("PASSWORD_MIN_LENGTH"),
+		MaxLength:        config.GetInt("PASSWORD_MAX_LENGTH"),
+		MaxBytes:         passwordMaxBytes,
+		RequireLowercase: config.GetBool("PASSWORD_REQUIRE_LOWERCASE"),
+		RequireUppercase: config.GetBool("PASSWORD_REQUIRE_UPPERCASE"),
+		RequireDigit:     config.GetBool("PASSWORD_REQUIRE_DIGIT"),
+		RequireSymbol:    config.GetBool("PASSWORD_REQUIRE_SYMBOL"),
+		DisallowIdentity: config.GetBool("PASSWORD_DISALLOW_IDENTITY"),
+		Breached:         breached,
+	})
+	if err != nil {
+		log.Fatal("failed to init password policy:", err)
+	}
+
+	oauthProviders, err := oauth.NewProviders()
+	if err != nil {
+		log.Fatal("failed to init oauth providers:", err)
+	}
+
 	file := logger.New()
 	defer file.Close()
 
+	jobs, err := newScheduler()
+	if err != nil {
+		log.Fatal("failed to init jobs:", err)
+	}
+
 	app := fiber.New()
 
 	origins := config.GetString("CORS_ORIGINS")
@@ -34,14 +128,52 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     origins,
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, " + middleware.CSRFHeaderName,
+		ExposeHeaders:    fiber.HeaderRetryAfter + ", " + fiber.HeaderXRequestID + ", RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset",
 		AllowCredentials: true,
 		MaxAge:           int(maxAge),
 	}))
 
+	// the request ID is returned in X-Request-ID and recorded with audit events
+	app.Use(requestid.New())
 	app.Use(middleware.LogMiddleware())
+	app.Use(middleware.CSRF(middleware.CSRFConfig{
+		Exempt:         []string{"/api/v1/register", "/api/v1/login", "/api/v1/login/mfa", "/api/v1/login/mfa/resend", "/api/v1/login/magic-link", "/api/v1/login/magic-link/verify"},
+		AllowedOrigins: strings.Split(origins, ","),
+	}))
 	port := config.GetInt("PORT")
-	router.NewRoute(app, jwt)
+	// emails that must not hold up their response, reset and sign in links, are sent by
+	// background tasks
+	background := scheduler.NewBackground()
+	router.NewRoute(app, jwt, passwordPolicy, oauthProviders, background)
+
+	jobs.Start()
+
+	go func() {
+		if err := app.Listen(fmt.Sprintf(":%d", port)); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// stop on SIGINT or SIGTERM, letting requests and jobs in flight finish
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		log.Print("failed to shut down server:", err)
+	}
+
+	if err := jobs.Stop(ctx); err != nil {
+		log.Print("failed to stop jobs:", err)
+	}
+
+	if err := background.Wait(ctx); err != nil {
+		log.Print("failed to finish background tasks:", err)
+	}
 
-	log.Fatal(app.Listen(fmt.Sprintf(":%d", port)))
+	log.Print("server stopped")
 }
@@ -0,0 +1,75 @@
+// breached-filter builds the bloom filter file of PASSWORD_BREACHED_FORMAT=bloom from a SHA-1
+// hash list such as the Have I Been Pwned download:
+//
+//	go run ./cmd/breached-filter -in pwned-passwords-sha1.txt -out breached.bloom -p 0.001
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+)
+
+func main() {
+	in := flag.String("in", "", "SHA-1 hash list, one HASH or HASH:COUNT per line")
+	out := flag.String("out", "breached.bloom", "bloom filter file to write")
+	p := flag.Float64("p", 0.001, "false positive rate")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	n, err := countLines(*in)
+	if err != nil {
+		log.Fatal("failed to read hash list:", err)
+	}
+
+	list, err := os.Open(*in)
+	if err != nil {
+		log.Fatal("failed to open hash list:", err)
+	}
+	defer list.Close()
+
+	filter, err := pkg.BuildBreachedBloomFilter(bufio.NewReader(list), max(n, 1), *p)
+	if err != nil {
+		log.Fatal("failed to build bloom filter:", err)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatal("failed to create bloom filter file:", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	size, err := filter.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		log.Fatal("failed to write bloom filter:", err)
+	}
+
+	log.Printf("wrote %d hashes to %s (%d bytes)", n, *out, size)
+}
+
+func countLines(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var n uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		n++
+	}
+
+	return n, scanner.Err()
+}
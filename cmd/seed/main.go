@@ -0,0 +1,42 @@
+// seed creates the default permissions and the admin role holding all of them, and assigns
+// the admin role to an existing user to bootstrap the first administrator:
+//
+//	go run ./cmd/seed -admin alice
+//
+// it is safe to run again, e.g. after a release adding permissions
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func main() {
+	admin := flag.String("admin", "", "username of the user to assign the admin role")
+	flag.Parse()
+
+	config.NewViper()
+	database.NewMysql()
+
+	file := logger.New()
+	defer file.Close()
+
+	roleUC := usecase.NewRoleUsecase(repository.NewUserRepository(), repository.NewRoleRepository(), repository.NewAuthRepository(), store.NewRevocationStore())
+	if err := roleUC.Seed(*admin); err != nil {
+		log.Fatal("failed to seed roles:", err)
+	}
+
+	if *admin != "" {
+		log.Printf("seeded roles and assigned the admin role to %s", *admin)
+		return
+	}
+
+	log.Print("seeded roles")
+}
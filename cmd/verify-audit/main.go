@@ -0,0 +1,76 @@
+// verify-audit walks the hash chain of the audit log and reports the first broken link, it
+// exits with status 1 when the chain does not verify:
+//
+//	go run ./cmd/verify-audit -checkpoint -export audit-checkpoints.jsonl
+//
+// the exported checkpoints are kept outside the database, so a rewrite of the whole chain
+// can be told apart from the copies handed to the auditors
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func main() {
+	checkpoint := flag.Bool("checkpoint", false, "sign a checkpoint of the current head of the chain before verifying")
+	export := flag.String("export", "", "file to write the signed checkpoints to as JSON lines")
+	flag.Parse()
+
+	config.NewViper()
+	database.NewMysql()
+
+	file := logger.New()
+	defer file.Close()
+
+	auditUC := usecase.NewAuditUsecase(repository.NewAuditRepository())
+
+	if *checkpoint {
+		result, err := auditUC.Checkpoint()
+		if err != nil {
+			log.Fatal("failed to sign checkpoint:", err)
+		}
+		if result != nil {
+			log.Printf("signed a checkpoint at event %d", result.LastEventId)
+		}
+	}
+
+	report, err := auditUC.VerifyChain()
+	if err != nil {
+		log.Fatal("failed to verify audit chain:", err)
+	}
+
+	if *export != "" {
+		out, err := os.Create(*export)
+		if err != nil {
+			log.Fatal("failed to create export file:", err)
+		}
+
+		if err := auditUC.ExportCheckpoints(out); err != nil {
+			out.Close()
+			log.Fatal("failed to export checkpoints:", err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatal("failed to write export file:", err)
+		}
+
+		log.Printf("exported checkpoints to %s", *export)
+	}
+
+	switch {
+	case report.BrokenEventId != nil:
+		log.Fatalf("audit chain is broken at event %d: %s", *report.BrokenEventId, report.Reason)
+	case report.BrokenCheckpointId != nil:
+		log.Fatalf("audit chain is broken at checkpoint %d: %s", *report.BrokenCheckpointId, report.Reason)
+	}
+
+	log.Printf("audit chain is valid, %d events and %d checkpoints verified, %d events predate the chain",
+		report.CheckedEvents, report.CheckedCheckpoints, report.UnchainedEvents)
+}
@@ -0,0 +1,7 @@
+DROP INDEX idx_refresh_tokens_token ON refresh_tokens;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN device_label,
+    DROP COLUMN user_agent,
+    DROP COLUMN ip_address,
+    DROP COLUMN last_used_at;
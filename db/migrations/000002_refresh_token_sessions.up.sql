@@ -0,0 +1,7 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN device_label VARCHAR(100) NOT NULL DEFAULT '' AFTER token,
+    ADD COLUMN user_agent VARCHAR(255) NOT NULL DEFAULT '' AFTER device_label,
+    ADD COLUMN ip_address VARCHAR(45) NOT NULL DEFAULT '' AFTER user_agent,
+    ADD COLUMN last_used_at TIMESTAMP NULL AFTER created_at;
+
+CREATE INDEX idx_refresh_tokens_token ON refresh_tokens(token);
@@ -0,0 +1,7 @@
+DROP INDEX idx_refresh_tokens_family_id ON refresh_tokens;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN family_id,
+    DROP COLUMN parent_id,
+    DROP COLUMN consumed_at,
+    DROP COLUMN revoked_at;
@@ -0,0 +1,10 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id VARCHAR(64) NOT NULL DEFAULT '' AFTER token,
+    ADD COLUMN parent_id INT NULL AFTER family_id,
+    ADD COLUMN consumed_at TIMESTAMP NULL AFTER last_used_at,
+    ADD COLUMN revoked_at TIMESTAMP NULL AFTER consumed_at;
+
+-- every session issued before rotation becomes its own family
+UPDATE refresh_tokens SET family_id = CONCAT('legacy-', id) WHERE family_id = '';
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
@@ -0,0 +1,5 @@
+DROP TABLE revoked_access_tokens;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN access_token_id,
+    DROP COLUMN access_expired_at;
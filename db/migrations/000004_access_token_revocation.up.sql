@@ -0,0 +1,11 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN access_token_id VARCHAR(64) NOT NULL DEFAULT '' AFTER parent_id,
+    ADD COLUMN access_expired_at TIMESTAMP NULL AFTER access_token_id;
+
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    expired_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_revoked_access_tokens_expired_at ON revoked_access_tokens(expired_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    attempt_key VARCHAR(255) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NULL,
+    expired_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_expired_at ON login_attempts(expired_at);
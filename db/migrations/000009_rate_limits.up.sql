@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS rate_limits (
+    limit_key VARCHAR(255) PRIMARY KEY,
+    value DOUBLE NOT NULL,
+    updated_at TIMESTAMP(6) NOT NULL,
+    expired_at TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_expired_at ON rate_limits(expired_at);
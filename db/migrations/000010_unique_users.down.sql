@@ -0,0 +1,6 @@
+-- the normalization of the up migration is one-way, usernames and emails stay lowercased and
+-- trimmed since their original form was not kept
+DROP INDEX uq_users_email ON users;
+DROP INDEX uq_users_username ON users;
+
+CREATE INDEX idx_users_username ON users(username);
@@ -0,0 +1,8 @@
+-- usernames and emails are stored in their canonical form, the unique indexes fail when two
+-- existing accounts only differed in case or surrounding spaces and have to be merged first
+UPDATE users SET username = LOWER(TRIM(username)), email = LOWER(TRIM(email));
+
+DROP INDEX idx_users_username ON users;
+
+CREATE UNIQUE INDEX uq_users_username ON users(username);
+CREATE UNIQUE INDEX uq_users_email ON users(email);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- an external account signs in to a single user, a user links one account per provider
+CREATE UNIQUE INDEX uq_user_identities_provider_subject ON user_identities(provider, subject);
+CREATE UNIQUE INDEX uq_user_identities_user_id_provider ON user_identities(user_id, provider);
@@ -0,0 +1 @@
+DROP TABLE impersonation_sessions;
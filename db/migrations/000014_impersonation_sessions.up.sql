@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    actor_id INT NOT NULL,
+    user_id INT NOT NULL,
+    access_token_id VARCHAR(64) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    expired_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (actor_id) REFERENCES users(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX uq_impersonation_sessions_access_token_id ON impersonation_sessions(access_token_id);
+CREATE INDEX idx_impersonation_sessions_actor_id ON impersonation_sessions(actor_id);
+CREATE INDEX idx_impersonation_sessions_user_id ON impersonation_sessions(user_id);
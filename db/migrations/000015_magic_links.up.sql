@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS magic_link_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token VARCHAR(255) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    device_label VARCHAR(100) NOT NULL DEFAULT '',
+    expired_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_magic_link_tokens_token ON magic_link_tokens(token);
+CREATE INDEX idx_magic_link_tokens_user_id ON magic_link_tokens(user_id);
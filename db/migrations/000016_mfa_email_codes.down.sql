@@ -0,0 +1,3 @@
+DROP TABLE mfa_email_codes;
+
+ALTER TABLE user_mfa DROP COLUMN method;
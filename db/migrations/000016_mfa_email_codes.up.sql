@@ -0,0 +1,14 @@
+ALTER TABLE user_mfa ADD COLUMN method VARCHAR(20) NOT NULL DEFAULT 'totp' AFTER user_id;
+
+CREATE TABLE IF NOT EXISTS mfa_email_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    code VARCHAR(255) NOT NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    expired_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_mfa_email_codes_user_id ON mfa_email_codes(user_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    event VARCHAR(50) NOT NULL,
+    user_id INT NULL,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    reason VARCHAR(50) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_events_user_id_created_at ON audit_events(user_id, created_at);
+CREATE INDEX idx_audit_events_event_created_at ON audit_events(event, created_at);
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
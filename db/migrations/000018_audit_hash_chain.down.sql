@@ -0,0 +1,6 @@
+DROP TABLE audit_checkpoints;
+DROP TABLE audit_chain_head;
+
+ALTER TABLE audit_events
+    DROP COLUMN hash,
+    DROP COLUMN prev_hash;
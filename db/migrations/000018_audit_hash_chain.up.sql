@@ -0,0 +1,20 @@
+ALTER TABLE audit_events
+    ADD COLUMN prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN hash VARCHAR(64) NOT NULL DEFAULT '';
+
+-- single row holding the last chained event, locked while an event is appended
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+    id TINYINT PRIMARY KEY,
+    last_event_id BIGINT NOT NULL DEFAULT 0,
+    hash VARCHAR(64) NOT NULL DEFAULT ''
+);
+
+INSERT INTO audit_chain_head (id, last_event_id, hash) VALUES (1, 0, '');
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    last_event_id BIGINT NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    signature VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+DROP INDEX idx_refresh_tokens_expired_at ON refresh_tokens;
@@ -0,0 +1 @@
+CREATE INDEX idx_refresh_tokens_expired_at ON refresh_tokens(expired_at);
@@ -0,0 +1,4 @@
+DROP INDEX idx_mfa_email_codes_expired_at ON mfa_email_codes;
+DROP INDEX idx_magic_link_tokens_expired_at ON magic_link_tokens;
+DROP INDEX idx_email_verification_tokens_expired_at ON email_verification_tokens;
+DROP INDEX idx_password_reset_tokens_expired_at ON password_reset_tokens;
@@ -0,0 +1,4 @@
+CREATE INDEX idx_password_reset_tokens_expired_at ON password_reset_tokens(expired_at);
+CREATE INDEX idx_email_verification_tokens_expired_at ON email_verification_tokens(expired_at);
+CREATE INDEX idx_magic_link_tokens_expired_at ON magic_link_tokens(expired_at);
+CREATE INDEX idx_mfa_email_codes_expired_at ON mfa_email_codes(expired_at);
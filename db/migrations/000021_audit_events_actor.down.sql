@@ -0,0 +1,5 @@
+DROP INDEX idx_audit_events_actor_id_created_at ON audit_events;
+
+ALTER TABLE audit_events
+    DROP COLUMN details,
+    DROP COLUMN actor_id;
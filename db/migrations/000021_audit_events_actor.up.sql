@@ -0,0 +1,6 @@
+-- the admin acting in an impersonation event and the reason they gave
+ALTER TABLE audit_events
+    ADD COLUMN actor_id INT NULL AFTER user_id,
+    ADD COLUMN details VARCHAR(255) NOT NULL DEFAULT '' AFTER reason;
+
+CREATE INDEX idx_audit_events_actor_id_created_at ON audit_events(actor_id, created_at);
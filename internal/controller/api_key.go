@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type APIKeyController interface {
+	Create(ctx *fiber.Ctx) error
+	List(ctx *fiber.Ctx) error
+	Revoke(ctx *fiber.Ctx) error
+}
+
+type apiKeyController struct {
+	usecase usecase.APIKeyUsecase
+	logger  *logrus.Logger
+}
+
+func NewAPIKeyController(usecase usecase.APIKeyUsecase) APIKeyController {
+	logger := logger.Get()
+	return &apiKeyController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *apiKeyController) Create(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.CreateAPIKeyRequest
+	)
+
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseReqBody.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Create(user.ID, &reqBody, clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *apiKeyController) List(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.List(user.ID)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *apiKeyController) Revoke(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	keyID, err := ctx.ParamsInt("id")
+	if err != nil || keyID <= 0 {
+		c.logger.Errorf("error parsing api key id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	response := c.usecase.Revoke(user.ID, uint(keyID), clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type AuditController interface {
+	List(ctx *fiber.Ctx) error
+	Verify(ctx *fiber.Ctx) error
+	ExportCheckpoints(ctx *fiber.Ctx) error
+}
+
+type auditController struct {
+	usecase usecase.AuditUsecase
+	logger  *logrus.Logger
+}
+
+func NewAuditController(usecase usecase.AuditUsecase) AuditController {
+	logger := logger.Get()
+	return &auditController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *auditController) List(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqQuery entity.ListAuditEventsRequest
+	)
+
+	if err := ctx.QueryParser(&reqQuery); err != nil {
+		c.logger.Errorf("error parsing query param: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqQuery struct
+	validationErr := pkg.ValidateRequest(&reqQuery)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.List(&reqQuery)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *auditController) Verify(ctx *fiber.Ctx) error {
+	response := c.usecase.Verify()
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+// ExportCheckpoints downloads the signed checkpoints of the audit chain as JSON lines
+func (c *auditController) ExportCheckpoints(ctx *fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := c.usecase.ExportCheckpoints(&buf); err != nil {
+		c.logger.Errorf("auditUsecase.ExportCheckpoints: %s", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+	}
+
+	ctx.Attachment("audit-checkpoints.jsonl")
+	ctx.Set(fiber.HeaderContentType, "application/x-ndjson")
+
+	return ctx.Status(http.StatusOK).Send(buf.Bytes())
+}
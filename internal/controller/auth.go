@@ -1,11 +1,10 @@
 package controller
 
 import (
-	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/fazriegi/go-boilerplate/internal/entity"
-	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
 	"github.com/fazriegi/go-boilerplate/internal/pkg"
 	"github.com/fazriegi/go-boilerplate/internal/usecase"
@@ -16,9 +15,12 @@ import (
 type AuthController interface {
 	Register(ctx *fiber.Ctx) error
 	Login(ctx *fiber.Ctx) error
+	VerifyMFA(ctx *fiber.Ctx) error
+	ResendMFACode(ctx *fiber.Ctx) error
 	CheckToken(ctx *fiber.Ctx) error
 	RefreshToken(ctx *fiber.Ctx) error
 	Logout(ctx *fiber.Ctx) error
+	LogoutAll(ctx *fiber.Ctx) error
 }
 
 type authController struct {
@@ -55,7 +57,7 @@ func (c *authController) Register(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
 	}
 
-	response = c.usecase.Register(&reqBody)
+	response = c.usecase.Register(&reqBody, clientInfo(ctx))
 
 	return ctx.Status(response.Status.Code).JSON(response)
 }
@@ -81,7 +83,54 @@ func (c *authController) Login(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
 	}
 
-	response = c.usecase.Login(&reqBody)
+	client := clientInfo(ctx)
+
+	response = c.usecase.Login(&reqBody, client)
+
+	if response.Data != nil {
+		data, ok := response.Data.(map[string]any)
+		if !ok {
+			c.logger.Errorf("error convert data")
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
+
+		if err := deliverTokens(ctx, data); err != nil {
+			c.logger.Errorf("deliverTokens: %s", err.Error())
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
+
+		response.Data = data
+	}
+
+	setRetryAfter(ctx, response)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *authController) VerifyMFA(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.MFALoginRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	client := clientInfo(ctx)
+
+	response = c.usecase.VerifyMFA(&reqBody, client)
 
 	if response.Data != nil {
 		data, ok := response.Data.(map[string]any)
@@ -90,33 +139,45 @@ func (c *authController) Login(ctx *fiber.Ctx) error {
 			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
 		}
 
-		accessTokenExp := config.GetUint("JWT_ACCESS_EXP_MINUTE")
-		refreshTokenExp := config.GetUint("JWT_REFRESH_EXP_DAY")
-
-		ctx.Cookie(&fiber.Cookie{
-			Name:     "access_token",
-			Value:    fmt.Sprint(data["access_token"]),
-			HTTPOnly: true,
-			Secure:   true,
-			SameSite: "Lax",
-			MaxAge:   int(accessTokenExp) * 60, // minute
-		})
-
-		ctx.Cookie(&fiber.Cookie{
-			Name:     "refresh_token",
-			Value:    fmt.Sprint(data["refresh_token"]),
-			HTTPOnly: true,
-			Secure:   true,
-			SameSite: "Lax",
-			MaxAge:   int(refreshTokenExp) * 24 * 60 * 60, // day
-		})
-
-		delete(data, "access_token")
-		delete(data, "refresh_token")
+		if err := deliverTokens(ctx, data); err != nil {
+			c.logger.Errorf("deliverTokens: %s", err.Error())
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
 
 		response.Data = data
 	}
 
+	setRetryAfter(ctx, response)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+// ResendMFACode emails a new code for a login with the email second factor
+func (c *authController) ResendMFACode(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.MFACodeRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.ResendMFACode(&reqBody)
+
+	setRetryAfter(ctx, response)
+
 	return ctx.Status(response.Status.Code).JSON(response)
 }
 
@@ -125,9 +186,13 @@ func (c *authController) CheckToken(ctx *fiber.Ctx) error {
 }
 
 func (c *authController) RefreshToken(ctx *fiber.Ctx) error {
-	refreshToken := ctx.Cookies("refresh_token")
+	refreshToken, err := refreshTokenFromRequest(ctx)
+	if err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseReqBody.Error(), nil, nil))
+	}
 
-	response := c.usecase.RefreshToken(refreshToken)
+	response := c.usecase.RefreshToken(refreshToken, clientInfo(ctx))
 
 	if response.Data != nil {
 		data, ok := response.Data.(map[string]any)
@@ -136,53 +201,71 @@ func (c *authController) RefreshToken(ctx *fiber.Ctx) error {
 			return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
 		}
 
-		accessTokenExp := config.GetUint("JWT_ACCESS_EXP_MINUTE")
-		refreshTokenExp := config.GetUint("JWT_REFRESH_EXP_DAY")
-
-		ctx.Cookie(&fiber.Cookie{
-			Name:     "access_token",
-			Value:    fmt.Sprint(data["access_token"]),
-			HTTPOnly: true,
-			Secure:   true,
-			SameSite: "Lax",
-			MaxAge:   int(accessTokenExp) * 60, // minute
-		})
-
-		ctx.Cookie(&fiber.Cookie{
-			Name:     "refresh_token",
-			Value:    fmt.Sprint(data["refresh_token"]),
-			HTTPOnly: true,
-			Secure:   true,
-			SameSite: "Lax",
-			MaxAge:   int(refreshTokenExp) * 24 * 60 * 60, // day
-		})
+		if err := deliverTokens(ctx, data); err != nil {
+			c.logger.Errorf("deliverTokens: %s", err.Error())
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
 
-		response.Data = nil
+		response.Data = data
+		if len(data) == 0 {
+			response.Data = nil
+		}
 	}
 
 	return ctx.Status(response.Status.Code).JSON(response)
 }
 
 func (c *authController) Logout(ctx *fiber.Ctx) error {
-	refreshToken := ctx.Cookies("refresh_token")
-
-	c.usecase.Logout(refreshToken)
-
-	ctx.Cookie(&fiber.Cookie{
-		Name:     "access_token",
-		Value:    "",
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: "Lax",
-	})
-
-	ctx.Cookie(&fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    "",
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: "Lax",
-	})
+	refreshToken, err := refreshTokenFromRequest(ctx)
+	if err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseReqBody.Error(), nil, nil))
+	}
+
+	c.usecase.Logout(refreshToken, clientInfo(ctx))
+
+	clearTokenCookies(ctx)
 
 	return ctx.Status(200).JSON(pkg.NewResponse(http.StatusOK, "success", nil, nil))
 }
+
+func (c *authController) LogoutAll(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.LogoutAll(user.ID, clientInfo(ctx))
+	if !response.IsSuccess {
+		return ctx.Status(response.Status.Code).JSON(response)
+	}
+
+	clearTokenCookies(ctx)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+// clientInfo describes the client of the request, the request ID is set by the requestid
+// middleware
+func clientInfo(ctx *fiber.Ctx) entity.ClientInfo {
+	requestID, _ := ctx.Locals("requestid").(string)
+
+	return entity.ClientInfo{
+		IPAddress: ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+		RequestID: requestID,
+	}
+}
+
+// setRetryAfter copies the retry_after of a 429 response into the Retry-After header
+func setRetryAfter(ctx *fiber.Ctx, response pkg.Response) {
+	if response.Code != http.StatusTooManyRequests {
+		return
+	}
+
+	if data, ok := response.Data.(map[string]any); ok {
+		if seconds, ok := data["retry_after"].(int); ok {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type ImpersonationController interface {
+	Start(ctx *fiber.Ctx) error
+	Stop(ctx *fiber.Ctx) error
+}
+
+type impersonationController struct {
+	usecase usecase.ImpersonationUsecase
+	logger  *logrus.Logger
+}
+
+func NewImpersonationController(usecase usecase.ImpersonationUsecase) ImpersonationController {
+	logger := logger.Get()
+	return &impersonationController{
+		usecase,
+		logger,
+	}
+}
+
+// Start returns the impersonation token in the body only, cookies would replace the session
+// of the admin
+func (c *impersonationController) Start(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.ImpersonateRequest
+	)
+
+	actor, ok := ctx.Locals("actor").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	userID, err := ctx.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		c.logger.Errorf("error parsing user id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseReqBody.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	client := clientInfo(ctx)
+
+	response = c.usecase.Start(actor, uint(userID), &reqBody, client)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *impersonationController) Stop(ctx *fiber.Ctx) error {
+	claims, ok := ctx.Locals("claims").(*pkg.AccessClaims)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.Stop(claims, clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
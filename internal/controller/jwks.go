@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+type JWKSController interface {
+	Get(ctx *fiber.Ctx) error
+}
+
+type jwksController struct {
+	jwt *pkg.JWT
+}
+
+func NewJWKSController(jwt *pkg.JWT) JWKSController {
+	return &jwksController{
+		jwt,
+	}
+}
+
+// Get serves the public signing keys as a plain JWK set so standard JWT libraries can consume it
+func (c *jwksController) Get(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+
+	return ctx.JSON(c.jwt.Keyring().JWKS())
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type LockoutController interface {
+	Clear(ctx *fiber.Ctx) error
+}
+
+type lockoutController struct {
+	usecase usecase.LockoutUsecase
+	logger  *logrus.Logger
+}
+
+func NewLockoutController(usecase usecase.LockoutUsecase) LockoutController {
+	logger := logger.Get()
+	return &lockoutController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *lockoutController) Clear(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqQuery entity.ClearLockoutRequest
+	)
+
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	if err := ctx.QueryParser(&reqQuery); err != nil {
+		c.logger.Errorf("error parsing query param: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqQuery struct
+	validationErr := pkg.ValidateRequest(&reqQuery)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Clear(user.ID, &reqQuery)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
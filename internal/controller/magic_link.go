@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// magicLinkNonceCookieName binds a sign in link to the browser that asked for it
+const magicLinkNonceCookieName = "magic_link_nonce"
+
+type MagicLinkController interface {
+	Send(ctx *fiber.Ctx) error
+	Verify(ctx *fiber.Ctx) error
+}
+
+type magicLinkController struct {
+	usecase usecase.MagicLinkUsecase
+	logger  *logrus.Logger
+}
+
+func NewMagicLinkController(usecase usecase.MagicLinkUsecase) MagicLinkController {
+	logger := logger.Get()
+	return &magicLinkController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *magicLinkController) Send(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.MagicLinkRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Send(&reqBody, clientInfo(ctx))
+	if !response.IsSuccess {
+		return ctx.Status(response.Status.Code).JSON(response)
+	}
+
+	setMagicLinkNonceCookie(ctx, response.Data.(map[string]any)["nonce"].(string))
+	response.Data = nil
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+// Verify signs in with a link like a login
+func (c *magicLinkController) Verify(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.VerifyMagicLinkRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	client := clientInfo(ctx)
+
+	response = c.usecase.Verify(&reqBody, ctx.Cookies(magicLinkNonceCookieName), client)
+
+	if response.Data != nil {
+		data, ok := response.Data.(map[string]any)
+		if !ok {
+			c.logger.Errorf("error convert data")
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
+
+		if err := deliverTokens(ctx, data); err != nil {
+			c.logger.Errorf("deliverTokens: %s", err.Error())
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
+
+		response.Data = data
+	}
+
+	// the nonce is done with once its link is used
+	if response.IsSuccess {
+		setMagicLinkNonceCookie(ctx, "")
+	}
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+// setMagicLinkNonceCookie stores the nonce for as long as the link is valid, an empty nonce
+// clears it
+func setMagicLinkNonceCookie(ctx *fiber.Ctx, nonce string) {
+	maxAge := int(config.GetUint("MAGIC_LINK_EXP_MINUTE")) * 60 // minute
+	if nonce == "" {
+		maxAge = -1
+	}
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     magicLinkNonceCookieName,
+		Value:    nonce,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+		MaxAge:   maxAge,
+	})
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type MFAController interface {
+	Enroll(ctx *fiber.Ctx) error
+	EnrollEmail(ctx *fiber.Ctx) error
+	SendEmailCode(ctx *fiber.Ctx) error
+	Confirm(ctx *fiber.Ctx) error
+	Disable(ctx *fiber.Ctx) error
+}
+
+type mfaController struct {
+	usecase usecase.MFAUsecase
+	logger  *logrus.Logger
+}
+
+func NewMFAController(usecase usecase.MFAUsecase) MFAController {
+	logger := logger.Get()
+	return &mfaController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *mfaController) Enroll(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.Enroll(user.ID)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *mfaController) EnrollEmail(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.EnrollEmail(user.ID)
+
+	setRetryAfter(ctx, response)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *mfaController) SendEmailCode(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.SendEmailCode(user.ID)
+
+	setRetryAfter(ctx, response)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *mfaController) Confirm(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.ConfirmMFARequest
+	)
+
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Confirm(user.ID, &reqBody, clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *mfaController) Disable(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.DisableMFARequest
+	)
+
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Disable(user.ID, &reqBody, clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
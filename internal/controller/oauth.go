@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// oauthFlowCookieName keeps the sealed flow between the start and the callback, it has to be
+// SameSite Lax so the browser sends it on the redirect back from the provider
+const oauthFlowCookieName = "oauth_flow"
+
+type OAuthController interface {
+	Start(ctx *fiber.Ctx) error
+	Callback(ctx *fiber.Ctx) error
+	Link(ctx *fiber.Ctx) error
+	Identities(ctx *fiber.Ctx) error
+	Unlink(ctx *fiber.Ctx) error
+}
+
+type oauthController struct {
+	usecase usecase.OAuthUsecase
+	logger  *logrus.Logger
+}
+
+func NewOAuthController(usecase usecase.OAuthUsecase) OAuthController {
+	logger := logger.Get()
+	return &oauthController{
+		usecase,
+		logger,
+	}
+}
+
+// Start redirects the browser to the provider to sign in
+func (c *oauthController) Start(ctx *fiber.Ctx) error {
+	var reqQuery entity.OAuthStartRequest
+
+	if err := ctx.QueryParser(&reqQuery); err != nil {
+		c.logger.Errorf("error parsing query param: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqQuery struct
+	validationErr := pkg.ValidateRequest(&reqQuery)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response := c.usecase.Start(ctx.Params("provider"), 0, reqQuery.DeviceLabel)
+	if !response.IsSuccess {
+		return ctx.Status(response.Status.Code).JSON(response)
+	}
+
+	data := response.Data.(map[string]any)
+	setOAuthFlowCookie(ctx, data["flow"].(string))
+
+	return ctx.Redirect(data["authorization_url"].(string), http.StatusFound)
+}
+
+// Callback is where the provider redirects back to, it signs in like a login
+func (c *oauthController) Callback(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqQuery entity.OAuthCallbackRequest
+	)
+
+	// the flow is single use, whatever the outcome
+	flow := ctx.Cookies(oauthFlowCookieName)
+	setOAuthFlowCookie(ctx, "")
+
+	if err := ctx.QueryParser(&reqQuery); err != nil {
+		c.logger.Errorf("error parsing query param: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqQuery struct
+	validationErr := pkg.ValidateRequest(&reqQuery)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	client := clientInfo(ctx)
+
+	response = c.usecase.Callback(ctx.Params("provider"), &reqQuery, flow, client)
+
+	if response.Data != nil {
+		data, ok := response.Data.(map[string]any)
+		if !ok {
+			c.logger.Errorf("error convert data")
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
+
+		if err := deliverTokens(ctx, data); err != nil {
+			c.logger.Errorf("deliverTokens: %s", err.Error())
+			return ctx.Status(http.StatusInternalServerError).JSON(pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil))
+		}
+
+		response.Data = data
+	}
+
+	setRetryAfter(ctx, response)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+// Link starts a flow linking an external account to the signed in user, the authorization
+// URL is returned for the frontend to navigate to since the request carries credentials
+func (c *oauthController) Link(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.Start(ctx.Params("provider"), user.ID, "")
+	if !response.IsSuccess {
+		return ctx.Status(response.Status.Code).JSON(response)
+	}
+
+	data := response.Data.(map[string]any)
+	setOAuthFlowCookie(ctx, data["flow"].(string))
+	delete(data, "flow")
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *oauthController) Identities(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.ListIdentities(user.ID)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *oauthController) Unlink(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.Unlink(user.ID, ctx.Params("provider"))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+// setOAuthFlowCookie stores the sealed flow, an empty flow clears it
+func setOAuthFlowCookie(ctx *fiber.Ctx, flow string) {
+	maxAge := int(config.GetUint("OAUTH_FLOW_EXP_MINUTE")) * 60 // minute
+	if flow == "" {
+		maxAge = -1
+	}
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oauthFlowCookieName,
+		Value:    flow,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+		MaxAge:   maxAge,
+	})
+}
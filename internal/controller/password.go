@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type PasswordController interface {
+	Forgot(ctx *fiber.Ctx) error
+	Reset(ctx *fiber.Ctx) error
+	Change(ctx *fiber.Ctx) error
+}
+
+type passwordController struct {
+	usecase usecase.PasswordUsecase
+	logger  *logrus.Logger
+}
+
+func NewPasswordController(usecase usecase.PasswordUsecase) PasswordController {
+	logger := logger.Get()
+	return &passwordController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *passwordController) Forgot(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.ForgotPasswordRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Forgot(&reqBody, clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *passwordController) Reset(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.ResetPasswordRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Reset(&reqBody, clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *passwordController) Change(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.ChangePasswordRequest
+	)
+
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	claims, ok := ctx.Locals("claims").(*pkg.AccessClaims)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Change(user.ID, claims.ID, &reqBody, clientInfo(ctx))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type RoleController interface {
+	ListRoles(ctx *fiber.Ctx) error
+	CreateRole(ctx *fiber.Ctx) error
+	DeleteRole(ctx *fiber.Ctx) error
+	SetRolePermissions(ctx *fiber.Ctx) error
+	ListPermissions(ctx *fiber.Ctx) error
+	ListUserRoles(ctx *fiber.Ctx) error
+	AssignRole(ctx *fiber.Ctx) error
+	RemoveRole(ctx *fiber.Ctx) error
+}
+
+type roleController struct {
+	usecase usecase.RoleUsecase
+	logger  *logrus.Logger
+}
+
+func NewRoleController(usecase usecase.RoleUsecase) RoleController {
+	logger := logger.Get()
+	return &roleController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *roleController) ListRoles(ctx *fiber.Ctx) error {
+	response := c.usecase.ListRoles()
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *roleController) CreateRole(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.CreateRoleRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseReqBody.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.CreateRole(&reqBody)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *roleController) DeleteRole(ctx *fiber.Ctx) error {
+	roleID, err := ctx.ParamsInt("id")
+	if err != nil || roleID <= 0 {
+		c.logger.Errorf("error parsing role id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	response := c.usecase.DeleteRole(uint(roleID))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *roleController) SetRolePermissions(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.SetRolePermissionsRequest
+	)
+
+	roleID, err := ctx.ParamsInt("id")
+	if err != nil || roleID <= 0 {
+		c.logger.Errorf("error parsing role id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseReqBody.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.SetRolePermissions(uint(roleID), &reqBody)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *roleController) ListPermissions(ctx *fiber.Ctx) error {
+	response := c.usecase.ListPermissions()
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *roleController) ListUserRoles(ctx *fiber.Ctx) error {
+	userID, err := ctx.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		c.logger.Errorf("error parsing user id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	response := c.usecase.ListUserRoles(uint(userID))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *roleController) AssignRole(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.AssignRoleRequest
+	)
+
+	actor, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	userID, err := ctx.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		c.logger.Errorf("error parsing user id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseReqBody.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.AssignRole(actor.ID, uint(userID), &reqBody)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *roleController) RemoveRole(ctx *fiber.Ctx) error {
+	actor, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	userID, err := ctx.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		c.logger.Errorf("error parsing user id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	response := c.usecase.RemoveRole(actor.ID, uint(userID), ctx.Params("role"))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
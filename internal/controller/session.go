@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type SessionController interface {
+	List(ctx *fiber.Ctx) error
+	Revoke(ctx *fiber.Ctx) error
+}
+
+type sessionController struct {
+	usecase usecase.SessionUsecase
+	logger  *logrus.Logger
+}
+
+func NewSessionController(usecase usecase.SessionUsecase) SessionController {
+	logger := logger.Get()
+	return &sessionController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *sessionController) List(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	claims, ok := ctx.Locals("claims").(*pkg.AccessClaims)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	response := c.usecase.List(user.ID, claims.ID)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *sessionController) Revoke(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(entity.User)
+	if !ok {
+		return ctx.Status(http.StatusUnauthorized).JSON(pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil))
+	}
+
+	sessionID, err := ctx.ParamsInt("id")
+	if err != nil || sessionID <= 0 {
+		c.logger.Errorf("error parsing session id: %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	response := c.usecase.Revoke(user.ID, uint(sessionID))
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
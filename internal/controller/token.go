@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+// token delivery modes configured by AUTH_TOKEN_DELIVERY
+const (
+	tokenDeliveryCookie = "cookie"
+	tokenDeliveryBody   = "body"
+	tokenDeliveryBoth   = "both"
+)
+
+func tokenDelivery() string {
+	switch mode := config.GetString("AUTH_TOKEN_DELIVERY"); mode {
+	case tokenDeliveryBody, tokenDeliveryBoth:
+		return mode
+	default:
+		return tokenDeliveryCookie
+	}
+}
+
+// deliverTokens hands the access_token and refresh_token of data to the client as cookies,
+// in the response body or both depending on the configured delivery mode. Data without
+// tokens, like an MFA challenge, is left untouched
+func deliverTokens(ctx *fiber.Ctx, data map[string]any) error {
+	accessToken, hasAccessToken := data["access_token"].(string)
+	refreshToken, hasRefreshToken := data["refresh_token"].(string)
+	if !hasAccessToken || !hasRefreshToken {
+		return nil
+	}
+
+	mode := tokenDelivery()
+
+	if mode == tokenDeliveryCookie || mode == tokenDeliveryBoth {
+		if err := setTokenCookies(ctx, accessToken, refreshToken); err != nil {
+			return err
+		}
+	}
+
+	if mode == tokenDeliveryCookie {
+		delete(data, "access_token")
+		delete(data, "refresh_token")
+	}
+
+	return nil
+}
+
+func setTokenCookies(ctx *fiber.Ctx, accessToken, refreshToken string) error {
+	accessTokenExp := config.GetUint("JWT_ACCESS_EXP_MINUTE")
+	refreshTokenExp := config.GetUint("JWT_REFRESH_EXP_DAY")
+
+	// double submit token, readable by the frontend so it can be echoed in the X-CSRF-Token header
+	csrfToken, err := pkg.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     "access_token",
+		Value:    accessToken,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+		MaxAge:   int(accessTokenExp) * 60, // minute
+	})
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+		MaxAge:   int(refreshTokenExp) * 24 * 60 * 60, // day
+	})
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     middleware.CSRFCookieName,
+		Value:    csrfToken,
+		HTTPOnly: false,
+		Secure:   true,
+		SameSite: "Lax",
+		MaxAge:   int(refreshTokenExp) * 24 * 60 * 60, // day
+	})
+
+	return nil
+}
+
+func clearTokenCookies(ctx *fiber.Ctx) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     "access_token",
+		Value:    "",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     middleware.CSRFCookieName,
+		Value:    "",
+		HTTPOnly: false,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+// refreshTokenFromRequest reads the refresh token from the JSON body and falls back to the cookie
+func refreshTokenFromRequest(ctx *fiber.Ctx) (string, error) {
+	if len(ctx.Body()) > 0 {
+		var reqBody entity.RefreshTokenRequest
+		if err := ctx.BodyParser(&reqBody); err != nil {
+			return "", err
+		}
+
+		if reqBody.RefreshToken != "" {
+			return reqBody.RefreshToken, nil
+		}
+	}
+
+	return ctx.Cookies("refresh_token"), nil
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDeliverTokens(t *testing.T) {
+	config.Set("JWT_ACCESS_EXP_MINUTE", 15)
+	config.Set("JWT_REFRESH_EXP_DAY", 7)
+
+	app := fiber.New()
+	app.Get("/tokens", func(ctx *fiber.Ctx) error {
+		data := map[string]any{"access_token": "access", "refresh_token": "refresh", "user_id": 1}
+		if err := deliverTokens(ctx, data); err != nil {
+			return err
+		}
+		return ctx.JSON(data)
+	})
+	app.Get("/challenge", func(ctx *fiber.Ctx) error {
+		data := map[string]any{"mfa_token": "challenge"}
+		if err := deliverTokens(ctx, data); err != nil {
+			return err
+		}
+		return ctx.JSON(data)
+	})
+
+	tests := []struct {
+		mode    string
+		path    string
+		cookies []string
+		body    []string
+	}{
+		{"cookie", "/tokens", []string{"access_token", "refresh_token", middleware.CSRFCookieName}, []string{"user_id"}},
+		{"body", "/tokens", nil, []string{"access_token", "refresh_token", "user_id"}},
+		{"both", "/tokens", []string{"access_token", "refresh_token", middleware.CSRFCookieName}, []string{"access_token", "refresh_token", "user_id"}},
+		// an unknown mode falls back to cookies
+		{"header", "/tokens", []string{"access_token", "refresh_token", middleware.CSRFCookieName}, []string{"user_id"}},
+		{"cookie", "/challenge", nil, []string{"mfa_token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode+tt.path, func(t *testing.T) {
+			config.Set("AUTH_TOKEN_DELIVERY", tt.mode)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var cookies []string
+			for _, cookie := range resp.Cookies() {
+				cookies = append(cookies, cookie.Name)
+				if cookie.Name != middleware.CSRFCookieName && !cookie.HttpOnly {
+					t.Errorf("expected cookie %s to be http only", cookie.Name)
+				}
+			}
+			if strings.Join(cookies, ",") != strings.Join(tt.cookies, ",") {
+				t.Fatalf("expected cookies %v, got %v", tt.cookies, cookies)
+			}
+
+			var body map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body) != len(tt.body) {
+				t.Fatalf("expected body fields %v, got %v", tt.body, body)
+			}
+			for _, field := range tt.body {
+				if _, ok := body[field]; !ok {
+					t.Fatalf("expected body fields %v, got %v", tt.body, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshTokenFromRequest(t *testing.T) {
+	app := fiber.New()
+	app.Post("/refresh", func(ctx *fiber.Ctx) error {
+		token, err := refreshTokenFromRequest(ctx)
+		if err != nil {
+			return ctx.SendStatus(http.StatusBadRequest)
+		}
+		return ctx.SendString(token)
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		cookie string
+		want   int
+		token  string
+	}{
+		{"body", `{"refresh_token":"from-body"}`, "", http.StatusOK, "from-body"},
+		{"cookie", "", "from-cookie", http.StatusOK, "from-cookie"},
+		{"body wins over cookie", `{"refresh_token":"from-body"}`, "from-cookie", http.StatusOK, "from-body"},
+		{"body without token falls back to cookie", `{}`, "from-cookie", http.StatusOK, "from-cookie"},
+		{"malformed body", `{"refresh_token":`, "from-cookie", http.StatusBadRequest, ""},
+		{"no token", "", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/refresh", strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tt.cookie})
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode == http.StatusOK && string(body) != tt.token {
+				t.Fatalf("expected token %q, got %q", tt.token, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type EmailVerificationController interface {
+	Verify(ctx *fiber.Ctx) error
+	Resend(ctx *fiber.Ctx) error
+}
+
+type emailVerificationController struct {
+	usecase usecase.EmailVerificationUsecase
+	logger  *logrus.Logger
+}
+
+func NewEmailVerificationController(usecase usecase.EmailVerificationUsecase) EmailVerificationController {
+	logger := logger.Get()
+	return &emailVerificationController{
+		usecase,
+		logger,
+	}
+}
+
+func (c *emailVerificationController) Verify(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.VerifyEmailRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Verify(&reqBody)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
+
+func (c *emailVerificationController) Resend(ctx *fiber.Ctx) error {
+	var (
+		response pkg.Response
+		reqBody  entity.ResendVerificationRequest
+	)
+
+	if err := ctx.BodyParser(&reqBody); err != nil {
+		c.logger.Errorf("error parsing request body: %s", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(pkg.NewResponse(http.StatusBadRequest, pkg.ErrParseQueryParam.Error(), nil, nil))
+	}
+
+	// validate reqBody struct
+	validationErr := pkg.ValidateRequest(&reqBody)
+	if len(validationErr) > 0 {
+		errResponse := map[string]any{
+			"errors": validationErr,
+		}
+
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil))
+	}
+
+	response = c.usecase.Resend(&reqBody)
+
+	return ctx.Status(response.Status.Code).JSON(response)
+}
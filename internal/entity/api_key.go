@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// scopes an API key can be granted, sessions of a signed in user hold all of them. A key needs
+// admin to reach the admin routes and also write for the ones that change state
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+	APIKeyScopeAdmin = "admin"
+)
+
+type (
+	CreateAPIKeyRequest struct {
+		Name   string   `json:"name" validate:"required,max=100"`
+		Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=read write admin"`
+		// ExpiresInDays of 0 creates a key that does not expire
+		ExpiresInDays uint `json:"expires_in_days" validate:"max=3650"`
+	}
+
+	// APIKeyResponse describes a key without its secret, Key is only set once when the key is created
+	APIKeyResponse struct {
+		ID         uint       `json:"id"`
+		Name       string     `json:"name"`
+		Key        string     `json:"key,omitempty"`
+		Prefix     string     `json:"prefix"`
+		Scopes     []string   `json:"scopes"`
+		ExpiredAt  *time.Time `json:"expired_at"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+		CreatedAt  time.Time  `json:"created_at"`
+	}
+
+	// APIKey is a long-lived credential of a machine client acting as its user, only the hash
+	// of the key is stored and Scopes is comma separated
+	APIKey struct {
+		ID         uint       `db:"id"`
+		UserId     uint       `db:"user_id"`
+		Name       string     `db:"name"`
+		Prefix     string     `db:"prefix"`
+		KeyHash    string     `db:"key_hash"`
+		Scopes     string     `db:"scopes"`
+		ExpiredAt  *time.Time `db:"expired_at"`
+		LastUsedAt *time.Time `db:"last_used_at"`
+		RevokedAt  *time.Time `db:"revoked_at"`
+		CreatedAt  time.Time  `db:"created_at"`
+	}
+)
+
+func (k APIKey) ScopeList() []string {
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.ScopeList(), scope)
+}
@@ -0,0 +1,113 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+)
+
+// events recorded in the audit log
+const (
+	AuditEventRegistered             = "registered"
+	AuditEventLoginSucceeded         = "login_succeeded"
+	AuditEventLoginFailed            = "login_failed"
+	AuditEventTokenRefreshed         = "token_refreshed"
+	AuditEventRefreshTokenReused     = "refresh_token_reused"
+	AuditEventLoggedOut              = "logged_out"
+	AuditEventLoggedOutAll           = "logged_out_all"
+	AuditEventPasswordChanged        = "password_changed"
+	AuditEventPasswordReset          = "password_reset"
+	AuditEventPasswordResetRequested = "password_reset_requested"
+	AuditEventMagicLinkRequested     = "magic_link_requested"
+	AuditEventMagicLinkUsed          = "magic_link_used"
+	AuditEventMFAEnabled             = "mfa_enabled"
+	AuditEventMFADisabled            = "mfa_disabled"
+	AuditEventAPIKeyCreated          = "api_key_created"
+	AuditEventAPIKeyRevoked          = "api_key_revoked"
+	AuditEventImpersonationStarted   = "impersonation_started"
+	AuditEventImpersonationStopped   = "impersonation_stopped"
+)
+
+// reasons of a failed login, unknown_user also marks a link asked for an unknown email
+const (
+	AuditReasonUnknownUser      = "unknown_user"
+	AuditReasonInvalidPassword  = "invalid_password"
+	AuditReasonInvalidMFACode   = "invalid_mfa_code"
+	AuditReasonLocked           = "locked"
+	AuditReasonEmailNotVerified = "email_not_verified"
+)
+
+type (
+	// ListAuditEventsRequest filters the audit log, from and to are RFC 3339 times and sort
+	// takes id, event, user_id or created_at followed by asc or desc
+	ListAuditEventsRequest struct {
+		pkg.PaginationRequest
+		UserId    *uint  `query:"user_id"`
+		ActorId   *uint  `query:"actor_id"`
+		Event     string `query:"event" validate:"max=50"`
+		IPAddress string `query:"ip_address" validate:"omitempty,ip"`
+		RequestId string `query:"request_id" validate:"max=64"`
+		From      string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+		To        string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	}
+
+	AuditEventFilter struct {
+		UserId    *uint
+		ActorId   *uint
+		Event     string
+		IPAddress string
+		RequestId string
+		From      *time.Time
+		To        *time.Time
+	}
+
+	// AuditEvent is a single entry of the audit log, Username is the login that was tried
+	// when no user could be found for it or the admin acting in an impersonation event.
+	// ActorId is the id of that admin and Details the reason they gave.
+	//
+	// Hash chains the entry to the one before it, entries recorded before the chain was
+	// introduced have none
+	AuditEvent struct {
+		ID        uint      `db:"id" json:"id"`
+		Event     string    `db:"event" json:"event"`
+		UserId    *uint     `db:"user_id" json:"user_id"`
+		ActorId   *uint     `db:"actor_id" json:"actor_id,omitempty"`
+		Username  string    `db:"username" json:"username,omitempty"`
+		Reason    string    `db:"reason" json:"reason,omitempty"`
+		Details   string    `db:"details" json:"details,omitempty"`
+		IPAddress string    `db:"ip_address" json:"ip_address"`
+		UserAgent string    `db:"user_agent" json:"user_agent"`
+		RequestId string    `db:"request_id" json:"request_id"`
+		CreatedAt time.Time `db:"created_at" json:"created_at"`
+		PrevHash  string    `db:"prev_hash" json:"prev_hash"`
+		Hash      string    `db:"hash" json:"hash"`
+	}
+
+	// AuditChainHead is the last chained event, the next event is appended after it
+	AuditChainHead struct {
+		LastEventId uint   `db:"last_event_id"`
+		Hash        string `db:"hash"`
+	}
+
+	// AuditCheckpoint pins the hash of the chain at an event, the signature keeps it from
+	// being rewritten together with the events
+	AuditCheckpoint struct {
+		ID          uint      `db:"id" json:"id"`
+		LastEventId uint      `db:"last_event_id" json:"last_event_id"`
+		Hash        string    `db:"hash" json:"hash"`
+		Signature   string    `db:"signature" json:"signature"`
+		CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	}
+
+	// AuditChainReport is the outcome of walking the audit chain, on a broken link it names
+	// the first event or checkpoint that does not verify
+	AuditChainReport struct {
+		Valid              bool   `json:"valid"`
+		CheckedEvents      int    `json:"checked_events"`
+		UnchainedEvents    int    `json:"unchained_events"`
+		CheckedCheckpoints int    `json:"checked_checkpoints"`
+		BrokenEventId      *uint  `json:"broken_event_id,omitempty"`
+		BrokenCheckpointId *uint  `json:"broken_checkpoint_id,omitempty"`
+		Reason             string `json:"reason,omitempty"`
+	}
+)
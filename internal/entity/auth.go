@@ -5,20 +5,46 @@ import "time"
 type (
 	RegisterRequest struct {
 		Name     string `json:"name" validate:"required"`
-		Username string `json:"username" validate:"required"`
-		Email    string `json:"email" validate:"required,email"`
+		Username string `json:"username" validate:"required,max=50,excludes=@"`
+		Email    string `json:"email" validate:"required,email,max=255"`
 		Password string `json:"password" validate:"required"`
 	}
 
 	LoginRequest struct {
-		Username string `json:"username" validate:"required"`
-		Password string `json:"password" validate:"required"`
+		// Username is the username or the email of the user
+		Username    string `json:"username" validate:"required"`
+		Password    string `json:"password" validate:"required"`
+		DeviceLabel string `json:"device_label" validate:"max=100"`
+	}
+
+	RefreshTokenRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// ClientInfo describes the client that issued the current request
+	ClientInfo struct {
+		IPAddress string
+		UserAgent string
+		RequestID string
 	}
 
+	// RefreshToken is a single token of a session, rotating a token consumes it and
+	// issues a child in the same family
 	RefreshToken struct {
-		ID        uint      `db:"id"`
-		UserId    uint      `db:"user_id" `
-		Token     string    `db:"token" `
-		ExpiredAt time.Time `db:"expired_at" `
+		ID              uint       `db:"id"`
+		UserId          uint       `db:"user_id" `
+		Token           string     `db:"token" `
+		FamilyId        string     `db:"family_id"`
+		ParentId        *uint      `db:"parent_id"`
+		AccessTokenId   string     `db:"access_token_id"`
+		AccessExpiredAt *time.Time `db:"access_expired_at"`
+		DeviceLabel     string     `db:"device_label"`
+		UserAgent       string     `db:"user_agent"`
+		IPAddress       string     `db:"ip_address"`
+		ExpiredAt       time.Time  `db:"expired_at" `
+		CreatedAt       time.Time  `db:"created_at"`
+		LastUsedAt      *time.Time `db:"last_used_at"`
+		ConsumedAt      *time.Time `db:"consumed_at"`
+		RevokedAt       *time.Time `db:"revoked_at"`
 	}
 )
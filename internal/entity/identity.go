@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+type (
+	OAuthStartRequest struct {
+		DeviceLabel string `query:"device_label" validate:"max=100"`
+	}
+
+	OAuthCallbackRequest struct {
+		Code  string `query:"code" validate:"required_without=Error"`
+		State string `query:"state" validate:"required"`
+		// Error is set by the provider when the user denied the request
+		Error string `query:"error"`
+	}
+
+	// OAuthFlow is the state of an authorization code flow between its start and the callback,
+	// it is kept by the browser in an encrypted cookie
+	OAuthFlow struct {
+		Provider     string `json:"provider"`
+		State        string `json:"state"`
+		Nonce        string `json:"nonce"`
+		CodeVerifier string `json:"code_verifier"`
+		DeviceLabel  string `json:"device_label"`
+		// LinkUserID is the signed in user the external account is linked to, 0 for a login
+		LinkUserID uint      `json:"link_user_id"`
+		ExpiredAt  time.Time `json:"expired_at"`
+	}
+
+	// UserIdentity links an account of an external provider to a user
+	UserIdentity struct {
+		ID        uint      `db:"id" json:"-"`
+		UserId    uint      `db:"user_id" json:"-"`
+		Provider  string    `db:"provider" json:"provider"`
+		Subject   string    `db:"subject" json:"-"`
+		Email     *string   `db:"email" json:"email"`
+		CreatedAt time.Time `db:"created_at" json:"created_at"`
+	}
+)
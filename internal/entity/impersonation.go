@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+type (
+	// ImpersonateRequest takes the reason of the impersonation, it is kept in the audit trail
+	ImpersonateRequest struct {
+		Reason string `json:"reason" validate:"required,max=255"`
+	}
+
+	// ImpersonationSession records an admin acting as a user, from the issued token until it
+	// is stopped or expires
+	ImpersonationSession struct {
+		ID            uint       `db:"id"`
+		ActorId       uint       `db:"actor_id"`
+		UserId        uint       `db:"user_id"`
+		AccessTokenId string     `db:"access_token_id"`
+		Reason        string     `db:"reason"`
+		IPAddress     string     `db:"ip_address"`
+		ExpiredAt     time.Time  `db:"expired_at"`
+		EndedAt       *time.Time `db:"ended_at"`
+		CreatedAt     time.Time  `db:"created_at"`
+	}
+)
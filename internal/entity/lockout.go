@@ -0,0 +1,6 @@
+package entity
+
+type ClearLockoutRequest struct {
+	Username  string `json:"username" query:"username" validate:"required_without=IPAddress"`
+	IPAddress string `json:"ip_address" query:"ip_address" validate:"required_without=Username,omitempty,ip"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+type (
+	MagicLinkRequest struct {
+		Email       string `json:"email" validate:"required,email"`
+		DeviceLabel string `json:"device_label" validate:"max=100"`
+	}
+
+	VerifyMagicLinkRequest struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	MagicLinkToken struct {
+		ID          uint       `db:"id"`
+		UserId      uint       `db:"user_id"`
+		Token       string     `db:"token"`
+		Nonce       string     `db:"nonce"`
+		DeviceLabel string     `db:"device_label"`
+		ExpiredAt   time.Time  `db:"expired_at"`
+		UsedAt      *time.Time `db:"used_at"`
+		CreatedAt   time.Time  `db:"created_at"`
+	}
+)
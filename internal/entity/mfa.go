@@ -0,0 +1,69 @@
+package entity
+
+import "time"
+
+// second factors a user can enable, only one at a time
+const (
+	MFAMethodTOTP  = "totp"
+	MFAMethodEmail = "email"
+)
+
+type (
+	ConfirmMFARequest struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	// DisableMFARequest takes a code of the enrolled method or an unused recovery code
+	DisableMFARequest struct {
+		Password string `json:"password" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	// MFALoginRequest completes a login with the challenge token returned by the password step,
+	// code is a code from the authenticator app or sent by email, or an unused recovery code
+	MFALoginRequest struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	// MFACodeRequest asks for a new email code for the challenge token of a login
+	MFACodeRequest struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+	}
+
+	MFAEnrollResponse struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+
+	// UserMFA is the second factor enrollment of a user, Secret is the TOTP secret encrypted
+	// with ENCRYPTION_KEY and is empty for the email method. EnabledAt stays nil until the
+	// enrollment is confirmed with a valid code
+	UserMFA struct {
+		UserId       uint       `db:"user_id"`
+		Method       string     `db:"method"`
+		Secret       string     `db:"secret"`
+		EnabledAt    *time.Time `db:"enabled_at"`
+		LastUsedStep *int64     `db:"last_used_step"`
+		CreatedAt    time.Time  `db:"created_at"`
+	}
+
+	// MFAEmailCode is a one-time code sent by email, Code is hashed
+	MFAEmailCode struct {
+		ID        uint       `db:"id"`
+		UserId    uint       `db:"user_id"`
+		Code      string     `db:"code"`
+		Attempts  uint       `db:"attempts"`
+		ExpiredAt time.Time  `db:"expired_at"`
+		UsedAt    *time.Time `db:"used_at"`
+		CreatedAt time.Time  `db:"created_at"`
+	}
+
+	MFARecoveryCode struct {
+		ID        uint       `db:"id"`
+		UserId    uint       `db:"user_id"`
+		Code      string     `db:"code"`
+		UsedAt    *time.Time `db:"used_at"`
+		CreatedAt time.Time  `db:"created_at"`
+	}
+)
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+type (
+	ForgotPasswordRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	ResetPasswordRequest struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	ChangePasswordRequest struct {
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required"`
+	}
+
+	PasswordResetToken struct {
+		ID        uint       `db:"id"`
+		UserId    uint       `db:"user_id"`
+		Token     string     `db:"token"`
+		ExpiredAt time.Time  `db:"expired_at"`
+		UsedAt    *time.Time `db:"used_at"`
+		CreatedAt time.Time  `db:"created_at"`
+	}
+)
@@ -0,0 +1,72 @@
+package entity
+
+import "time"
+
+// RoleAdmin is seeded by cmd/seed with every default permission
+const RoleAdmin = "admin"
+
+// permissions are named resource:action
+const (
+	PermissionUsersRead  = "users:read"
+	PermissionUsersWrite = "users:write"
+	// PermissionUsersImpersonate also protects its holders from being impersonated
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionRolesRead        = "roles:read"
+	PermissionRolesWrite       = "roles:write"
+	PermissionLockoutsWrite    = "lockouts:write"
+	PermissionAuditRead        = "audit:read"
+)
+
+// DefaultPermissions are the permissions the routes of this app check
+var DefaultPermissions = []string{
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionUsersImpersonate,
+	PermissionRolesRead,
+	PermissionRolesWrite,
+	PermissionLockoutsWrite,
+	PermissionAuditRead,
+}
+
+type (
+	CreateRoleRequest struct {
+		Name        string   `json:"name" validate:"required,max=50"`
+		Description string   `json:"description" validate:"max=255"`
+		Permissions []string `json:"permissions" validate:"dive,required"`
+	}
+
+	// SetRolePermissionsRequest replaces every permission of a role
+	SetRolePermissionsRequest struct {
+		Permissions []string `json:"permissions" validate:"required,dive,required"`
+	}
+
+	AssignRoleRequest struct {
+		Role string `json:"role" validate:"required"`
+	}
+
+	RoleResponse struct {
+		ID          uint     `json:"id"`
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	Role struct {
+		ID          uint      `db:"id"`
+		Name        string    `db:"name"`
+		Description string    `db:"description"`
+		CreatedAt   time.Time `db:"created_at"`
+	}
+
+	Permission struct {
+		ID        uint      `db:"id" json:"id"`
+		Name      string    `db:"name" json:"name"`
+		CreatedAt time.Time `db:"created_at" json:"-"`
+	}
+
+	// RolePermission is a permission granted to a role, with the name of the permission
+	RolePermission struct {
+		RoleId uint   `db:"role_id"`
+		Name   string `db:"name"`
+	}
+)
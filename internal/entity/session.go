@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+type SessionResponse struct {
+	ID          uint       `json:"id"`
+	DeviceLabel string     `json:"device_label"`
+	UserAgent   string     `json:"user_agent"`
+	IPAddress   string     `json:"ip_address"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiredAt   time.Time  `json:"expired_at"`
+	Current     bool       `json:"current"`
+}
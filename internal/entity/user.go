@@ -1,11 +1,17 @@
 package entity
 
+import "time"
+
 type User struct {
-	ID       uint   `db:"id" json:"id"`
-	Name     string `db:"name" json:"name" validate:"required,min=2,max=100"`
-	Email    string `db:"email" json:"email" validate:"email"`
-	Username string `db:"username" json:"username" validate:"required"`
-	Password string `db:"password" json:"password" validate:"required"`
+	ID              uint       `db:"id" json:"id"`
+	Name            string     `db:"name" json:"name" validate:"required,min=2,max=100"`
+	Email           string     `db:"email" json:"email" validate:"email"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
+	Username        string     `db:"username" json:"username" validate:"required"`
+	Password        string     `db:"password" json:"password" validate:"required"`
+	// Roles and Permissions of the authenticated user, set by the authentication middlewares
+	Roles       []string `db:"-" json:"-"`
+	Permissions []string `db:"-" json:"-"`
 }
 
 type UserResponse struct {
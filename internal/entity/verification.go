@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+type (
+	VerifyEmailRequest struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	ResendVerificationRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	EmailVerificationToken struct {
+		ID        uint       `db:"id"`
+		UserId    uint       `db:"user_id"`
+		Token     string     `db:"token"`
+		ExpiredAt time.Time  `db:"expired_at"`
+		UsedAt    *time.Time `db:"used_at"`
+		CreatedAt time.Time  `db:"created_at"`
+	}
+)
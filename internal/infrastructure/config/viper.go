@@ -38,3 +38,16 @@ func GetInt(key string) int {
 func GetUint(key string) uint {
 	return viperInstance.GetUint(key)
 }
+
+func GetBool(key string) bool {
+	return viperInstance.GetBool(key)
+}
+
+// Set overrides the value of key, mainly used to prepare configuration in tests
+func Set(key string, value any) {
+	if viperInstance == nil {
+		viperInstance = viper.New()
+	}
+
+	viperInstance.Set(key, value)
+}
@@ -0,0 +1,22 @@
+package mailer
+
+import (
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// LogMailer writes messages to the logger instead of sending them, meant for local development
+type LogMailer struct {
+	log *logrus.Logger
+}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{
+		log: logger.Get(),
+	}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	m.log.Infof("mail | to=%s | subject=%s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER, defaults to log which only writes messages to the logger
+func New() Mailer {
+	switch config.GetString("MAIL_DRIVER") {
+	case "smtp":
+		return NewSMTPMailer(
+			config.GetString("MAIL_HOST"),
+			config.GetInt("MAIL_PORT"),
+			config.GetString("MAIL_USERNAME"),
+			config.GetString("MAIL_PASSWORD"),
+			config.GetString("MAIL_FROM"),
+		)
+	default:
+		return NewLogMailer()
+	}
+}
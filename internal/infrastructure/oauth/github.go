@@ -0,0 +1,103 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+var defaultGitHubScopes = []string{"read:user", "user:email"}
+
+// GitHubProvider signs users in with GitHub, which speaks plain OAuth2 so the identity is read
+// from its REST API instead of an ID token
+type GitHubProvider struct {
+	cfg ProviderConfig
+
+	// endpoints, overridable for GitHub Enterprise and tests
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+func NewGitHubProvider(cfg ProviderConfig) *GitHubProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultGitHubScopes
+	}
+
+	return &GitHubProvider{
+		cfg:      cfg,
+		AuthURL:  "https://github.com/login/oauth/authorize",
+		TokenURL: "https://github.com/login/oauth/access_token",
+		APIURL:   "https://api.github.com",
+	}
+}
+
+func (p *GitHubProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *GitHubProvider) AuthCodeURL(req AuthRequest) (string, error) {
+	return authCodeURL(p.AuthURL, p.cfg, req, nil)
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code string, req AuthRequest) (Identity, error) {
+	token, err := exchangeCode(ctx, p.TokenURL, p.cfg, code, req)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(ctx, "/user", token.AccessToken, &user); err != nil {
+		return Identity{}, err
+	}
+	if user.ID == 0 {
+		return Identity{}, fmt.Errorf("github user has no id")
+	}
+
+	// the public email of the profile is not necessarily verified, the primary one of the
+	// email list tells
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, "/user/emails", token.AccessToken, &emails); err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Username: user.Login,
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *GitHubProvider) get(ctx context.Context, path, accessToken string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.APIURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build github request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	if err := doJSON(req, dest); err != nil {
+		return fmt.Errorf("failed to read github %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth"
+)
+
+func TestGitHubExchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "code-123" || r.PostForm.Get("code_verifier") != testRequest.CodeVerifier {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": 42, "login": "octocat", "name": "The Octocat"})
+	})
+	mux.HandleFunc("GET /user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"email": "octo@old.example.com", "primary": false, "verified": true},
+			{"email": "octo@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := oauth.NewGitHubProvider(oauth.ProviderConfig{Name: "github", ClientID: "client", RedirectURL: redirectURL})
+	provider.TokenURL = server.URL + "/login/oauth/access_token"
+	provider.APIURL = server.URL
+
+	identity, err := provider.Exchange(context.Background(), "code-123", testRequest)
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+
+	expected := oauth.Identity{Subject: "42", Email: "octo@example.com", EmailVerified: true, Name: "The Octocat", Username: "octocat"}
+	if identity != expected {
+		t.Fatalf("expected %+v, got %+v", expected, identity)
+	}
+
+	// errors are answered with 200 by GitHub
+	if _, err := provider.Exchange(context.Background(), "wrong-code", testRequest); err == nil {
+		t.Fatal("expected a rejected code to fail")
+	}
+}
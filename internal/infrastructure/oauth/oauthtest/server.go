@@ -0,0 +1,200 @@
+// Package oauthtest is a local stand-in OpenID Connect provider for tests
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "oauthtest"
+
+type grant struct {
+	identity      oauth.Identity
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+// Server issues codes for the identity a test approves and redeems them for ID tokens signed
+// with its own RS256 key, it checks the PKCE verifier, the redirect URI and the client
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	// Claims, when set, changes the claims of the next ID tokens before they are signed
+	Claims func(claims jwt.MapClaims)
+	// ForgeKey, when set, signs the next ID tokens instead of the published key
+	ForgeKey *rsa.PrivateKey
+
+	keyring *pkg.Keyring
+	key     *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+func NewServer() (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := pkg.NewKeyring(pkg.SigningKey{ID: keyID, Algorithm: pkg.AlgRS256, PrivateKey: key}, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		keyring:      keyring,
+		key:          key,
+		grants:       make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("POST /token", s.token)
+	s.Server = httptest.NewServer(mux)
+
+	return s, nil
+}
+
+// Issuer is the issuer to configure the provider with
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// Provider returns a provider of the server named name
+func (s *Server) Provider(name, redirectURL string) *oauth.OIDCProvider {
+	return oauth.NewOIDCProvider(oauth.ProviderConfig{
+		Name:         name,
+		ClientID:     s.ClientID,
+		ClientSecret: s.ClientSecret,
+		RedirectURL:  redirectURL,
+		Issuer:       s.Issuer(),
+	})
+}
+
+// Authorize plays the user approving the authorization request authURL as identity, it
+// returns the code and the state the provider redirects back with
+func (s *Server) Authorize(authURL string, identity oauth.Identity) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := u.Query()
+	if query.Get("client_id") != s.ClientID {
+		return "", "", fmt.Errorf("unknown client %q", query.Get("client_id"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", "", fmt.Errorf("missing S256 code challenge")
+	}
+
+	code = rand.Text()
+
+	s.mu.Lock()
+	s.grants[code] = grant{
+		identity:      identity,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	s.mu.Unlock()
+
+	return code, query.Get("state"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.keyring.JWKS())
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	if r.PostForm.Get("client_id") != s.ClientID || subtle.ConstantTimeCompare([]byte(r.PostForm.Get("client_secret")), []byte(s.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	// codes are single use
+	s.mu.Lock()
+	grant, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != grant.redirectURI ||
+		oauth.CodeChallenge(r.PostForm.Get("code_verifier")) != grant.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.Issuer(),
+		"aud":            s.ClientID,
+		"sub":            grant.identity.Subject,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.identity.Email,
+		"email_verified": grant.identity.EmailVerified,
+		"name":           grant.identity.Name,
+	}
+	if s.Claims != nil {
+		s.Claims(claims)
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+
+	key := s.key
+	if s.ForgeKey != nil {
+		key = s.ForgeKey
+	}
+
+	signed, err := idToken.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
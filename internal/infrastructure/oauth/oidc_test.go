@@ -0,0 +1,160 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth/oauthtest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "https://api.example.com/api/v1/oauth/test/callback"
+
+func newStandIn(t *testing.T) (*oauthtest.Server, *oauth.OIDCProvider) {
+	t.Helper()
+
+	server, err := oauthtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	return server, server.Provider("test", redirectURL)
+}
+
+var testRequest = oauth.AuthRequest{
+	State:        "state-123",
+	Nonce:        "nonce-123",
+	CodeVerifier: "verifier-0123456789-0123456789-0123456789-0123",
+}
+
+var alice = oauth.Identity{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+// runs the flow up to the code the provider redirects back with
+func authorize(t *testing.T, server *oauthtest.Server, provider *oauth.OIDCProvider) string {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(testRequest)
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+
+	code, state, err := server.Authorize(authURL, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != testRequest.State {
+		t.Fatalf("expected state to round trip, got %s", state)
+	}
+
+	return code
+}
+
+func TestOIDCAuthCodeURL(t *testing.T) {
+	server, provider := newStandIn(t)
+
+	authURL, err := provider.AuthCodeURL(testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(authURL)
+	query := u.Query()
+
+	if !strings.HasPrefix(authURL, server.URL+"/authorize?") {
+		t.Errorf("expected the discovered authorization endpoint, got %s", authURL)
+	}
+	if query.Get("nonce") != testRequest.Nonce || query.Get("redirect_uri") != redirectURL || query.Get("scope") != "openid email profile" {
+		t.Errorf("unexpected authorization request %v", query)
+	}
+	if query.Get("code_challenge") != oauth.CodeChallenge(testRequest.CodeVerifier) || query.Get("code_verifier") != "" {
+		t.Error("expected the challenge, never the verifier, in the authorization request")
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	server, provider := newStandIn(t)
+
+	identity, err := provider.Exchange(context.Background(), authorize(t, server, provider), testRequest)
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+
+	if identity != alice {
+		t.Fatalf("expected %+v, got %+v", alice, identity)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	server, provider := newStandIn(t)
+	code := authorize(t, server, provider)
+
+	req := testRequest
+	req.CodeVerifier = "another-verifier-0123456789-0123456789-01234"
+	if _, err := provider.Exchange(context.Background(), code, req); err == nil {
+		t.Fatal("expected a code redeemed with another verifier to be rejected")
+	}
+}
+
+func TestOIDCExchangeValidatesIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims func(claims jwt.MapClaims)
+		nonce  string
+	}{
+		{name: "nonce mismatch", nonce: "other-nonce"},
+		{name: "wrong audience", claims: func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{name: "wrong issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "missing subject", claims: func(c jwt.MapClaims) { delete(c, "sub") }},
+		{
+			name: "foreign authorized party",
+			claims: func(c jwt.MapClaims) {
+				c["aud"] = []string{"test-client", "other-client"}
+				c["azp"] = "other-client"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, provider := newStandIn(t)
+			server.Claims = tt.claims
+			code := authorize(t, server, provider)
+
+			req := testRequest
+			if tt.nonce != "" {
+				req.Nonce = tt.nonce
+			}
+
+			if _, err := provider.Exchange(context.Background(), code, req); err == nil {
+				t.Fatal("expected the id token to be rejected")
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeRejectsForgedSignature(t *testing.T) {
+	server, provider := newStandIn(t)
+
+	forgeKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ForgeKey = forgeKey
+
+	if _, err := provider.Exchange(context.Background(), authorize(t, server, provider), testRequest); err == nil {
+		t.Fatal("expected an id token not signed by a published key to be rejected")
+	}
+
+	server.ForgeKey = nil
+	if _, err := provider.Exchange(context.Background(), authorize(t, server, provider), testRequest); err != nil {
+		t.Fatalf("expected the provider to keep working, got %v", err)
+	}
+}
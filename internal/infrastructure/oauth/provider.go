@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+)
+
+// provider types configured by OAUTH_<NAME>_TYPE
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+var ErrExchange = errors.New("failed to exchange authorization code")
+
+// Identity is the user as asserted by a provider
+type Identity struct {
+	// Subject is the stable id of the user at the provider
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Username is a handle suggested by the provider, it may be empty
+	Username string
+}
+
+// AuthRequest holds the values of one authorization code flow, they are created when the flow
+// starts and must be presented again to exchange the code
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// Provider is an OAuth2 authorization server users can sign in with
+type Provider interface {
+	Name() string
+	// AuthCodeURL returns the URL the user is sent to, with a PKCE S256 code challenge
+	AuthCodeURL(req AuthRequest) (string, error)
+	// Exchange trades an authorization code for the identity of the user
+	Exchange(ctx context.Context, code string, req AuthRequest) (Identity, error)
+}
+
+type ProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// Issuer is the issuer of an OIDC provider, its endpoints are discovered from it
+	Issuer string
+}
+
+// NewProviders builds the providers listed in OAUTH_PROVIDERS (comma separated), each one is
+// configured by OAUTH_<NAME>_TYPE, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL, _SCOPES and,
+// for OIDC, _ISSUER
+func NewProviders() (map[string]Provider, error) {
+	providers := make(map[string]Provider)
+
+	for _, name := range strings.Split(config.GetString("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		cfg := ProviderConfig{
+			Name:         name,
+			ClientID:     config.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: config.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  config.GetString(prefix + "REDIRECT_URL"),
+			Issuer:       config.GetString(prefix + "ISSUER"),
+		}
+		if scopes := config.GetString(prefix + "SCOPES"); scopes != "" {
+			cfg.Scopes = strings.Split(scopes, ",")
+		}
+
+		if cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("oauth provider %s: client id and redirect url are required", name)
+		}
+
+		switch config.GetString(prefix + "TYPE") {
+		case TypeOIDC:
+			if cfg.Issuer == "" {
+				return nil, fmt.Errorf("oauth provider %s: issuer is required", name)
+			}
+			providers[name] = NewOIDCProvider(cfg)
+		case TypeGitHub:
+			providers[name] = NewGitHubProvider(cfg)
+		default:
+			return nil, fmt.Errorf("oauth provider %s: unsupported type %q", name, config.GetString(prefix+"TYPE"))
+		}
+	}
+
+	return providers, nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge of verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// authCodeURL adds the parameters of an authorization code request with PKCE to endpoint
+func authCodeURL(endpoint string, cfg ProviderConfig, req AuthRequest, extra url.Values) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", cfg.RedirectURL)
+	query.Set("scope", strings.Join(cfg.Scopes, " "))
+	query.Set("state", req.State)
+	query.Set("code_challenge", CodeChallenge(req.CodeVerifier))
+	query.Set("code_challenge_method", "S256")
+	for key, values := range extra {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// exchangeCode redeems code at the token endpoint, the client authenticates with
+// client_secret_post
+func exchangeCode(ctx context.Context, endpoint string, cfg ProviderConfig, code string, req AuthRequest) (tokenResponse, error) {
+	var result tokenResponse
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"client_id":     {cfg.ClientID},
+		"client_secret": {cfg.ClientSecret},
+		"code_verifier": {req.CodeVerifier},
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return result, fmt.Errorf("failed to build token request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+
+	if err := doJSON(httpReq, &result); err != nil {
+		return result, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+
+	// some servers answer errors with 200
+	if result.Error != "" {
+		return result, fmt.Errorf("%w: %s", ErrExchange, result.Error)
+	}
+	if result.AccessToken == "" {
+		return result, fmt.Errorf("%w: no access token", ErrExchange)
+	}
+
+	return result, nil
+}
+
+// doJSON sends req and decodes a 2xx JSON response into dest
+func doJSON(req *http.Request, dest any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s answered %d", req.Method, req.URL.Redacted(), resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, dest); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", req.URL.Redacted(), err)
+	}
+
+	return nil
+}
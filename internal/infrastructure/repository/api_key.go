@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type APIKeyRepository interface {
+	GetByHash(keyHash string, db *sqlx.DB) (result entity.APIKey, err error)
+	GetActiveByUserId(userId uint, db *sqlx.DB) (result []entity.APIKey, err error)
+	Insert(data entity.APIKey, tx *sqlx.Tx) (result uint, err error)
+	Revoke(id, userId uint, tx *sqlx.Tx) (revoked bool, err error)
+	UpdateLastUsed(id uint, usedAt time.Time, tx *sqlx.Tx) error
+}
+
+type apiKeyRepo struct {
+}
+
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepo{}
+}
+
+var apiKeyColumns = []any{
+	goqu.I("id"),
+	goqu.I("user_id"),
+	goqu.I("name"),
+	goqu.I("prefix"),
+	goqu.I("key_hash"),
+	goqu.I("scopes"),
+	goqu.I("expired_at"),
+	goqu.I("last_used_at"),
+	goqu.I("revoked_at"),
+	goqu.I("created_at"),
+}
+
+func (r *apiKeyRepo) GetByHash(keyHash string, db *sqlx.DB) (result entity.APIKey, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("api_keys").
+		Select(apiKeyColumns...).
+		Where(goqu.I("key_hash").Eq(keyHash))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// returns the keys of the user that are not revoked, expired ones included, newest first
+func (r *apiKeyRepo) GetActiveByUserId(userId uint, db *sqlx.DB) (result []entity.APIKey, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("api_keys").
+		Select(apiKeyColumns...).
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("revoked_at").IsNull(),
+		).
+		Order(goqu.I("created_at").Desc(), goqu.I("id").Desc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *apiKeyRepo) Insert(data entity.APIKey, tx *sqlx.Tx) (result uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("api_keys").Rows(data)
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return result, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return result, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return uint(id), nil
+}
+
+// revokes the key when it belongs to the user and is not revoked yet
+func (r *apiKeyRepo) Revoke(id, userId uint, tx *sqlx.Tx) (revoked bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("api_keys").
+		Set(goqu.Record{"revoked_at": time.Now()}).
+		Where(
+			goqu.I("id").Eq(id),
+			goqu.I("user_id").Eq(userId),
+			goqu.I("revoked_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r *apiKeyRepo) UpdateLastUsed(id uint, usedAt time.Time, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("api_keys").
+		Set(goqu.Record{"last_used_at": usedAt}).
+		Where(goqu.I("id").Eq(id))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
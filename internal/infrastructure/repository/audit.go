@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepository interface {
+	Insert(data entity.AuditEvent, tx *sqlx.Tx) (result uint, err error)
+	List(filter entity.AuditEventFilter, pagination pkg.PaginationRequest, db *sqlx.DB) (result []entity.AuditEvent, err error)
+	Count(filter entity.AuditEventFilter, db *sqlx.DB) (result uint, err error)
+	ListAfter(id, limit uint, db *sqlx.DB) (result []entity.AuditEvent, err error)
+	GetChainHead(tx *sqlx.Tx) (result entity.AuditChainHead, err error)
+	UpdateChainHead(data entity.AuditChainHead, tx *sqlx.Tx) error
+	InsertCheckpoint(data entity.AuditCheckpoint, tx *sqlx.Tx) error
+	GetLastCheckpoint(db *sqlx.DB) (result entity.AuditCheckpoint, err error)
+	ListCheckpoints(db *sqlx.DB) (result []entity.AuditCheckpoint, err error)
+}
+
+type auditRepo struct {
+}
+
+func NewAuditRepository() AuditRepository {
+	return &auditRepo{}
+}
+
+// auditColumns are the selected columns of an audit event
+var auditColumns = []any{
+	goqu.I("id"),
+	goqu.I("event"),
+	goqu.I("user_id"),
+	goqu.I("actor_id"),
+	goqu.I("username"),
+	goqu.I("reason"),
+	goqu.I("details"),
+	goqu.I("ip_address"),
+	goqu.I("user_agent"),
+	goqu.I("request_id"),
+	goqu.I("created_at"),
+	goqu.I("prev_hash"),
+	goqu.I("hash"),
+}
+
+func (r *auditRepo) Insert(data entity.AuditEvent, tx *sqlx.Tx) (result uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("audit_events").Rows(data)
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return result, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return result, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return uint(id), nil
+}
+
+// auditConditions turns the set fields of filter into where conditions
+func auditConditions(filter entity.AuditEventFilter) []exp.Expression {
+	var conditions []exp.Expression
+
+	if filter.UserId != nil {
+		conditions = append(conditions, goqu.I("user_id").Eq(*filter.UserId))
+	}
+
+	if filter.ActorId != nil {
+		conditions = append(conditions, goqu.I("actor_id").Eq(*filter.ActorId))
+	}
+
+	if filter.Event != "" {
+		conditions = append(conditions, goqu.I("event").Eq(filter.Event))
+	}
+
+	if filter.IPAddress != "" {
+		conditions = append(conditions, goqu.I("ip_address").Eq(filter.IPAddress))
+	}
+
+	if filter.RequestId != "" {
+		conditions = append(conditions, goqu.I("request_id").Eq(filter.RequestId))
+	}
+
+	if filter.From != nil {
+		conditions = append(conditions, goqu.I("created_at").Gte(*filter.From))
+	}
+
+	if filter.To != nil {
+		conditions = append(conditions, goqu.I("created_at").Lt(*filter.To))
+	}
+
+	return conditions
+}
+
+func (r *auditRepo) List(filter entity.AuditEventFilter, pagination pkg.PaginationRequest, db *sqlx.DB) (result []entity.AuditEvent, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("audit_events").
+		Select(auditColumns...).
+		Where(auditConditions(filter)...)
+
+	// newest first unless sorted otherwise, id breaks ties of events of the same second
+	dataset = pkg.QueryWithPagination(dataset, pagination)
+	if pagination.Sort == nil || *pagination.Sort == "" {
+		dataset = dataset.Order(goqu.I("created_at").Desc(), goqu.I("id").Desc())
+	}
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *auditRepo) Count(filter entity.AuditEventFilter, db *sqlx.DB) (result uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("audit_events").
+		Select(goqu.COUNT("*")).
+		Where(auditConditions(filter)...)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// ListAfter returns up to limit events following the event id in the order they were recorded
+func (r *auditRepo) ListAfter(id, limit uint, db *sqlx.DB) (result []entity.AuditEvent, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("audit_events").
+		Select(auditColumns...).
+		Where(goqu.I("id").Gt(id)).
+		Order(goqu.I("id").Asc()).
+		Limit(limit)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// GetChainHead locks the head of the chain until tx ends, so events are appended one at a time
+func (r *auditRepo) GetChainHead(tx *sqlx.Tx) (result entity.AuditChainHead, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("audit_chain_head").
+		Select(goqu.I("last_event_id"), goqu.I("hash")).
+		Where(goqu.I("id").Eq(1)).
+		ForUpdate(exp.Wait)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = tx.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *auditRepo) UpdateChainHead(data entity.AuditChainHead, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("audit_chain_head").
+		Set(goqu.Record{
+			"last_event_id": data.LastEventId,
+			"hash":          data.Hash,
+		}).
+		Where(goqu.I("id").Eq(1))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(query, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
+
+func (r *auditRepo) InsertCheckpoint(data entity.AuditCheckpoint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("audit_checkpoints").Rows(data)
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(query, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	return nil
+}
+
+func (r *auditRepo) GetLastCheckpoint(db *sqlx.DB) (result entity.AuditCheckpoint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("audit_checkpoints").
+		Select(
+			goqu.I("id"),
+			goqu.I("last_event_id"),
+			goqu.I("hash"),
+			goqu.I("signature"),
+			goqu.I("created_at"),
+		).
+		Order(goqu.I("id").Desc()).
+		Limit(1)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *auditRepo) ListCheckpoints(db *sqlx.DB) (result []entity.AuditCheckpoint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("audit_checkpoints").
+		Select(
+			goqu.I("id"),
+			goqu.I("last_event_id"),
+			goqu.I("hash"),
+			goqu.I("signature"),
+			goqu.I("created_at"),
+		).
+		Order(goqu.I("id").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
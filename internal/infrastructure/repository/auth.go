@@ -12,8 +12,16 @@ import (
 
 type AuthRepository interface {
 	InsertRefreshToken(data entity.RefreshToken, db *sqlx.Tx) (result uint, err error)
-	GetValidRefreshToken(token string, userId uint, db *sqlx.DB) (result entity.RefreshToken, err error)
-	DeleteRefreshTokenByUserId(userId uint, tx *sqlx.Tx) error
+	GetRefreshTokenByToken(token string, db *sqlx.DB) (result entity.RefreshToken, err error)
+	GetActiveRefreshTokenById(id, userId uint, db *sqlx.DB) (result entity.RefreshToken, err error)
+	GetActiveRefreshTokensByUserId(userId uint, db *sqlx.DB) (result []entity.RefreshToken, err error)
+	ConsumeRefreshToken(id uint, tx *sqlx.Tx) (consumed bool, err error)
+	GetLiveAccessTokensByFamily(familyId string, db *sqlx.DB) (result []entity.RefreshToken, err error)
+	GetLiveAccessTokensByUserId(userId uint, db *sqlx.DB) (result []entity.RefreshToken, err error)
+	RevokeRefreshTokenFamily(familyId string, tx *sqlx.Tx) error
+	RevokeRefreshTokensByUserId(userId uint, tx *sqlx.Tx) error
+	RevokeRefreshTokensByUserIdExceptFamily(userId uint, familyId string, tx *sqlx.Tx) error
+	DeleteExpiredRefreshTokens(before time.Time, limit uint, tx *sqlx.Tx) (deleted int64, err error)
 }
 
 type authRepo struct {
@@ -23,6 +31,24 @@ func NewAuthRepository() AuthRepository {
 	return &authRepo{}
 }
 
+var refreshTokenColumns = []any{
+	goqu.I("id"),
+	goqu.I("user_id"),
+	goqu.I("token"),
+	goqu.I("family_id"),
+	goqu.I("parent_id"),
+	goqu.I("access_token_id"),
+	goqu.I("access_expired_at"),
+	goqu.I("device_label"),
+	goqu.I("user_agent"),
+	goqu.I("ip_address"),
+	goqu.I("expired_at"),
+	goqu.I("created_at"),
+	goqu.I("last_used_at"),
+	goqu.I("consumed_at"),
+	goqu.I("revoked_at"),
+}
+
 func (r *authRepo) InsertRefreshToken(data entity.RefreshToken, tx *sqlx.Tx) (result uint, err error) {
 	dialect := pkg.GetDialect()
 
@@ -45,20 +71,38 @@ func (r *authRepo) InsertRefreshToken(data entity.RefreshToken, tx *sqlx.Tx) (re
 	return uint(id), nil
 }
 
-func (r *authRepo) GetValidRefreshToken(token string, userId uint, db *sqlx.DB) (result entity.RefreshToken, err error) {
+// returns the token regardless of its state so that replays of consumed tokens can be detected
+func (r *authRepo) GetRefreshTokenByToken(token string, db *sqlx.DB) (result entity.RefreshToken, err error) {
 	dialect := pkg.GetDialect()
 
 	dataset := dialect.From("refresh_tokens").
-		Select(
-			goqu.I("id"),
-			goqu.I("user_id"),
-			goqu.I("token"),
-			goqu.I("expired_at"),
-		).
+		Select(refreshTokenColumns...).
+		Where(goqu.I("token").Eq(token))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *authRepo) GetActiveRefreshTokenById(id, userId uint, db *sqlx.DB) (result entity.RefreshToken, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("refresh_tokens").
+		Select(refreshTokenColumns...).
 		Where(
-			goqu.I("token").Eq(token),
+			goqu.I("id").Eq(id),
 			goqu.I("user_id").Eq(userId),
 			goqu.I("expired_at").Gt(time.Now()),
+			goqu.I("consumed_at").IsNull(),
+			goqu.I("revoked_at").IsNull(),
 		)
 
 	query, val, err := dataset.ToSQL()
@@ -74,10 +118,154 @@ func (r *authRepo) GetValidRefreshToken(token string, userId uint, db *sqlx.DB)
 	return
 }
 
-func (r *authRepo) DeleteRefreshTokenByUserId(userId uint, tx *sqlx.Tx) error {
+func (r *authRepo) GetActiveRefreshTokensByUserId(userId uint, db *sqlx.DB) (result []entity.RefreshToken, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("refresh_tokens").
+		Select(refreshTokenColumns...).
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("expired_at").Gt(time.Now()),
+			goqu.I("consumed_at").IsNull(),
+			goqu.I("revoked_at").IsNull(),
+		).
+		Order(goqu.I("created_at").Desc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// marks the token as consumed, consumed is false when the token was already consumed or revoked
+func (r *authRepo) ConsumeRefreshToken(id uint, tx *sqlx.Tx) (consumed bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("refresh_tokens").
+		Set(goqu.Record{"consumed_at": time.Now()}).
+		Where(
+			goqu.I("id").Eq(id),
+			goqu.I("consumed_at").IsNull(),
+			goqu.I("revoked_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// returns the tokens of the family whose access token has not expired yet
+func (r *authRepo) GetLiveAccessTokensByFamily(familyId string, db *sqlx.DB) (result []entity.RefreshToken, err error) {
+	return r.getLiveAccessTokens(goqu.I("family_id").Eq(familyId), db)
+}
+
+// returns the tokens of the user whose access token has not expired yet
+func (r *authRepo) GetLiveAccessTokensByUserId(userId uint, db *sqlx.DB) (result []entity.RefreshToken, err error) {
+	return r.getLiveAccessTokens(goqu.I("user_id").Eq(userId), db)
+}
+
+func (r *authRepo) getLiveAccessTokens(filter goqu.Expression, db *sqlx.DB) (result []entity.RefreshToken, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("refresh_tokens").
+		Select(refreshTokenColumns...).
+		Where(
+			filter,
+			goqu.I("access_token_id").Neq(""),
+			goqu.I("access_expired_at").Gt(time.Now()),
+		)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *authRepo) RevokeRefreshTokenFamily(familyId string, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("refresh_tokens").
+		Set(goqu.Record{"revoked_at": time.Now()}).
+		Where(
+			goqu.I("family_id").Eq(familyId),
+			goqu.I("revoked_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
+
+func (r *authRepo) RevokeRefreshTokensByUserId(userId uint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("refresh_tokens").
+		Set(goqu.Record{"revoked_at": time.Now()}).
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("revoked_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
+
+// revokes every session of the user but the one identified by familyId
+func (r *authRepo) RevokeRefreshTokensByUserIdExceptFamily(userId uint, familyId string, tx *sqlx.Tx) error {
 	dialect := pkg.GetDialect()
 
-	dataset := dialect.Delete("refresh_tokens").Where(goqu.I("user_id").Eq(userId))
+	dataset := dialect.Update("refresh_tokens").
+		Set(goqu.Record{"revoked_at": time.Now()}).
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("family_id").Neq(familyId),
+			goqu.I("revoked_at").IsNull(),
+		)
+
 	sql, val, err := dataset.ToSQL()
 	if err != nil {
 		return fmt.Errorf("failed to build SQL query: %w", err)
@@ -85,8 +273,30 @@ func (r *authRepo) DeleteRefreshTokenByUserId(userId uint, tx *sqlx.Tx) error {
 
 	_, err = tx.Exec(sql, val...)
 	if err != nil {
-		return fmt.Errorf("failed to execute insert: %w", err)
+		return fmt.Errorf("failed to execute update: %w", err)
 	}
 
 	return nil
 }
+
+// deletes up to limit refresh tokens that expired before the given time
+func (r *authRepo) DeleteExpiredRefreshTokens(before time.Time, limit uint, tx *sqlx.Tx) (deleted int64, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete("refresh_tokens").
+		Where(goqu.I("expired_at").Lt(before)).
+		Order(goqu.I("expired_at").Asc()).
+		Limit(limit)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return deleted, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const mysqlErrDuplicateEntry = 1062
+
+// duplicateIndexError returns the domain error of the unique index a duplicate key error was
+// raised by, nil when err is not a duplicate of one of the indexes
+func duplicateIndexError(err error, indexes map[string]error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDuplicateEntry {
+		return nil
+	}
+
+	// the message ends with "for key 'uq_index'", prefixed by the table name since MySQL 8.0.19
+	for index, domainErr := range indexes {
+		if strings.HasSuffix(mysqlErr.Message, "'"+index+"'") || strings.HasSuffix(mysqlErr.Message, "."+index+"'") {
+			return domainErr
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+// ExpiringTables are the tables whose rows are dead once expired_at has passed, each has an
+// index on expired_at. The refresh tokens are purged by AuthRepository
+var ExpiringTables = []string{
+	"revoked_access_tokens",
+	"login_attempts",
+	"rate_limits",
+	"password_reset_tokens",
+	"email_verification_tokens",
+	"magic_link_tokens",
+	"mfa_email_codes",
+}
+
+// ExpiredRowRepository deletes the expired rows of ExpiringTables
+type ExpiredRowRepository interface {
+	DeleteExpired(table string, before time.Time, limit uint, tx *sqlx.Tx) (deleted int64, err error)
+}
+
+type expiredRowRepo struct {
+}
+
+func NewExpiredRowRepository() ExpiredRowRepository {
+	return &expiredRowRepo{}
+}
+
+// deletes up to limit rows of table that expired before the given time
+func (r *expiredRowRepo) DeleteExpired(table string, before time.Time, limit uint, tx *sqlx.Tx) (deleted int64, err error) {
+	if !slices.Contains(ExpiringTables, table) {
+		return deleted, fmt.Errorf("table %s has no expiring rows", table)
+	}
+
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete(table).
+		Where(goqu.I("expired_at").Lt(before)).
+		Order(goqu.I("expired_at").Asc()).
+		Limit(limit)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return deleted, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	return res.RowsAffected()
+}
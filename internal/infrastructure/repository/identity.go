@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type IdentityRepository interface {
+	GetByProviderSubject(provider, subject string, db *sqlx.DB) (result entity.UserIdentity, err error)
+	GetByUserId(userId uint, db *sqlx.DB) (result []entity.UserIdentity, err error)
+	Insert(data entity.UserIdentity, tx *sqlx.Tx) (result uint, err error)
+	Delete(userId uint, provider string, tx *sqlx.Tx) (deleted bool, err error)
+}
+
+type identityRepo struct {
+}
+
+func NewIdentityRepository() IdentityRepository {
+	return &identityRepo{}
+}
+
+var identityColumns = []any{
+	goqu.I("id"),
+	goqu.I("user_id"),
+	goqu.I("provider"),
+	goqu.I("subject"),
+	goqu.I("email"),
+	goqu.I("created_at"),
+}
+
+var identityUniqueIndexes = map[string]error{
+	"uq_user_identities_provider_subject": pkg.ErrIdentityLinked,
+	"uq_user_identities_user_id_provider": pkg.ErrProviderLinked,
+}
+
+func (r *identityRepo) GetByProviderSubject(provider, subject string, db *sqlx.DB) (result entity.UserIdentity, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("user_identities").
+		Select(identityColumns...).
+		Where(
+			goqu.I("provider").Eq(provider),
+			goqu.I("subject").Eq(subject),
+		)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *identityRepo) GetByUserId(userId uint, db *sqlx.DB) (result []entity.UserIdentity, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("user_identities").
+		Select(identityColumns...).
+		Where(goqu.I("user_id").Eq(userId)).
+		Order(goqu.I("provider").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *identityRepo) Insert(data entity.UserIdentity, tx *sqlx.Tx) (result uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("user_identities").Rows(data)
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		if domainErr := duplicateIndexError(err, identityUniqueIndexes); domainErr != nil {
+			return result, domainErr
+		}
+		return result, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return result, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return uint(id), nil
+}
+
+func (r *identityRepo) Delete(userId uint, provider string, tx *sqlx.Tx) (deleted bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete("user_identities").
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("provider").Eq(provider),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
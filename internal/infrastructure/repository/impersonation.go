@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type ImpersonationRepository interface {
+	Insert(data entity.ImpersonationSession, tx *sqlx.Tx) (result uint, err error)
+	GetByAccessTokenId(accessTokenId string, db *sqlx.DB) (result entity.ImpersonationSession, err error)
+	End(accessTokenId string, endedAt time.Time, tx *sqlx.Tx) (ended bool, err error)
+}
+
+type impersonationRepo struct {
+}
+
+func NewImpersonationRepository() ImpersonationRepository {
+	return &impersonationRepo{}
+}
+
+func (r *impersonationRepo) Insert(data entity.ImpersonationSession, tx *sqlx.Tx) (result uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("impersonation_sessions").Rows(data)
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return result, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return result, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return uint(id), nil
+}
+
+var impersonationColumns = []any{
+	goqu.I("id"),
+	goqu.I("actor_id"),
+	goqu.I("user_id"),
+	goqu.I("access_token_id"),
+	goqu.I("reason"),
+	goqu.I("ip_address"),
+	goqu.I("expired_at"),
+	goqu.I("ended_at"),
+	goqu.I("created_at"),
+}
+
+func (r *impersonationRepo) GetByAccessTokenId(accessTokenId string, db *sqlx.DB) (result entity.ImpersonationSession, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("impersonation_sessions").
+		Select(impersonationColumns...).
+		Where(goqu.I("access_token_id").Eq(accessTokenId))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// ends the session of the token when it has not ended yet
+func (r *impersonationRepo) End(accessTokenId string, endedAt time.Time, tx *sqlx.Tx) (ended bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("impersonation_sessions").
+		Set(goqu.Record{"ended_at": endedAt}).
+		Where(
+			goqu.I("access_token_id").Eq(accessTokenId),
+			goqu.I("ended_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
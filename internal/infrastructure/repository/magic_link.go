@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/jmoiron/sqlx"
+)
+
+type MagicLinkRepository interface {
+	SingleUseTokenRepository[entity.MagicLinkToken]
+	GetValidByToken(token string, db *sqlx.DB) (result entity.MagicLinkToken, err error)
+}
+
+type magicLinkRepo struct {
+	singleUseTokenRepo[entity.MagicLinkToken]
+}
+
+func NewMagicLinkRepository() MagicLinkRepository {
+	return &magicLinkRepo{
+		singleUseTokenRepo[entity.MagicLinkToken]{"magic_link_tokens", magicLinkColumns},
+	}
+}
+
+var magicLinkColumns = []any{
+	goqu.I("id"),
+	goqu.I("user_id"),
+	goqu.I("token"),
+	goqu.I("nonce"),
+	goqu.I("device_label"),
+	goqu.I("expired_at"),
+	goqu.I("used_at"),
+	goqu.I("created_at"),
+}
+
+func (r *magicLinkRepo) GetValidByToken(token string, db *sqlx.DB) (result entity.MagicLinkToken, err error) {
+	return r.getValid(goqu.I("token").Eq(token), db)
+}
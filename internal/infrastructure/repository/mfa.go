@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type MFARepository interface {
+	GetByUserId(userId uint, db *sqlx.DB) (result entity.UserMFA, err error)
+	Upsert(data entity.UserMFA, tx *sqlx.Tx) error
+	Enable(userId uint, tx *sqlx.Tx) error
+	UseStep(userId uint, step int64, tx *sqlx.Tx) (used bool, err error)
+	Delete(userId uint, tx *sqlx.Tx) error
+	InsertRecoveryCodes(data []entity.MFARecoveryCode, tx *sqlx.Tx) error
+	UseRecoveryCode(userId uint, code string, tx *sqlx.Tx) (used bool, err error)
+	DeleteRecoveryCodesByUserId(userId uint, tx *sqlx.Tx) error
+}
+
+type mfaRepo struct {
+}
+
+func NewMFARepository() MFARepository {
+	return &mfaRepo{}
+}
+
+func (r *mfaRepo) GetByUserId(userId uint, db *sqlx.DB) (result entity.UserMFA, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("user_mfa").
+		Select(
+			goqu.I("user_id"),
+			goqu.I("method"),
+			goqu.I("secret"),
+			goqu.I("enabled_at"),
+			goqu.I("last_used_step"),
+			goqu.I("created_at"),
+		).
+		Where(goqu.I("user_id").Eq(userId))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// inserts the enrollment or replaces a pending one with a new method and secret
+func (r *mfaRepo) Upsert(data entity.UserMFA, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("user_mfa").
+		Rows(data).
+		OnConflict(goqu.DoUpdate("user_id", goqu.Record{
+			"method":         data.Method,
+			"secret":         data.Secret,
+			"enabled_at":     nil,
+			"last_used_step": nil,
+			"created_at":     data.CreatedAt,
+		}))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mfaRepo) Enable(userId uint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("user_mfa").
+		Set(goqu.Record{"enabled_at": time.Now()}).
+		Where(goqu.I("user_id").Eq(userId))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
+
+// records step as the last accepted TOTP step, used is false when a code of the same or a
+// later step was already accepted so a code cannot be replayed
+func (r *mfaRepo) UseStep(userId uint, step int64, tx *sqlx.Tx) (used bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("user_mfa").
+		Set(goqu.Record{"last_used_step": step}).
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.Or(
+				goqu.I("last_used_step").IsNull(),
+				goqu.I("last_used_step").Lt(step),
+			),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r *mfaRepo) Delete(userId uint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete("user_mfa").Where(goqu.I("user_id").Eq(userId))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mfaRepo) InsertRecoveryCodes(data []entity.MFARecoveryCode, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("mfa_recovery_codes").Rows(data)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	return nil
+}
+
+// marks the recovery code as used, used is false when the code is unknown or already used
+func (r *mfaRepo) UseRecoveryCode(userId uint, code string, tx *sqlx.Tx) (used bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("mfa_recovery_codes").
+		Set(goqu.Record{"used_at": time.Now()}).
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("code").Eq(code),
+			goqu.I("used_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r *mfaRepo) DeleteRecoveryCodesByUserId(userId uint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete("mfa_recovery_codes").Where(goqu.I("user_id").Eq(userId))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type MFAEmailCodeRepository interface {
+	SingleUseTokenRepository[entity.MFAEmailCode]
+	GetValidByUserId(userId uint, db *sqlx.DB) (result entity.MFAEmailCode, err error)
+	AddAttempt(id, maxAttempts uint, db *sqlx.DB) (added bool, err error)
+}
+
+type mfaEmailCodeRepo struct {
+	singleUseTokenRepo[entity.MFAEmailCode]
+}
+
+func NewMFAEmailCodeRepository() MFAEmailCodeRepository {
+	return &mfaEmailCodeRepo{
+		singleUseTokenRepo[entity.MFAEmailCode]{"mfa_email_codes", mfaEmailCodeColumns},
+	}
+}
+
+var mfaEmailCodeColumns = []any{
+	goqu.I("id"),
+	goqu.I("user_id"),
+	goqu.I("code"),
+	goqu.I("attempts"),
+	goqu.I("expired_at"),
+	goqu.I("used_at"),
+	goqu.I("created_at"),
+}
+
+// returns the latest unused and unexpired code of the user
+func (r *mfaEmailCodeRepo) GetValidByUserId(userId uint, db *sqlx.DB) (result entity.MFAEmailCode, err error) {
+	return r.getValid(goqu.I("user_id").Eq(userId), db)
+}
+
+// counts a guess against the code, added is false when it already had maxAttempts guesses.
+// It runs outside the transaction of the check so a rolled back check still counts
+func (r *mfaEmailCodeRepo) AddAttempt(id, maxAttempts uint, db *sqlx.DB) (added bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("mfa_email_codes").
+		Set(goqu.Record{"attempts": goqu.L("attempts + 1")}).
+		Where(
+			goqu.I("id").Eq(id),
+			goqu.I("attempts").Lt(maxAttempts),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := db.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/jmoiron/sqlx"
+)
+
+type PasswordResetRepository interface {
+	SingleUseTokenRepository[entity.PasswordResetToken]
+	GetValidByToken(token string, db *sqlx.DB) (result entity.PasswordResetToken, err error)
+}
+
+type passwordResetRepo struct {
+	singleUseTokenRepo[entity.PasswordResetToken]
+}
+
+func NewPasswordResetRepository() PasswordResetRepository {
+	return &passwordResetRepo{
+		singleUseTokenRepo[entity.PasswordResetToken]{"password_reset_tokens", passwordResetColumns},
+	}
+}
+
+var passwordResetColumns = []any{
+	goqu.I("id"),
+	goqu.I("user_id"),
+	goqu.I("token"),
+	goqu.I("expired_at"),
+	goqu.I("used_at"),
+	goqu.I("created_at"),
+}
+
+func (r *passwordResetRepo) GetValidByToken(token string, db *sqlx.DB) (result entity.PasswordResetToken, err error) {
+	return r.getValid(goqu.I("token").Eq(token), db)
+}
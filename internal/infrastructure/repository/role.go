@@ -0,0 +1,413 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type RoleRepository interface {
+	GetAll(db *sqlx.DB) (result []entity.Role, err error)
+	GetById(id uint, db *sqlx.DB) (result entity.Role, err error)
+	GetByName(name string, db *sqlx.DB) (result entity.Role, err error)
+	Insert(data entity.Role, tx *sqlx.Tx) (result uint, err error)
+	Delete(id uint, tx *sqlx.Tx) (deleted bool, err error)
+	GetPermissions(db *sqlx.DB) (result []entity.Permission, err error)
+	GetPermissionsByNames(names []string, db *sqlx.DB) (result []entity.Permission, err error)
+	InsertPermissions(names []string, tx *sqlx.Tx) error
+	GetRolePermissions(roleIds []uint, db *sqlx.DB) (result []entity.RolePermission, err error)
+	SetRolePermissions(roleId uint, permissionIds []uint, tx *sqlx.Tx) error
+	GetUserRoles(userId uint, db *sqlx.DB) (result []entity.Role, err error)
+	GetUserPermissions(userId uint, db *sqlx.DB) (result []string, err error)
+	GetRoleUserIds(roleId uint, db *sqlx.DB) (result []uint, err error)
+	AssignUserRole(userId, roleId uint, tx *sqlx.Tx) (assigned bool, err error)
+	RemoveUserRole(userId, roleId uint, tx *sqlx.Tx) (removed bool, err error)
+}
+
+type roleRepo struct {
+}
+
+func NewRoleRepository() RoleRepository {
+	return &roleRepo{}
+}
+
+var roleColumns = []any{
+	goqu.I("roles.id"),
+	goqu.I("roles.name"),
+	goqu.I("roles.description"),
+	goqu.I("roles.created_at"),
+}
+
+var roleUniqueIndexes = map[string]error{
+	"uq_roles_name": pkg.ErrRoleExists,
+}
+
+func (r *roleRepo) GetAll(db *sqlx.DB) (result []entity.Role, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("roles").
+		Select(roleColumns...).
+		Order(goqu.I("roles.name").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *roleRepo) GetById(id uint, db *sqlx.DB) (result entity.Role, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("roles").
+		Select(roleColumns...).
+		Where(goqu.I("roles.id").Eq(id))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *roleRepo) GetByName(name string, db *sqlx.DB) (result entity.Role, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("roles").
+		Select(roleColumns...).
+		Where(goqu.I("roles.name").Eq(name))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *roleRepo) Insert(data entity.Role, tx *sqlx.Tx) (result uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("roles").Rows(data)
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		if domainErr := duplicateIndexError(err, roleUniqueIndexes); domainErr != nil {
+			return result, domainErr
+		}
+		return result, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return result, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return uint(id), nil
+}
+
+// deletes the role, its permissions and assignments are removed by cascade
+func (r *roleRepo) Delete(id uint, tx *sqlx.Tx) (deleted bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete("roles").Where(goqu.I("id").Eq(id))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r *roleRepo) GetPermissions(db *sqlx.DB) (result []entity.Permission, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("permissions").
+		Select(goqu.I("id"), goqu.I("name"), goqu.I("created_at")).
+		Order(goqu.I("name").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *roleRepo) GetPermissionsByNames(names []string, db *sqlx.DB) (result []entity.Permission, err error) {
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("permissions").
+		Select(goqu.I("id"), goqu.I("name"), goqu.I("created_at")).
+		Where(goqu.I("name").In(names))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// inserts the permissions that do not exist yet
+func (r *roleRepo) InsertPermissions(names []string, tx *sqlx.Tx) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	dialect := pkg.GetDialect()
+
+	rows := make([]any, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, goqu.Record{"name": name})
+	}
+
+	dataset := dialect.Insert("permissions").
+		Rows(rows...).
+		OnConflict(goqu.DoNothing())
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	return nil
+}
+
+func (r *roleRepo) GetRolePermissions(roleIds []uint, db *sqlx.DB) (result []entity.RolePermission, err error) {
+	if len(roleIds) == 0 {
+		return result, nil
+	}
+
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("role_permissions").
+		Join(goqu.T("permissions"), goqu.On(goqu.I("permissions.id").Eq(goqu.I("role_permissions.permission_id")))).
+		Select(goqu.I("role_permissions.role_id"), goqu.I("permissions.name")).
+		Where(goqu.I("role_permissions.role_id").In(roleIds)).
+		Order(goqu.I("permissions.name").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// replaces every permission of the role with permissionIds
+func (r *roleRepo) SetRolePermissions(roleId uint, permissionIds []uint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	sql, val, err := dialect.Delete("role_permissions").
+		Where(goqu.I("role_id").Eq(roleId)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err = tx.Exec(sql, val...); err != nil {
+		return fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	if len(permissionIds) == 0 {
+		return nil
+	}
+
+	rows := make([]any, 0, len(permissionIds))
+	for _, permissionId := range permissionIds {
+		rows = append(rows, goqu.Record{"role_id": roleId, "permission_id": permissionId})
+	}
+
+	sql, val, err = dialect.Insert("role_permissions").Rows(rows...).ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err = tx.Exec(sql, val...); err != nil {
+		return fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	return nil
+}
+
+func (r *roleRepo) GetUserRoles(userId uint, db *sqlx.DB) (result []entity.Role, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("roles").
+		Join(goqu.T("user_roles"), goqu.On(goqu.I("user_roles.role_id").Eq(goqu.I("roles.id")))).
+		Select(roleColumns...).
+		Where(goqu.I("user_roles.user_id").Eq(userId)).
+		Order(goqu.I("roles.name").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// returns the names of the permissions granted to the user through any of its roles
+func (r *roleRepo) GetUserPermissions(userId uint, db *sqlx.DB) (result []string, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("user_roles").
+		Join(goqu.T("role_permissions"), goqu.On(goqu.I("role_permissions.role_id").Eq(goqu.I("user_roles.role_id")))).
+		Join(goqu.T("permissions"), goqu.On(goqu.I("permissions.id").Eq(goqu.I("role_permissions.permission_id")))).
+		Select(goqu.I("permissions.name")).
+		Distinct().
+		Where(goqu.I("user_roles.user_id").Eq(userId)).
+		Order(goqu.I("permissions.name").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// returns the ids of the users holding the role
+func (r *roleRepo) GetRoleUserIds(roleId uint, db *sqlx.DB) (result []uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("user_roles").
+		Select(goqu.I("user_id")).
+		Where(goqu.I("role_id").Eq(roleId)).
+		Order(goqu.I("user_id").Asc())
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Select(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// assigns the role, assigned is false when the user already had it
+func (r *roleRepo) AssignUserRole(userId, roleId uint, tx *sqlx.Tx) (assigned bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("user_roles").
+		Rows(goqu.Record{"user_id": userId, "role_id": roleId}).
+		OnConflict(goqu.DoNothing())
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r *roleRepo) RemoveUserRole(userId, roleId uint, tx *sqlx.Tx) (removed bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete("user_roles").
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("role_id").Eq(roleId),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
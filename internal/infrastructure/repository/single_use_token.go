@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+// SingleUseTokenRepository holds the tokens and codes emailed to a user that are spent once,
+// T is the row of the table
+type SingleUseTokenRepository[T any] interface {
+	Insert(data T, tx *sqlx.Tx) (result uint, err error)
+	MarkUsed(id uint, tx *sqlx.Tx) (used bool, err error)
+	InvalidateByUserId(userId uint, tx *sqlx.Tx) error
+}
+
+// singleUseTokenRepo implements SingleUseTokenRepository on a table with the id, user_id,
+// expired_at and used_at columns
+type singleUseTokenRepo[T any] struct {
+	table   string
+	columns []any
+}
+
+func (r *singleUseTokenRepo[T]) Insert(data T, tx *sqlx.Tx) (result uint, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert(r.table).Rows(data)
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return result, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return result, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return uint(id), nil
+}
+
+// returns the latest unused and unexpired row matching filter
+func (r *singleUseTokenRepo[T]) getValid(filter goqu.Expression, db *sqlx.DB) (result T, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From(r.table).
+		Select(r.columns...).
+		Where(
+			filter,
+			goqu.I("used_at").IsNull(),
+			goqu.I("expired_at").Gt(time.Now()),
+		).
+		Order(goqu.I("id").Desc()).
+		Limit(1)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+// marks the row as used, used is false when it was already used
+func (r *singleUseTokenRepo[T]) MarkUsed(id uint, tx *sqlx.Tx) (used bool, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update(r.table).
+		Set(goqu.Record{"used_at": time.Now()}).
+		Where(
+			goqu.I("id").Eq(id),
+			goqu.I("used_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := tx.Exec(sql, val...)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// marks every unused row of the user as used so only the one issued next is accepted
+func (r *singleUseTokenRepo[T]) InvalidateByUserId(userId uint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update(r.table).
+		Set(goqu.Record{"used_at": time.Now()}).
+		Where(
+			goqu.I("user_id").Eq(userId),
+			goqu.I("used_at").IsNull(),
+		)
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/fazriegi/go-boilerplate/internal/entity"
@@ -11,8 +12,11 @@ import (
 
 type UserRepository interface {
 	GetByUsername(username string, db *sqlx.DB) (entity.User, error)
+	GetByEmail(email string, db *sqlx.DB) (entity.User, error)
 	Insert(data *entity.User, db *sqlx.Tx) (result uint, err error)
 	GetById(id uint, db *sqlx.DB) (result entity.User, err error)
+	MarkEmailVerified(id uint, tx *sqlx.Tx) error
+	UpdatePassword(id uint, password string, tx *sqlx.Tx) error
 }
 
 type userRepo struct {
@@ -22,10 +26,24 @@ func NewUserRepository() UserRepository {
 	return &userRepo{}
 }
 
+var userColumns = []any{
+	goqu.I("username"),
+	goqu.I("password"),
+	goqu.I("email"),
+	goqu.I("email_verified_at"),
+	goqu.I("id"),
+	goqu.I("name"),
+}
+
+var userUniqueIndexes = map[string]error{
+	"uq_users_username": pkg.ErrUsernameTaken,
+	"uq_users_email":    pkg.ErrEmailTaken,
+}
+
 func (r *userRepo) GetById(id uint, db *sqlx.DB) (result entity.User, err error) {
 	dialect := pkg.GetDialect()
 
-	dataset := dialect.From("users").Select(goqu.I("username"), goqu.I("password"), goqu.I("email"), goqu.I("id"), goqu.I("name")).Where(goqu.I("id").Eq(id))
+	dataset := dialect.From("users").Select(userColumns...).Where(goqu.I("id").Eq(id))
 
 	query, val, err := dataset.ToSQL()
 	if err != nil {
@@ -43,7 +61,25 @@ func (r *userRepo) GetById(id uint, db *sqlx.DB) (result entity.User, err error)
 func (r *userRepo) GetByUsername(username string, db *sqlx.DB) (result entity.User, err error) {
 	dialect := pkg.GetDialect()
 
-	dataset := dialect.From("users").Select(goqu.I("username"), goqu.I("password"), goqu.I("email"), goqu.I("id"), goqu.I("name")).Where(goqu.I("username").Eq(username))
+	dataset := dialect.From("users").Select(userColumns...).Where(goqu.I("username").Eq(username))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
+
+func (r *userRepo) GetByEmail(email string, db *sqlx.DB) (result entity.User, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("users").Select(userColumns...).Where(goqu.I("email").Eq(email))
 
 	query, val, err := dataset.ToSQL()
 	if err != nil {
@@ -69,6 +105,10 @@ func (r *userRepo) Insert(data *entity.User, tx *sqlx.Tx) (result uint, err erro
 
 	res, err := tx.Exec(sql, val...)
 	if err != nil {
+		// concurrent registrations are only caught by the unique indexes
+		if domainErr := duplicateIndexError(err, userUniqueIndexes); domainErr != nil {
+			return result, domainErr
+		}
 		return result, fmt.Errorf("failed to execute insert: %w", err)
 	}
 
@@ -79,3 +119,43 @@ func (r *userRepo) Insert(data *entity.User, tx *sqlx.Tx) (result uint, err erro
 
 	return uint(id), nil
 }
+
+func (r *userRepo) MarkEmailVerified(id uint, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("users").
+		Set(goqu.Record{"email_verified_at": time.Now()}).
+		Where(goqu.I("id").Eq(id))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userRepo) UpdatePassword(id uint, password string, tx *sqlx.Tx) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("users").
+		Set(goqu.Record{"password": password}).
+		Where(goqu.I("id").Eq(id))
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = tx.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
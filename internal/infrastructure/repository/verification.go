@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+type EmailVerificationRepository interface {
+	SingleUseTokenRepository[entity.EmailVerificationToken]
+	GetValidByToken(token string, db *sqlx.DB) (result entity.EmailVerificationToken, err error)
+	GetLatestByUserId(userId uint, db *sqlx.DB) (result entity.EmailVerificationToken, err error)
+}
+
+type emailVerificationRepo struct {
+	singleUseTokenRepo[entity.EmailVerificationToken]
+}
+
+func NewEmailVerificationRepository() EmailVerificationRepository {
+	return &emailVerificationRepo{
+		singleUseTokenRepo[entity.EmailVerificationToken]{"email_verification_tokens", emailVerificationColumns},
+	}
+}
+
+var emailVerificationColumns = []any{
+	goqu.I("id"),
+	goqu.I("user_id"),
+	goqu.I("token"),
+	goqu.I("expired_at"),
+	goqu.I("used_at"),
+	goqu.I("created_at"),
+}
+
+func (r *emailVerificationRepo) GetValidByToken(token string, db *sqlx.DB) (result entity.EmailVerificationToken, err error) {
+	return r.getValid(goqu.I("token").Eq(token), db)
+}
+
+func (r *emailVerificationRepo) GetLatestByUserId(userId uint, db *sqlx.DB) (result entity.EmailVerificationToken, err error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("email_verification_tokens").
+		Select(emailVerificationColumns...).
+		Where(goqu.I("user_id").Eq(userId)).
+		Order(goqu.I("created_at").Desc()).
+		Limit(1)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return result, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	err = db.Get(&result, query, val...)
+	if err != nil {
+		return result, err
+	}
+
+	return
+}
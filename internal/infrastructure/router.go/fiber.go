@@ -2,25 +2,131 @@ package router
 
 import (
 	"github.com/fazriegi/go-boilerplate/internal/controller"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/mailer"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth"
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/scheduler"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
 	"github.com/fazriegi/go-boilerplate/internal/middleware"
 	"github.com/fazriegi/go-boilerplate/internal/pkg"
 	"github.com/fazriegi/go-boilerplate/internal/usecase"
 	"github.com/gofiber/fiber/v2"
 )
 
-func NewRoute(app *fiber.App, jwt *pkg.JWT) {
+func NewRoute(app *fiber.App, jwt *pkg.JWT, passwordPolicy *pkg.PasswordPolicy, oauthProviders map[string]oauth.Provider, background *scheduler.Background) {
 	userRepo := repository.NewUserRepository()
 	authRepo := repository.NewAuthRepository()
-	authUC := usecase.NewAuthUsecase(userRepo, authRepo, jwt)
+	verificationRepo := repository.NewEmailVerificationRepository()
+	passwordResetRepo := repository.NewPasswordResetRepository()
+	mfaRepo := repository.NewMFARepository()
+	identityRepo := repository.NewIdentityRepository()
+	apiKeyRepo := repository.NewAPIKeyRepository()
+	roleRepo := repository.NewRoleRepository()
+	impersonationRepo := repository.NewImpersonationRepository()
+	magicLinkRepo := repository.NewMagicLinkRepository()
+	mfaEmailCodeRepo := repository.NewMFAEmailCodeRepository()
+	auditRepo := repository.NewAuditRepository()
+	revocationStore := store.NewRevocationStore()
+	loginAttemptStore := store.NewLoginAttemptStore()
+	rateLimitStore := store.NewRateLimitStore()
+	mail := mailer.New()
+	audit := usecase.NewAuditLogger(auditRepo)
+	verificationUC := usecase.NewEmailVerificationUsecase(userRepo, verificationRepo, mail)
+	verificationController := controller.NewEmailVerificationController(verificationUC)
+	emailOTPUC := usecase.NewEmailOTPUsecase(mfaEmailCodeRepo, rateLimitStore, mail)
+	authUC := usecase.NewAuthUsecase(userRepo, authRepo, mfaRepo, roleRepo, verificationUC, emailOTPUC, audit, revocationStore, loginAttemptStore, passwordPolicy, jwt)
 	authController := controller.NewAuthController(authUC)
+	passwordUC := usecase.NewPasswordUsecase(userRepo, authRepo, passwordResetRepo, mail, revocationStore, loginAttemptStore, audit, passwordPolicy, background)
+	passwordController := controller.NewPasswordController(passwordUC)
+	magicLinkUC := usecase.NewMagicLinkUsecase(userRepo, magicLinkRepo, authUC, mail, audit, background)
+	magicLinkController := controller.NewMagicLinkController(magicLinkUC)
+	oauthUC := usecase.NewOAuthUsecase(userRepo, identityRepo, authUC, oauthProviders)
+	oauthController := controller.NewOAuthController(oauthUC)
+	apiKeyUC := usecase.NewAPIKeyUsecase(userRepo, apiKeyRepo, roleRepo, audit)
+	apiKeyController := controller.NewAPIKeyController(apiKeyUC)
+	mfaUC := usecase.NewMFAUsecase(userRepo, mfaRepo, emailOTPUC, audit, loginAttemptStore)
+	mfaController := controller.NewMFAController(mfaUC)
+	lockoutUC := usecase.NewLockoutUsecase(loginAttemptStore)
+	lockoutController := controller.NewLockoutController(lockoutUC)
+	sessionUC := usecase.NewSessionUsecase(authRepo, revocationStore)
+	sessionController := controller.NewSessionController(sessionUC)
+	roleUC := usecase.NewRoleUsecase(userRepo, roleRepo, authRepo, revocationStore)
+	roleController := controller.NewRoleController(roleUC)
+	impersonationUC := usecase.NewImpersonationUsecase(userRepo, roleRepo, impersonationRepo, revocationStore, audit, jwt)
+	impersonationController := controller.NewImpersonationController(impersonationUC)
+	auditUC := usecase.NewAuditUsecase(auditRepo)
+	auditController := controller.NewAuditController(auditUC)
+
+	jwksController := controller.NewJWKSController(jwt)
+	authentication := middleware.Authentication(jwt, revocationStore)
+	// routes a machine client may call also accept API keys, account management stays with sessions
+	apiKeyAuthentication := middleware.APIKeyAuthentication(apiKeyUC, authentication)
+	// credentials, sessions and admin actions stay with the user while an admin impersonates them
+	denyImpersonation := middleware.DenyImpersonation()
+	// an API key changes state on the admin routes only when it also holds the write scope
+	writeScope := middleware.RequireScope(entity.APIKeyScopeWrite)
+	authLimit := middleware.RateLimit(rateLimitStore, middleware.RateLimitConfigFromEnv("auth"))
+	apiLimit := middleware.RateLimit(rateLimitStore, middleware.RateLimitConfigFromEnv("api"))
+
+	app.Get("/.well-known/jwks.json", jwksController.Get)
 
 	v1 := app.Group("/api/v1")
 	{
-		v1.Post("/register", authController.Register)
-		v1.Post("/login", authController.Login)
-		v1.Get("/check-token", middleware.Authentication(jwt), authController.CheckToken)
-		v1.Post("/refresh-token", authController.RefreshToken)
+		v1.Post("/register", authLimit, authController.Register)
+		v1.Post("/login", authLimit, authController.Login)
+		v1.Post("/login/mfa", authLimit, authController.VerifyMFA)
+		v1.Post("/login/mfa/resend", authLimit, authController.ResendMFACode)
+		v1.Post("/login/magic-link", authLimit, magicLinkController.Send)
+		v1.Post("/login/magic-link/verify", authLimit, magicLinkController.Verify)
+		v1.Get("/check-token", apiKeyAuthentication, apiLimit, middleware.RequireScope(entity.APIKeyScopeRead), authController.CheckToken)
+		v1.Post("/refresh-token", authLimit, authController.RefreshToken)
 		v1.Post("/logout", authController.Logout)
+		v1.Post("/logout-all", authentication, denyImpersonation, apiLimit, authController.LogoutAll)
+		v1.Post("/verify-email", authLimit, verificationController.Verify)
+		v1.Post("/verify-email/resend", authLimit, verificationController.Resend)
+		v1.Post("/password/forgot", authLimit, passwordController.Forgot)
+		v1.Post("/password/reset", authLimit, passwordController.Reset)
+		v1.Get("/oauth/:provider", authLimit, oauthController.Start)
+		v1.Get("/oauth/:provider/callback", authLimit, oauthController.Callback)
+
+		v1.Put("/me/password", authentication, denyImpersonation, apiLimit, passwordController.Change)
+		v1.Post("/impersonation/stop", authentication, apiLimit, impersonationController.Stop)
+
+		mfa := v1.Group("/me/mfa", authentication, denyImpersonation, apiLimit)
+		mfa.Post("/enroll", mfaController.Enroll)
+		mfa.Post("/email/enroll", mfaController.EnrollEmail)
+		mfa.Post("/email/code", mfaController.SendEmailCode)
+		mfa.Post("/confirm", mfaController.Confirm)
+		mfa.Post("/disable", mfaController.Disable)
+
+		identities := v1.Group("/me/identities", authentication, denyImpersonation, apiLimit)
+		identities.Get("/", oauthController.Identities)
+		identities.Post("/:provider", oauthController.Link)
+		identities.Delete("/:provider", oauthController.Unlink)
+
+		apiKeys := v1.Group("/me/api-keys", authentication, denyImpersonation, apiLimit)
+		apiKeys.Get("/", apiKeyController.List)
+		apiKeys.Post("/", apiKeyController.Create)
+		apiKeys.Delete("/:id", apiKeyController.Revoke)
+
+		sessions := v1.Group("/sessions", authentication, denyImpersonation, apiLimit)
+		sessions.Get("/", sessionController.List)
+		sessions.Delete("/:id", sessionController.Revoke)
+
+		admin := v1.Group("/admin", apiKeyAuthentication, denyImpersonation, middleware.RequireScope(entity.APIKeyScopeAdmin), apiLimit)
+		admin.Delete("/lockouts", writeScope, middleware.RequirePermission(entity.PermissionLockoutsWrite), lockoutController.Clear)
+		admin.Get("/roles", middleware.RequirePermission(entity.PermissionRolesRead), roleController.ListRoles)
+		admin.Post("/roles", writeScope, middleware.RequirePermission(entity.PermissionRolesWrite), roleController.CreateRole)
+		admin.Put("/roles/:id/permissions", writeScope, middleware.RequirePermission(entity.PermissionRolesWrite), roleController.SetRolePermissions)
+		admin.Delete("/roles/:id", writeScope, middleware.RequirePermission(entity.PermissionRolesWrite), roleController.DeleteRole)
+		admin.Get("/permissions", middleware.RequirePermission(entity.PermissionRolesRead), roleController.ListPermissions)
+		admin.Get("/users/:id/roles", middleware.RequirePermission(entity.PermissionUsersRead), roleController.ListUserRoles)
+		admin.Post("/users/:id/roles", writeScope, middleware.RequirePermission(entity.PermissionUsersWrite), roleController.AssignRole)
+		admin.Delete("/users/:id/roles/:role", writeScope, middleware.RequirePermission(entity.PermissionUsersWrite), roleController.RemoveRole)
+		admin.Post("/users/:id/impersonate", writeScope, middleware.RequirePermission(entity.PermissionUsersImpersonate), impersonationController.Start)
+		admin.Get("/audit-events", middleware.RequirePermission(entity.PermissionAuditRead), auditController.List)
+		admin.Get("/audit-events/verify", middleware.RequirePermission(entity.PermissionAuditRead), auditController.Verify)
+		admin.Get("/audit-checkpoints/export", middleware.RequirePermission(entity.PermissionAuditRead), auditController.ExportCheckpoints)
 	}
 }
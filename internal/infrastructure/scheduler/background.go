@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// Background runs one-off tasks outside the request that started them, e.g. work whose
+// duration would tell the client whether an account exists
+type Background struct {
+	wg  sync.WaitGroup
+	log *logrus.Logger
+}
+
+func NewBackground() *Background {
+	log := logger.Get()
+
+	return &Background{
+		log: log,
+	}
+}
+
+// Go runs task in its own goroutine, a failed or panicking task is logged
+func (b *Background) Go(name string, task func() error) {
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+
+		start := time.Now()
+
+		err := func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+
+			return task()
+		}()
+
+		if err != nil {
+			b.log.Errorf("task failed: %s | name=%s | duration=%s", err.Error(), name, time.Since(start))
+		}
+	}()
+}
+
+// Wait waits for the running tasks to return until ctx is done
+func (b *Background) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("tasks still running: %w", ctx.Err())
+	}
+}
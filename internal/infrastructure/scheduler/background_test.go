@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestBackground() *Background {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	logger.LOGGER = log
+
+	return NewBackground()
+}
+
+func TestBackgroundWaitsForTasks(t *testing.T) {
+	b := newTestBackground()
+
+	var done atomic.Int32
+	b.Go("slow", func() error {
+		time.Sleep(10 * time.Millisecond)
+		done.Add(1)
+		return nil
+	})
+	// failing and panicking tasks do not stop the others
+	b.Go("failing", func() error {
+		return errors.New("failed")
+	})
+	b.Go("panicking", func() error {
+		panic("boom")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("expected wait to succeed, got %v", err)
+	}
+	if done.Load() != 1 {
+		t.Fatal("expected wait to return once the slow task finished")
+	}
+}
+
+func TestBackgroundWaitGivesUpWithContext(t *testing.T) {
+	b := newTestBackground()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	b.Go("blocked", func() error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
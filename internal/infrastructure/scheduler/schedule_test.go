@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// a wednesday
+	from := time.Date(2026, 1, 7, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 90s", from.Add(90 * time.Second)},
+		{"*/15 * * * *", time.Date(2026, 1, 7, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 1, 8, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 7, 11, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * 1-5", time.Date(2026, 1, 7, 13, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 2 *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		// both day fields restricted, either matches
+		{"0 0 20 * 5", time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("expected %q to parse, got %v", tt.spec, err)
+			}
+
+			if got := schedule.Next(from); !got.Equal(tt.want) {
+				t.Fatalf("expected the next run at %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseScheduleRejectsInvalidSpecs(t *testing.T) {
+	for _, spec := range []string{"", "@every -1m", "@every soon", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("expected %q to be refused", spec)
+		}
+	}
+}
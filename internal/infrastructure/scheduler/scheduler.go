@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Jitter delays every run by a random duration up to it, so instances sharing a schedule
+	// do not all run at once
+	Jitter time.Duration
+	// Run should return early once ctx is done, it is canceled when the scheduler stops
+	Run func(ctx context.Context) error
+}
+
+// Scheduler runs jobs in the background of the process. Each job runs in its own goroutine
+// and its next run is planned once the current one returned, so runs of a job never overlap
+// and runs missed meanwhile are skipped
+type Scheduler struct {
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	log    *logrus.Logger
+}
+
+func New() *Scheduler {
+	log := logger.Get()
+
+	return &Scheduler{
+		log: log,
+	}
+}
+
+// Add adds a job, jobs added after Start are not run
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Register adds the job configured by JOB_<NAME>_ENABLED, JOB_<NAME>_SCHEDULE and
+// JOB_<NAME>_JITTER_SECOND, a disabled job is left out
+func (s *Scheduler) Register(name string, run func(ctx context.Context) error) error {
+	prefix := "JOB_" + strings.ToUpper(name)
+
+	if !config.GetBool(prefix + "_ENABLED") {
+		s.log.Infof("job disabled | name=%s", name)
+		return nil
+	}
+
+	schedule, err := ParseSchedule(config.GetString(prefix + "_SCHEDULE"))
+	if err != nil {
+		return fmt.Errorf("invalid schedule of job %s: %w", name, err)
+	}
+
+	s.Add(Job{
+		Name:     name,
+		Schedule: schedule,
+		Jitter:   time.Duration(config.GetUint(prefix+"_JITTER_SECOND")) * time.Second,
+		Run:      run,
+	})
+
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop plans no further runs and cancels the running ones, it waits for them to return
+// until ctx is done
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running: %w", ctx.Err())
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	for {
+		next := job.Schedule.Next(time.Now())
+		if next.IsZero() {
+			s.log.Warnf("job has no next run | name=%s", job.Name)
+			return
+		}
+
+		if job.Jitter > 0 {
+			next = next.Add(rand.N(job.Jitter))
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(ctx, job)
+	}
+}
+
+// run runs the job once, a panic is logged as a failed run instead of ending the process
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		return job.Run(ctx)
+	}()
+
+	duration := time.Since(start)
+	if err != nil {
+		s.log.Errorf("job failed: %s | name=%s | duration=%s", err.Error(), job.Name, duration)
+		return
+	}
+
+	s.log.Infof("job finished | name=%s | duration=%s", job.Name, duration)
+}
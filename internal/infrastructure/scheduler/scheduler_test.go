@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestScheduler() *Scheduler {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	logger.LOGGER = log
+
+	return New()
+}
+
+func TestSchedulerRunsJobsWithoutOverlap(t *testing.T) {
+	s := newTestScheduler()
+
+	var runs, running, overlaps atomic.Int32
+	s.Add(Job{
+		Name:     "slow",
+		Schedule: Every(time.Millisecond),
+		Run: func(ctx context.Context) error {
+			if running.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			defer running.Add(-1)
+
+			runs.Add(1)
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		},
+	})
+
+	// failing and panicking runs are logged and the job keeps being scheduled
+	var failures atomic.Int32
+	s.Add(Job{
+		Name:     "failing",
+		Schedule: Every(time.Millisecond),
+		Run: func(ctx context.Context) error {
+			if failures.Add(1)%2 == 0 {
+				panic("boom")
+			}
+			return errors.New("failed")
+		},
+	})
+
+	s.Start()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("expected the scheduler to stop, got %v", err)
+	}
+
+	if runs.Load() < 2 || failures.Load() < 2 {
+		t.Fatalf("expected the jobs to run repeatedly, got %d and %d runs", runs.Load(), failures.Load())
+	}
+	if overlaps.Load() != 0 {
+		t.Fatalf("expected runs of a job not to overlap, got %d", overlaps.Load())
+	}
+
+	after := runs.Load()
+	time.Sleep(10 * time.Millisecond)
+	if runs.Load() != after {
+		t.Fatal("expected no runs after stop")
+	}
+}
+
+func TestSchedulerStopCancelsRunningJobs(t *testing.T) {
+	s := newTestScheduler()
+
+	started := make(chan struct{})
+	s.Add(Job{
+		Name:     "blocking",
+		Schedule: Every(time.Millisecond),
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	s.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("expected the running job to be canceled, got %v", err)
+	}
+}
+
+func TestSchedulerRegisterFromConfig(t *testing.T) {
+	s := newTestScheduler()
+	run := func(ctx context.Context) error { return nil }
+
+	config.Set("JOB_DISABLED_ENABLED", false)
+	if err := s.Register("disabled", run); err != nil || len(s.jobs) != 0 {
+		t.Fatalf("expected a disabled job to be left out, got %d jobs: %v", len(s.jobs), err)
+	}
+
+	config.Set("JOB_BROKEN_ENABLED", true)
+	config.Set("JOB_BROKEN_SCHEDULE", "every hour")
+	if err := s.Register("broken", run); err == nil {
+		t.Fatal("expected an invalid schedule to be refused")
+	}
+
+	config.Set("JOB_PURGE_ENABLED", true)
+	config.Set("JOB_PURGE_SCHEDULE", "@every 1h")
+	config.Set("JOB_PURGE_JITTER_SECOND", 30)
+	if err := s.Register("purge", run); err != nil {
+		t.Fatalf("expected the job to be registered, got %v", err)
+	}
+
+	if len(s.jobs) != 1 || s.jobs[0].Name != "purge" || s.jobs[0].Jitter != 30*time.Second {
+		t.Fatalf("expected the configured job, got %+v", s.jobs)
+	}
+}
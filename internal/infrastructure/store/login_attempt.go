@@ -0,0 +1,37 @@
+package store
+
+import (
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+)
+
+// LoginAttempt is the failure counter of a login key, a username or a client IP
+type LoginAttempt struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Locked reports whether the key is locked at now
+func (a LoginAttempt) Locked(now time.Time) bool {
+	return a.LockedUntil.After(now)
+}
+
+// LoginAttemptStore tracks failed logins per key
+type LoginAttemptStore interface {
+	Get(key string) (LoginAttempt, error)
+	// RecordFailure adds a failure to key, the counter starts over once window passed without failures
+	RecordFailure(key string, window time.Duration) (LoginAttempt, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// NewLoginAttemptStore returns the store selected by LOGIN_ATTEMPT_STORE, defaults to memory
+func NewLoginAttemptStore() LoginAttemptStore {
+	switch config.GetString("LOGIN_ATTEMPT_STORE") {
+	case "mysql":
+		return NewMysqlLoginAttemptStore()
+	default:
+		return NewMemoryLoginAttemptStore(time.Minute)
+	}
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryLoginAttempt struct {
+	LoginAttempt
+	lastFailedAt time.Time
+	expiresAt    time.Time
+}
+
+// MemoryLoginAttemptStore is a process local LoginAttemptStore, entries are dropped once
+// their window and lock have passed
+type MemoryLoginAttemptStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryLoginAttempt
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryLoginAttemptStore creates the store and purges expired entries every cleanupInterval
+// until it is closed
+func NewMemoryLoginAttemptStore(cleanupInterval time.Duration) *MemoryLoginAttemptStore {
+	s := &MemoryLoginAttemptStore{
+		entries: make(map[string]*memoryLoginAttempt),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.cleanup()
+			}
+		}
+	}()
+
+	return s
+}
+
+// Close stops purging expired entries, the store keeps answering afterwards
+func (s *MemoryLoginAttemptStore) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+func (s *MemoryLoginAttemptStore) Get(key string) (LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !entry.expiresAt.After(time.Now()) {
+		return LoginAttempt{}, nil
+	}
+
+	return entry.LoginAttempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(key string, window time.Duration) (LoginAttempt, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryLoginAttempt{}
+		s.entries[key] = entry
+	}
+
+	if entry.lastFailedAt.Add(window).Before(now) {
+		entry.Failures = 0
+	}
+
+	entry.Failures++
+	entry.lastFailedAt = now
+	entry.expiresAt = latest(now.Add(window), entry.LockedUntil)
+
+	return entry.LoginAttempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryLoginAttempt{}
+		s.entries[key] = entry
+	}
+
+	entry.LockedUntil = until
+	entry.expiresAt = latest(entry.expiresAt, until)
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) cleanup() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, entry := range s.entries {
+		if !entry.expiresAt.After(now) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
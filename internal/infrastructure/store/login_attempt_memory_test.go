@@ -0,0 +1,57 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+)
+
+func TestMemoryLoginAttemptStore(t *testing.T) {
+	s := store.NewMemoryLoginAttemptStore(time.Minute)
+	defer s.Close()
+
+	for i := 1; i <= 3; i++ {
+		attempt, err := s.RecordFailure("username:alice", time.Minute)
+		if err != nil {
+			t.Fatalf("RecordFailure returned error: %v", err)
+		}
+		if attempt.Failures != i {
+			t.Fatalf("expected %d failures, got %d", i, attempt.Failures)
+		}
+	}
+
+	if err := s.Lock("username:alice", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Lock returned error: %v", err)
+	}
+
+	attempt, _ := s.Get("username:alice")
+	if !attempt.Locked(time.Now()) {
+		t.Error("expected key to be locked")
+	}
+
+	if other, _ := s.Get("ip:127.0.0.1"); other.Failures != 0 || other.Locked(time.Now()) {
+		t.Error("other keys should be untouched")
+	}
+
+	if err := s.Reset("username:alice"); err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+
+	if attempt, _ := s.Get("username:alice"); attempt.Failures != 0 || attempt.Locked(time.Now()) {
+		t.Error("expected key to be cleared")
+	}
+}
+
+func TestMemoryLoginAttemptStoreWindow(t *testing.T) {
+	s := store.NewMemoryLoginAttemptStore(time.Minute)
+	defer s.Close()
+
+	s.RecordFailure("ip:127.0.0.1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	attempt, _ := s.RecordFailure("ip:127.0.0.1", time.Millisecond)
+	if attempt.Failures != 1 {
+		t.Fatalf("expected the counter to start over after the window, got %d failures", attempt.Failures)
+	}
+}
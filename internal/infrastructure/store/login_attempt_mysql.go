@@ -0,0 +1,131 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+// MysqlLoginAttemptStore shares failed login counters between instances through the login_attempts table
+type MysqlLoginAttemptStore struct {
+	db *sqlx.DB
+}
+
+func NewMysqlLoginAttemptStore() *MysqlLoginAttemptStore {
+	return &MysqlLoginAttemptStore{
+		db: database.Get(),
+	}
+}
+
+func (s *MysqlLoginAttemptStore) Get(key string) (LoginAttempt, error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("login_attempts").
+		Select(
+			goqu.I("failures"),
+			goqu.I("locked_until"),
+		).
+		Where(
+			goqu.I("attempt_key").Eq(key),
+			goqu.I("expired_at").Gt(time.Now()),
+		)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return LoginAttempt{}, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	var row struct {
+		Failures    int        `db:"failures"`
+		LockedUntil *time.Time `db:"locked_until"`
+	}
+
+	err = s.db.Get(&row, query, val...)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return LoginAttempt{}, nil
+	} else if err != nil {
+		return LoginAttempt{}, err
+	}
+
+	attempt := LoginAttempt{Failures: row.Failures}
+	if row.LockedUntil != nil {
+		attempt.LockedUntil = *row.LockedUntil
+	}
+
+	return attempt, nil
+}
+
+func (s *MysqlLoginAttemptStore) RecordFailure(key string, window time.Duration) (LoginAttempt, error) {
+	dialect := pkg.GetDialect()
+	now := time.Now()
+
+	// the counter is restarted in the same statement when the previous failure is outside the window
+	dataset := dialect.Insert("login_attempts").
+		Rows(goqu.Record{
+			"attempt_key":    key,
+			"failures":       1,
+			"last_failed_at": now,
+			"expired_at":     now.Add(window),
+		}).
+		OnConflict(goqu.DoUpdate("attempt_key", goqu.Record{
+			"failures":       goqu.L("IF(last_failed_at < ?, 1, failures + 1)", now.Add(-window)),
+			"last_failed_at": now,
+			"expired_at":     goqu.L("GREATEST(?, COALESCE(locked_until, ?))", now.Add(window), now),
+		}))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return LoginAttempt{}, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := s.db.Exec(query, val...); err != nil {
+		return LoginAttempt{}, fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	return s.Get(key)
+}
+
+func (s *MysqlLoginAttemptStore) Lock(key string, until time.Time) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Update("login_attempts").
+		Set(goqu.Record{
+			"locked_until": until,
+			"expired_at":   goqu.L("GREATEST(expired_at, ?)", until),
+		}).
+		Where(goqu.I("attempt_key").Eq(key))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := s.db.Exec(query, val...); err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MysqlLoginAttemptStore) Reset(key string) error {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Delete("login_attempts").Where(goqu.I("attempt_key").Eq(key))
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := s.db.Exec(query, val...); err != nil {
+		return fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	return nil
+}
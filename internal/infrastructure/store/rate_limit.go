@@ -0,0 +1,74 @@
+package store
+
+import (
+	"math"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+)
+
+// RateLimitResult is the outcome of counting a request against a limit
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// ResetAt is when the whole limit is available again
+	ResetAt time.Time
+	// RetryAt is when the next request is allowed, only set when the request is refused
+	RetryAt time.Time
+}
+
+// RateLimitStore counts requests per key for the fixed window and token bucket algorithms,
+// each call counts one request and is atomic for the key
+type RateLimitStore interface {
+	FixedWindow(key string, limit int, window time.Duration) (RateLimitResult, error)
+	// TokenBucket takes a token from a bucket holding at most capacity tokens, the bucket is
+	// refilled at capacity tokens per refill
+	TokenBucket(key string, capacity int, refill time.Duration) (RateLimitResult, error)
+}
+
+// NewRateLimitStore returns the store selected by RATE_LIMIT_STORE, defaults to memory
+func NewRateLimitStore() RateLimitStore {
+	switch config.GetString("RATE_LIMIT_STORE") {
+	case "mysql":
+		return NewMysqlRateLimitStore()
+	default:
+		return NewMemoryRateLimitStore(time.Minute)
+	}
+}
+
+// fixedWindowResult evaluates hits counted in the window ending at resetAt
+func fixedWindowResult(hits, limit int, resetAt time.Time) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   hits <= limit,
+		Remaining: max(limit-hits, 0),
+		ResetAt:   resetAt,
+	}
+
+	if !result.Allowed {
+		result.RetryAt = resetAt
+	}
+
+	return result
+}
+
+// takeToken refills a bucket last updated at updatedAt and takes a token from it, it returns
+// the tokens left in the bucket
+func takeToken(tokens float64, updatedAt, now time.Time, capacity int, refill time.Duration) (float64, RateLimitResult) {
+	rate := float64(capacity) / refill.Seconds() // tokens per second
+
+	if elapsed := now.Sub(updatedAt).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(capacity), tokens+elapsed*rate)
+	}
+
+	result := RateLimitResult{Allowed: tokens >= 1}
+	if result.Allowed {
+		tokens--
+	} else {
+		result.RetryAt = now.Add(time.Duration((1 - tokens) / rate * float64(time.Second)))
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAt = now.Add(time.Duration((float64(capacity) - tokens) / rate * float64(time.Second)))
+
+	return tokens, result
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryRateLimitEntry struct {
+	// hits of the current window, or tokens left in the bucket
+	value     float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// MemoryRateLimitStore is a process local RateLimitStore, limits are counted per instance
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryRateLimitEntry
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryRateLimitStore creates the store and purges expired entries every cleanupInterval
+// until it is closed
+func NewMemoryRateLimitStore(cleanupInterval time.Duration) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{
+		entries: make(map[string]*memoryRateLimitEntry),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.cleanup()
+			}
+		}
+	}()
+
+	return s
+}
+
+// Close stops purging expired entries, the store keeps answering afterwards
+func (s *MemoryRateLimitStore) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+func (s *MemoryRateLimitStore) FixedWindow(key string, limit int, window time.Duration) (RateLimitResult, error) {
+	now := time.Now()
+	windowStart := now.Truncate(window)
+	resetAt := windowStart.Add(window)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !entry.updatedAt.Equal(windowStart) {
+		entry = &memoryRateLimitEntry{updatedAt: windowStart, expiresAt: resetAt}
+		s.entries[key] = entry
+	}
+
+	entry.value++
+
+	return fixedWindowResult(int(entry.value), limit, resetAt), nil
+}
+
+func (s *MemoryRateLimitStore) TokenBucket(key string, capacity int, refill time.Duration) (RateLimitResult, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryRateLimitEntry{value: float64(capacity), updatedAt: now}
+		s.entries[key] = entry
+	}
+
+	tokens, result := takeToken(entry.value, entry.updatedAt, now, capacity, refill)
+	entry.value = tokens
+	entry.updatedAt = now
+	entry.expiresAt = result.ResetAt
+
+	return result, nil
+}
+
+func (s *MemoryRateLimitStore) cleanup() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, entry := range s.entries {
+		if !entry.expiresAt.After(now) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+// MysqlRateLimitStore shares rate limit counters between instances through the rate_limits table
+type MysqlRateLimitStore struct {
+	db *sqlx.DB
+}
+
+func NewMysqlRateLimitStore() *MysqlRateLimitStore {
+	return &MysqlRateLimitStore{
+		db: database.Get(),
+	}
+}
+
+// rateLimitState is a row of rate_limits, value holds the hits of the current window or
+// the tokens left in the bucket
+type rateLimitState struct {
+	Value     float64   `db:"value"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (s *MysqlRateLimitStore) FixedWindow(key string, limit int, window time.Duration) (RateLimitResult, error) {
+	now := time.Now()
+	windowStart := now.Truncate(window)
+	resetAt := windowStart.Add(window)
+
+	initial := rateLimitState{Value: 0, UpdatedAt: windowStart}
+
+	var result RateLimitResult
+	err := s.update(key, initial, func(state rateLimitState) (rateLimitState, time.Time) {
+		if state.UpdatedAt.Before(windowStart) {
+			state = initial
+		}
+
+		state.Value++
+		result = fixedWindowResult(int(state.Value), limit, resetAt)
+
+		return state, resetAt
+	})
+
+	return result, err
+}
+
+func (s *MysqlRateLimitStore) TokenBucket(key string, capacity int, refill time.Duration) (RateLimitResult, error) {
+	now := time.Now()
+
+	initial := rateLimitState{Value: float64(capacity), UpdatedAt: now}
+
+	var result RateLimitResult
+	err := s.update(key, initial, func(state rateLimitState) (rateLimitState, time.Time) {
+		var tokens float64
+		tokens, result = takeToken(state.Value, state.UpdatedAt, now, capacity, refill)
+
+		return rateLimitState{Value: tokens, UpdatedAt: now}, result.ResetAt
+	})
+
+	return result, err
+}
+
+// update locks the row of key, creating it with initial when missing, and stores what apply
+// derives from it so concurrent requests of every instance are counted one after the other
+func (s *MysqlRateLimitStore) update(key string, initial rateLimitState, apply func(state rateLimitState) (rateLimitState, time.Time)) error {
+	dialect := pkg.GetDialect()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert := dialect.Insert("rate_limits").
+		Rows(goqu.Record{
+			"limit_key":  key,
+			"value":      initial.Value,
+			"updated_at": initial.UpdatedAt,
+			"expired_at": initial.UpdatedAt,
+		}).
+		OnConflict(goqu.DoNothing())
+
+	query, val, err := insert.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := tx.Exec(query, val...); err != nil {
+		return fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	selectState := dialect.From("rate_limits").
+		Select(goqu.I("value"), goqu.I("updated_at")).
+		Where(goqu.I("limit_key").Eq(key)).
+		ForUpdate(exp.Wait)
+
+	query, val, err = selectState.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	var state rateLimitState
+	if err := tx.Get(&state, query, val...); err != nil {
+		return err
+	}
+
+	state, expiresAt := apply(state)
+
+	update := dialect.Update("rate_limits").
+		Set(goqu.Record{
+			"value":      state.Value,
+			"updated_at": state.UpdatedAt,
+			"expired_at": expiresAt,
+		}).
+		Where(goqu.I("limit_key").Eq(key))
+
+	query, val, err = update.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := tx.Exec(query, val...); err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	return nil
+}
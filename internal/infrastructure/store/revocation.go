@@ -0,0 +1,23 @@
+package store
+
+import (
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+)
+
+// RevocationStore keeps the ids (jti) of access tokens that must be rejected before they expire
+type RevocationStore interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
+
+// NewRevocationStore returns the store selected by TOKEN_REVOCATION_STORE, defaults to memory
+func NewRevocationStore() RevocationStore {
+	switch config.GetString("TOKEN_REVOCATION_STORE") {
+	case "mysql":
+		return NewMysqlRevocationStore()
+	default:
+		return NewMemoryRevocationStore(time.Minute)
+	}
+}
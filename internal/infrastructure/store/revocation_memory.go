@@ -0,0 +1,79 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryRevocationStore is a process local RevocationStore, entries are dropped once the token expires
+type MemoryRevocationStore struct {
+	mu        sync.RWMutex
+	entries   map[string]time.Time
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryRevocationStore creates the store and purges expired entries every cleanupInterval
+// until it is closed
+func NewMemoryRevocationStore(cleanupInterval time.Duration) *MemoryRevocationStore {
+	s := &MemoryRevocationStore{
+		entries: make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.cleanup()
+			}
+		}
+	}()
+
+	return s
+}
+
+// Close stops purging expired entries, the store keeps answering afterwards
+func (s *MemoryRevocationStore) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+func (s *MemoryRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	if !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[jti] = expiresAt
+
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, ok := s.entries[jti]
+
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+func (s *MemoryRevocationStore) cleanup() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for jti, expiresAt := range s.entries {
+		if !expiresAt.After(now) {
+			delete(s.entries, jti)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+)
+
+func TestMemoryRevocationStore(t *testing.T) {
+	s := store.NewMemoryRevocationStore(time.Minute)
+	defer s.Close()
+
+	if err := s.Revoke("live", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke returned error: %v", err)
+	}
+
+	if err := s.Revoke("expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Revoke returned error: %v", err)
+	}
+
+	if revoked, _ := s.IsRevoked("live"); !revoked {
+		t.Error("expected live token to be revoked")
+	}
+
+	if revoked, _ := s.IsRevoked("expired"); revoked {
+		t.Error("expired token should not be kept")
+	}
+
+	if revoked, _ := s.IsRevoked("unknown"); revoked {
+		t.Error("unknown token should not be revoked")
+	}
+}
+
+func TestMemoryRevocationStoreClose(t *testing.T) {
+	s := store.NewMemoryRevocationStore(time.Millisecond)
+
+	if err := s.Revoke("live", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke returned error: %v", err)
+	}
+
+	s.Close()
+	s.Close()
+
+	if revoked, _ := s.IsRevoked("live"); !revoked {
+		t.Error("expected a closed store to keep its entries")
+	}
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+)
+
+// MysqlRevocationStore shares revoked token ids between instances through the revoked_access_tokens table
+type MysqlRevocationStore struct {
+	db *sqlx.DB
+}
+
+func NewMysqlRevocationStore() *MysqlRevocationStore {
+	return &MysqlRevocationStore{
+		db: database.Get(),
+	}
+}
+
+func (s *MysqlRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	if !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.Insert("revoked_access_tokens").
+		Rows(goqu.Record{
+			"jti":        jti,
+			"expired_at": expiresAt,
+		}).
+		OnConflict(goqu.DoNothing())
+
+	sql, val, err := dataset.ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	_, err = s.db.Exec(sql, val...)
+	if err != nil {
+		return fmt.Errorf("failed to execute insert: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MysqlRevocationStore) IsRevoked(jti string) (bool, error) {
+	dialect := pkg.GetDialect()
+
+	dataset := dialect.From("revoked_access_tokens").
+		Select(goqu.COUNT("*")).
+		Where(
+			goqu.I("jti").Eq(jti),
+			goqu.I("expired_at").Gt(time.Now()),
+		)
+
+	query, val, err := dataset.ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	var count int
+	if err := s.db.Get(&count, query, val...); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyAuthenticator resolves an API key to its user with its roles and permissions, it
+// returns pkg.ErrInvalidAPIKey for keys that are unknown, expired or revoked
+type APIKeyAuthenticator interface {
+	Authenticate(key string) (user entity.User, apiKey entity.APIKey, err error)
+}
+
+// APIKeyAuthentication authenticates requests carrying an "Authorization: ApiKey <key>" header
+// and hands the others to next, usually Authentication, so a route accepts both. The user and
+// the actor are set like Authentication does and the key is set as "api_key"
+func APIKeyAuthentication(authenticator APIKeyAuthenticator, next fiber.Handler) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		key := apiKeyFromRequest(ctx)
+		if key == "" {
+			return next(ctx)
+		}
+
+		user, apiKey, err := authenticator.Authenticate(key)
+		if errors.Is(err, pkg.ErrInvalidAPIKey) {
+			response := pkg.NewResponse(http.StatusUnauthorized, err.Error(), nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		} else if err != nil {
+			response := pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		user = entity.User{
+			ID:          user.ID,
+			Email:       user.Email,
+			Username:    user.Username,
+			Roles:       user.Roles,
+			Permissions: user.Permissions,
+		}
+
+		ctx.Locals("user", user)
+		ctx.Locals("actor", user)
+		ctx.Locals("api_key", apiKey)
+
+		return ctx.Next()
+	}
+}
+
+// RequireScope refuses requests authenticated by an API key that was not granted scope,
+// sessions of signed in users hold every scope
+func RequireScope(scope string) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		if apiKey, ok := ctx.Locals("api_key").(entity.APIKey); ok && !apiKey.HasScope(scope) {
+			response := pkg.NewResponse(http.StatusForbidden, "api key lacks the "+scope+" scope", nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		return ctx.Next()
+	}
+}
+
+// apiKeyFromRequest reads the key of an "Authorization: ApiKey <key>" header
+func apiKeyFromRequest(ctx *fiber.Ctx) string {
+	scheme, key, found := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+	if found && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(key)
+	}
+
+	return ""
+}
@@ -0,0 +1,83 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+type fakeAPIKeyAuthenticator map[string]entity.APIKey
+
+func (a fakeAPIKeyAuthenticator) Authenticate(key string) (entity.User, entity.APIKey, error) {
+	apiKey, ok := a[key]
+	if !ok {
+		return entity.User{}, entity.APIKey{}, pkg.ErrInvalidAPIKey
+	}
+	return entity.User{ID: apiKey.UserId, Username: "alice", Password: "hash"}, apiKey, nil
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	authenticator := fakeAPIKeyAuthenticator{
+		"ak_read":  {ID: 1, UserId: 7, Scopes: "read"},
+		"ak_admin": {ID: 2, UserId: 7, Scopes: "admin,read"},
+	}
+	// stands in for Authentication, requests without an API key end up here
+	session := func(ctx *fiber.Ctx) error {
+		if ctx.Get(fiber.HeaderAuthorization) != "Bearer token" {
+			return ctx.SendStatus(http.StatusUnauthorized)
+		}
+		ctx.Locals("user", entity.User{ID: 9})
+		return ctx.Next()
+	}
+
+	app := fiber.New()
+	app.Use(middleware.APIKeyAuthentication(authenticator, session))
+	app.Get("/read", middleware.RequireScope(entity.APIKeyScopeRead), func(ctx *fiber.Ctx) error {
+		user := ctx.Locals("user").(entity.User)
+		if user.Password != "" {
+			t.Error("expected the password hash to stay out of the locals")
+		}
+		return ctx.JSON(user.ID)
+	})
+	app.Get("/admin", middleware.RequireScope(entity.APIKeyScopeAdmin), func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{"api key", "/read", "ApiKey ak_read", http.StatusOK},
+		{"scheme is case insensitive", "/read", "apikey ak_read", http.StatusOK},
+		{"unknown api key", "/read", "ApiKey ak_unknown", http.StatusUnauthorized},
+		{"missing scope", "/admin", "ApiKey ak_read", http.StatusForbidden},
+		{"granted scope", "/admin", "ApiKey ak_admin", http.StatusOK},
+		{"session holds every scope", "/admin", "Bearer token", http.StatusOK},
+		{"no credentials", "/read", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
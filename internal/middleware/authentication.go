@@ -1,33 +1,71 @@
 package middleware
 
 import (
-	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
 	"github.com/fazriegi/go-boilerplate/internal/pkg"
 	"github.com/gofiber/fiber/v2"
 )
 
-func Authentication(jwt *pkg.JWT) func(ctx *fiber.Ctx) error {
+func Authentication(jwt *pkg.JWT, revocationStore store.RevocationStore) func(ctx *fiber.Ctx) error {
 	return func(ctx *fiber.Ctx) error {
 		var response = pkg.Response{}
 
-		tokenString := ctx.Cookies("access_token")
+		tokenString := accessTokenFromRequest(ctx)
 
-		verifiedToken, err := jwt.VerifyToken(tokenString, "access")
+		claims, err := jwt.VerifyAccessToken(tokenString)
 		if err != nil {
 			response = pkg.NewResponse(http.StatusUnauthorized, err.Error(), nil, nil)
 			return ctx.Status(response.Code).JSON(response)
 		}
 
-		jsonData, _ := json.Marshal(verifiedToken)
+		if claims.ID == "" {
+			response = pkg.NewResponse(http.StatusUnauthorized, "invalid or expired token", nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		revoked, err := revocationStore.IsRevoked(claims.ID)
+		if err != nil {
+			response = pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		if revoked {
+			response = pkg.NewResponse(http.StatusUnauthorized, "token has been revoked", nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		user := entity.User{
+			ID:          claims.UserID(),
+			Email:       claims.Email,
+			Username:    claims.Username,
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+		}
 
-		var user entity.User
-		json.Unmarshal(jsonData, &user)
+		// user is who the request acts as, actor is who sent it, an admin while impersonating
+		actor := user
+		if claims.Act != nil {
+			actor = entity.User{ID: claims.Act.UserID(), Username: claims.Act.Username}
+		}
 
 		ctx.Locals("user", user)
+		ctx.Locals("actor", actor)
+		ctx.Locals("claims", claims)
 
 		return ctx.Next()
 	}
 }
+
+// accessTokenFromRequest reads the bearer token of the Authorization header and falls back to the cookie
+func accessTokenFromRequest(ctx *fiber.Ctx) string {
+	scheme, token, found := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ctx.Cookies("access_token")
+}
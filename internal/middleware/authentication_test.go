@@ -0,0 +1,96 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAuthenticationReadsBearerBeforeCookie(t *testing.T) {
+	jwt, err := pkg.InitJWT(pkg.JWTConfig{
+		Keyring:        pkg.NewHMACKeyring("test-secret"),
+		Issuer:         "test-issuer",
+		Audience:       []string{"test-audience"},
+		AccessTokenExp: 15 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revocationStore := store.NewMemoryRevocationStore(time.Minute)
+	t.Cleanup(revocationStore.Close)
+
+	app := fiber.New()
+	app.Use(middleware.Authentication(jwt, revocationStore))
+	app.Get("/me", func(ctx *fiber.Ctx) error {
+		return ctx.SendString(ctx.Locals("user").(entity.User).Username)
+	})
+
+	alice, err := jwt.GenerateAccessToken(1, "alice@example.com", "alice", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := jwt.GenerateAccessToken(2, "bob@example.com", "bob", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := jwt.GenerateAccessToken(3, "carol@example.com", "carol", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocationStore.Revoke(revoked.ID, revoked.ExpiresAt)
+
+	tests := []struct {
+		name          string
+		authorization string
+		cookie        string
+		want          int
+		user          string
+	}{
+		{"bearer", "Bearer " + alice.Token, "", http.StatusOK, "alice"},
+		{"bearer scheme is case insensitive", "bearer " + alice.Token, "", http.StatusOK, "alice"},
+		{"cookie", "", bob.Token, http.StatusOK, "bob"},
+		{"bearer wins over cookie", "Bearer " + alice.Token, bob.Token, http.StatusOK, "alice"},
+		{"other scheme falls back to cookie", "Basic YWxpY2U6c2VjcmV0", bob.Token, http.StatusOK, "bob"},
+		{"invalid bearer does not fall back to cookie", "Bearer invalid", bob.Token, http.StatusUnauthorized, ""},
+		{"revoked token", "Bearer " + revoked.Token, "", http.StatusUnauthorized, ""},
+		{"no token", "", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+
+			if tt.user != "" {
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != tt.user {
+					t.Fatalf("expected to authenticate %s, got %s", tt.user, body)
+				}
+			}
+		})
+	}
+}
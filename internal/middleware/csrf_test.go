@@ -0,0 +1,131 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newCSRFApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.CSRF(middleware.CSRFConfig{
+		Exempt:         []string{"/login"},
+		AllowedOrigins: []string{"https://app.example.com"},
+	}))
+
+	ok := func(ctx *fiber.Ctx) error { return ctx.SendStatus(http.StatusOK) }
+	app.Get("/me", ok)
+	app.Post("/login", ok)
+	app.Post("/logout", ok)
+
+	return app
+}
+
+func TestCSRF(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		cookies string
+		headers map[string]string
+		want    int
+	}{
+		{
+			name:    "safe method",
+			method:  http.MethodGet,
+			path:    "/me",
+			cookies: "access_token=a",
+			want:    http.StatusOK,
+		},
+		{
+			name:    "exempt route",
+			method:  http.MethodPost,
+			path:    "/login",
+			cookies: "access_token=a",
+			want:    http.StatusOK,
+		},
+		{
+			name:   "no auth cookies",
+			method: http.MethodPost,
+			path:   "/logout",
+			headers: map[string]string{
+				"Authorization": "Bearer a",
+			},
+			want: http.StatusOK,
+		},
+		{
+			name:    "missing header",
+			method:  http.MethodPost,
+			path:    "/logout",
+			cookies: "refresh_token=r; csrf_token=c",
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "mismatched token",
+			method:  http.MethodPost,
+			path:    "/logout",
+			cookies: "refresh_token=r; csrf_token=c",
+			headers: map[string]string{middleware.CSRFHeaderName: "other"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "matching token",
+			method:  http.MethodPost,
+			path:    "/logout",
+			cookies: "refresh_token=r; csrf_token=c",
+			headers: map[string]string{
+				middleware.CSRFHeaderName: "c",
+				"Origin":                  "https://app.example.com",
+			},
+			want: http.StatusOK,
+		},
+		{
+			name:    "foreign origin",
+			method:  http.MethodPost,
+			path:    "/logout",
+			cookies: "refresh_token=r; csrf_token=c",
+			headers: map[string]string{
+				middleware.CSRFHeaderName: "c",
+				"Origin":                  "https://evil.example.com",
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name:    "foreign referer",
+			method:  http.MethodPost,
+			path:    "/logout",
+			cookies: "refresh_token=r; csrf_token=c",
+			headers: map[string]string{
+				middleware.CSRFHeaderName: "c",
+				"Referer":                 "https://evil.example.com/page",
+			},
+			want: http.StatusForbidden,
+		},
+	}
+
+	app := newCSRFApp()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.cookies != "" {
+				req.Header.Set("Cookie", tt.cookies)
+			}
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+// DenyImpersonation refuses requests of an admin impersonating a user, it guards the actions
+// only the user should take like changing credentials, it must run after Authentication
+func DenyImpersonation() func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		user, userOk := ctx.Locals("user").(entity.User)
+		actor, actorOk := ctx.Locals("actor").(entity.User)
+		if !userOk || !actorOk {
+			response := pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		if actor.ID != user.ID {
+			response := pkg.NewResponse(http.StatusForbidden, pkg.ErrImpersonating.Error(), nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,87 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAuthenticationExposesActorOfImpersonation(t *testing.T) {
+	jwt, err := pkg.InitJWT(pkg.JWTConfig{
+		Keyring:        pkg.NewHMACKeyring("test-secret"),
+		Issuer:         "test-issuer",
+		Audience:       []string{"test-audience"},
+		AccessTokenExp: 15 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revocationStore := store.NewMemoryRevocationStore(time.Minute)
+	t.Cleanup(revocationStore.Close)
+
+	app := fiber.New()
+	app.Use(middleware.Authentication(jwt, revocationStore))
+	app.Get("/me", func(ctx *fiber.Ctx) error {
+		user := ctx.Locals("user").(entity.User)
+		actor := ctx.Locals("actor").(entity.User)
+		return ctx.JSON([]uint{user.ID, actor.ID})
+	})
+	app.Put("/me/password", middleware.DenyImpersonation(), func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(http.StatusOK)
+	})
+
+	own, err := jwt.GenerateAccessToken(1, "alice@example.com", "alice", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonation, err := jwt.GenerateImpersonationToken(2, "bob@example.com", "bob", nil, nil, pkg.NewActorClaim(1, "alice"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		want   int
+		body   string
+	}{
+		{"own session", own.Token, http.MethodGet, "/me", http.StatusOK, "[1,1]"},
+		{"impersonation", impersonation.Token, http.MethodGet, "/me", http.StatusOK, "[2,1]"},
+		{"own session may change password", own.Token, http.MethodPut, "/me/password", http.StatusOK, ""},
+		{"impersonation may not change password", impersonation.Token, http.MethodPut, "/me/password", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+
+			if tt.body != "" {
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != tt.body {
+					t.Fatalf("expected user and actor %s, got %s", tt.body, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+// rate limit algorithms
+const (
+	RateLimitFixedWindow = "fixed_window"
+	RateLimitTokenBucket = "token_bucket"
+)
+
+// what requests are counted by, requests without a user or an API key fall back to their IP
+const (
+	RateLimitByIP     = "ip"
+	RateLimitByUser   = "user"
+	RateLimitByAPIKey = "api_key"
+)
+
+type RateLimitConfig struct {
+	// Name separates the counters of route groups sharing a store
+	Name      string
+	Algorithm string
+	KeyBy     string
+	// Limit is the number of requests per window, or the capacity of the bucket
+	Limit int
+	// Window is the size of a fixed window, or the time a bucket takes to refill completely
+	Window time.Duration
+}
+
+// RateLimitConfigFromEnv reads the limit of the route group name from RATE_LIMIT_<NAME>_LIMIT,
+// RATE_LIMIT_<NAME>_WINDOW_SECOND, RATE_LIMIT_<NAME>_ALGORITHM and RATE_LIMIT_<NAME>_KEY
+func RateLimitConfigFromEnv(name string) RateLimitConfig {
+	prefix := "RATE_LIMIT_" + strings.ToUpper(name) + "_"
+
+	return RateLimitConfig{
+		Name:      name,
+		Algorithm: config.GetString(prefix + "ALGORITHM"),
+		KeyBy:     config.GetString(prefix + "KEY"),
+		Limit:     config.GetInt(prefix + "LIMIT"),
+		Window:    time.Duration(config.GetUint(prefix+"WINDOW_SECOND")) * time.Second,
+	}
+}
+
+// RateLimit counts requests with the configured algorithm and refuses them with 429 once the
+// limit is reached, a limit of 0 disables it. Limits keyed by user must run after Authentication
+func RateLimit(rateLimitStore store.RateLimitStore, cfg RateLimitConfig) func(ctx *fiber.Ctx) error {
+	log := logger.Get()
+
+	return func(ctx *fiber.Ctx) error {
+		if cfg.Limit <= 0 || cfg.Window <= 0 {
+			return ctx.Next()
+		}
+
+		key := "rate:" + cfg.Name + ":" + rateLimitKey(ctx, cfg.KeyBy)
+
+		var (
+			result store.RateLimitResult
+			err    error
+		)
+
+		switch cfg.Algorithm {
+		case RateLimitTokenBucket:
+			result, err = rateLimitStore.TokenBucket(key, cfg.Limit, cfg.Window)
+		default:
+			result, err = rateLimitStore.FixedWindow(key, cfg.Limit, cfg.Window)
+		}
+
+		// an unavailable store must not take the API down with it
+		if err != nil {
+			log.Errorf("rateLimitStore: %s", err.Error())
+			return ctx.Next()
+		}
+
+		now := time.Now()
+		ctx.Set("RateLimit-Limit", strconv.Itoa(cfg.Limit))
+		ctx.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Set("RateLimit-Reset", strconv.Itoa(secondsUntil(result.ResetAt, now)))
+
+		if !result.Allowed {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(secondsUntil(result.RetryAt, now)))
+
+			response := pkg.NewResponse(http.StatusTooManyRequests, "too many requests, try again later", nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		return ctx.Next()
+	}
+}
+
+func rateLimitKey(ctx *fiber.Ctx, keyBy string) string {
+	switch keyBy {
+	case RateLimitByUser:
+		if user, ok := ctx.Locals("user").(entity.User); ok && user.ID != 0 {
+			return "user:" + strconv.FormatUint(uint64(user.ID), 10)
+		}
+	case RateLimitByAPIKey:
+		// the key itself is never kept, only a digest of it
+		if apiKey := apiKeyFromRequest(ctx); apiKey != "" {
+			return "api_key:" + pkg.Hash(apiKey, "rate-limit")
+		}
+	}
+
+	return "ip:" + ctx.IP()
+}
+
+// rounds up so clients never retry a moment too early
+func secondsUntil(t, now time.Time) int {
+	return max(int(math.Ceil(t.Sub(now).Seconds())), 0)
+}
@@ -0,0 +1,175 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func newRateLimitApp(cfg middleware.RateLimitConfig) *fiber.App {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	logger.LOGGER = log
+
+	app := fiber.New()
+	app.Use(func(ctx *fiber.Ctx) error {
+		if username := ctx.Get("X-Test-User"); username != "" {
+			ctx.Locals("user", entity.User{ID: uint(len(username)), Username: username})
+		}
+		return ctx.Next()
+	})
+	app.Get("/", middleware.RateLimit(store.NewMemoryRateLimitStore(time.Minute), cfg), func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(http.StatusOK)
+	})
+
+	return app
+}
+
+func doRequest(t *testing.T, app *fiber.App, headers map[string]string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func TestRateLimitFixedWindow(t *testing.T) {
+	app := newRateLimitApp(middleware.RateLimitConfig{
+		Name:      "test",
+		Algorithm: middleware.RateLimitFixedWindow,
+		KeyBy:     middleware.RateLimitByIP,
+		Limit:     2,
+		Window:    time.Minute,
+	})
+
+	for i, remaining := range []string{"1", "0"} {
+		resp := doRequest(t, app, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i+1, resp.StatusCode)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("request %d: expected remaining %s, got %s", i+1, remaining, got)
+		}
+		if resp.Header.Get("RateLimit-Limit") != "2" || resp.Header.Get("RateLimit-Reset") == "" {
+			t.Errorf("request %d: missing rate limit headers", i+1)
+		}
+	}
+
+	resp := doRequest(t, app, nil)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	var body pkg.Response
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != http.StatusTooManyRequests || body.IsSuccess {
+		t.Errorf("expected the response envelope, got %+v", body)
+	}
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	app := newRateLimitApp(middleware.RateLimitConfig{
+		Name:      "test",
+		Algorithm: middleware.RateLimitTokenBucket,
+		KeyBy:     middleware.RateLimitByIP,
+		Limit:     3,
+		Window:    3 * time.Second,
+	})
+
+	for i := range 3 {
+		if resp := doRequest(t, app, nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected the burst to be allowed, got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	resp := doRequest(t, app, nil)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected empty bucket to refuse, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "1" {
+		t.Errorf("expected to retry after the next token in 1 second, got %s", got)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if resp := doRequest(t, app, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a refilled token to be allowed, got %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		keyBy  string
+		first  map[string]string
+		second map[string]string
+	}{
+		{
+			name:   "user",
+			keyBy:  middleware.RateLimitByUser,
+			first:  map[string]string{"X-Test-User": "alice"},
+			second: map[string]string{"X-Test-User": "bob"},
+		},
+		{
+			name:   "api key",
+			keyBy:  middleware.RateLimitByAPIKey,
+			first:  map[string]string{"Authorization": "ApiKey key-a"},
+			second: map[string]string{"Authorization": "ApiKey key-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newRateLimitApp(middleware.RateLimitConfig{
+				Name:   "test",
+				KeyBy:  tt.keyBy,
+				Limit:  1,
+				Window: time.Minute,
+			})
+
+			if resp := doRequest(t, app, tt.first); resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected first request to be allowed, got %d", resp.StatusCode)
+			}
+			if resp := doRequest(t, app, tt.first); resp.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("expected second request of the same key to be refused, got %d", resp.StatusCode)
+			}
+			if resp := doRequest(t, app, tt.second); resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected another key to have its own limit, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	app := newRateLimitApp(middleware.RateLimitConfig{Name: "test"})
+
+	for range 5 {
+		if resp := doRequest(t, app, nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected disabled limit to allow every request, got %d", resp.StatusCode)
+		}
+	}
+}
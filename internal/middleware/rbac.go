@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRole only lets through users holding one of roles, it must run after Authentication
+func RequireRole(roles ...string) func(ctx *fiber.Ctx) error {
+	return authorize(func(user entity.User) bool {
+		return slices.ContainsFunc(roles, func(role string) bool {
+			return slices.Contains(user.Roles, role)
+		})
+	})
+}
+
+// RequirePermission only lets through users granted permission by any of their roles, it
+// must run after Authentication
+func RequirePermission(permission string) func(ctx *fiber.Ctx) error {
+	return authorize(func(user entity.User) bool {
+		return slices.Contains(user.Permissions, permission)
+	})
+}
+
+func authorize(allowed func(user entity.User) bool) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		user, ok := ctx.Locals("user").(entity.User)
+		if !ok {
+			response := pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		if !allowed(user) {
+			response := pkg.NewResponse(http.StatusForbidden, pkg.ErrForbidden.Error(), nil, nil)
+			return ctx.Status(response.Code).JSON(response)
+		}
+
+		return ctx.Next()
+	}
+}
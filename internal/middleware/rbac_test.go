@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newRBACApp(user *entity.User) *fiber.App {
+	app := fiber.New()
+	app.Use(func(ctx *fiber.Ctx) error {
+		if user != nil {
+			ctx.Locals("user", *user)
+		}
+		return ctx.Next()
+	})
+
+	ok := func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(http.StatusOK)
+	}
+	app.Get("/admin", middleware.RequireRole(entity.RoleAdmin), ok)
+	app.Get("/staff", middleware.RequireRole(entity.RoleAdmin, "support"), ok)
+	app.Get("/users", middleware.RequirePermission(entity.PermissionUsersRead), ok)
+	app.Post("/users", middleware.RequirePermission(entity.PermissionUsersWrite), ok)
+
+	return app
+}
+
+func TestRBAC(t *testing.T) {
+	admin := &entity.User{ID: 1, Roles: []string{entity.RoleAdmin}, Permissions: entity.DefaultPermissions}
+	support := &entity.User{ID: 2, Roles: []string{"support"}, Permissions: []string{entity.PermissionUsersRead}}
+	member := &entity.User{ID: 3}
+
+	tests := []struct {
+		name   string
+		user   *entity.User
+		method string
+		path   string
+		want   int
+	}{
+		{"admin role", admin, http.MethodGet, "/admin", http.StatusOK},
+		{"missing role", support, http.MethodGet, "/admin", http.StatusForbidden},
+		{"any of the roles", support, http.MethodGet, "/staff", http.StatusOK},
+		{"no role", member, http.MethodGet, "/staff", http.StatusForbidden},
+		{"granted permission", support, http.MethodGet, "/users", http.StatusOK},
+		{"missing permission", support, http.MethodPost, "/users", http.StatusForbidden},
+		{"admin permission", admin, http.MethodPost, "/users", http.StatusOK},
+		{"anonymous", nil, http.MethodGet, "/users", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := newRBACApp(tt.user).Test(httptest.NewRequest(tt.method, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
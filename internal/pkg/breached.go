@@ -0,0 +1,236 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// BreachedPasswords tells whether a password is known to have leaked
+type BreachedPasswords interface {
+	Contains(password string) bool
+}
+
+// breached password file formats
+const (
+	BreachedFormatHashes = "hashes"
+	BreachedFormatBloom  = "bloom"
+)
+
+// LoadBreachedPasswords loads a hash list or a bloom filter file, the check is disabled when
+// path is empty
+func LoadBreachedPasswords(format, path string) (BreachedPasswords, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	switch format {
+	case "", BreachedFormatHashes:
+		return LoadBreachedHashList(path)
+	case BreachedFormatBloom:
+		return LoadBreachedBloomFilter(path)
+	default:
+		return nil, fmt.Errorf("unsupported breached password format %q", format)
+	}
+}
+
+// BreachedHashList holds the SHA-1 hashes of leaked passwords in memory, it reads the
+// "HASH" or "HASH:COUNT" lines of the Have I Been Pwned downloads
+type BreachedHashList struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+func LoadBreachedHashList(path string) (*BreachedHashList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	list := &BreachedHashList{hashes: make(map[[sha1.Size]byte]struct{})}
+	err = readBreachedHashes(file, func(hash [sha1.Size]byte) {
+		list.hashes[hash] = struct{}{}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (l *BreachedHashList) Contains(password string) bool {
+	_, ok := l.hashes[sha1.Sum([]byte(password))]
+	return ok
+}
+
+// readBreachedHashes calls add with every hash of a hash list, blank lines are skipped
+func readBreachedHashes(r io.Reader, add func(hash [sha1.Size]byte)) error {
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if text == "" {
+			continue
+		}
+
+		var hash [sha1.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(text)); err != nil || n != sha1.Size {
+			return fmt.Errorf("invalid sha1 hash on line %d of breached password list", line)
+		}
+
+		add(hash)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	return nil
+}
+
+// bloom filter file layout: magic, number of bits (uint64), number of hashes (uint32), bits
+var bloomFilterMagic = []byte("BLOOM1")
+
+// BloomFilter answers whether a password may have leaked in a fraction of the memory of a
+// hash list, at the cost of rare false positives and no false negatives
+type BloomFilter struct {
+	bits   []uint64
+	m      uint64
+	hashes uint32
+}
+
+// NewBloomFilter sizes a filter for n passwords with the false positive rate p
+func NewBloomFilter(n uint64, p float64) (*BloomFilter, error) {
+	if n == 0 || p <= 0 || p >= 1 {
+		return nil, errors.New("bloom filter needs a positive size and a false positive rate between 0 and 1")
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	hashes := uint32(max(math.Round(float64(m)/float64(n)*math.Ln2), 1))
+
+	return newBloomFilter(m, hashes), nil
+}
+
+func newBloomFilter(m uint64, hashes uint32) *BloomFilter {
+	return &BloomFilter{
+		bits:   make([]uint64, (m+63)/64),
+		m:      m,
+		hashes: hashes,
+	}
+}
+
+// BuildBreachedBloomFilter reads a hash list into a filter with the false positive rate p
+func BuildBreachedBloomFilter(r io.Reader, n uint64, p float64) (*BloomFilter, error) {
+	filter, err := NewBloomFilter(n, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := readBreachedHashes(r, filter.AddHash); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func LoadBreachedBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password filter: %w", err)
+	}
+	defer file.Close()
+
+	return ReadBloomFilter(bufio.NewReader(file))
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	magic := make([]byte, len(bloomFilterMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != string(bloomFilterMagic) {
+		return nil, errors.New("not a bloom filter file")
+	}
+
+	var header struct {
+		M      uint64
+		Hashes uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read bloom filter header: %w", err)
+	}
+	if header.M == 0 || header.Hashes == 0 {
+		return nil, errors.New("invalid bloom filter header")
+	}
+
+	filter := newBloomFilter(header.M, header.Hashes)
+	if err := binary.Read(r, binary.BigEndian, filter.bits); err != nil {
+		return nil, fmt.Errorf("failed to read bloom filter: %w", err)
+	}
+
+	return filter, nil
+}
+
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+
+	if _, err := cw.Write(bloomFilterMagic); err != nil {
+		return cw.n, err
+	}
+	if err := binary.Write(cw, binary.BigEndian, struct {
+		M      uint64
+		Hashes uint32
+	}{f.m, f.hashes}); err != nil {
+		return cw.n, err
+	}
+	if err := binary.Write(cw, binary.BigEndian, f.bits); err != nil {
+		return cw.n, err
+	}
+
+	return cw.n, nil
+}
+
+// AddHash adds the SHA-1 hash of a password
+func (f *BloomFilter) AddHash(hash [sha1.Size]byte) {
+	f.each(hash, func(bit uint64) {
+		f.bits[bit/64] |= 1 << (bit % 64)
+	})
+}
+
+func (f *BloomFilter) Add(password string) {
+	f.AddHash(sha1.Sum([]byte(password)))
+}
+
+func (f *BloomFilter) Contains(password string) bool {
+	found := true
+	f.each(sha1.Sum([]byte(password)), func(bit uint64) {
+		found = found && f.bits[bit/64]&(1<<(bit%64)) != 0
+	})
+
+	return found
+}
+
+// each calls fn with every bit of hash, derived from two halves of it by double hashing
+func (f *BloomFilter) each(hash [sha1.Size]byte, fn func(bit uint64)) {
+	h1 := binary.BigEndian.Uint64(hash[0:8])
+	h2 := binary.BigEndian.Uint64(hash[8:16]) | 1
+
+	for i := range uint64(f.hashes) {
+		fn((h1 + i*h2) % f.m)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,127 @@
+package pkg_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+)
+
+// writes a hash list in the Have I Been Pwned format
+func writeHashList(t *testing.T, passwords ...string) string {
+	t.Helper()
+
+	var lines []string
+	for i, password := range passwords {
+		lines = append(lines, fmt.Sprintf("%X:%d", sha1.Sum([]byte(password)), i+1))
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestBreachedHashList(t *testing.T) {
+	path := writeHashList(t, "password", "123456")
+
+	breached, err := pkg.LoadBreachedPasswords(pkg.BreachedFormatHashes, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !breached.Contains("password") || !breached.Contains("123456") {
+		t.Error("expected listed passwords to be breached")
+	}
+	if breached.Contains("Correct-H0rse-Battery") {
+		t.Error("expected other passwords not to be breached")
+	}
+}
+
+func TestBreachedHashListRejectsMalformedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("not-a-hash\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pkg.LoadBreachedHashList(path); err == nil {
+		t.Fatal("expected a malformed line to be rejected")
+	}
+}
+
+func TestBloomFilterRoundTrip(t *testing.T) {
+	list, err := os.Open(writeHashList(t, "password", "123456", "qwerty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+
+	filter, err := pkg.BuildBreachedBloomFilter(list, 3, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := filter.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := pkg.LoadBreachedPasswords(pkg.BreachedFormatBloom, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"password", "123456", "qwerty"} {
+		if !loaded.Contains(password) {
+			t.Errorf("expected %q to be in the filter", password)
+		}
+	}
+	if loaded.Contains("Correct-H0rse-Battery") {
+		t.Error("expected an unlisted password not to be in the filter")
+	}
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	filter, err := pkg.NewBloomFilter(10000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 10000 {
+		filter.Add(fmt.Sprintf("breached-%d", i))
+	}
+
+	falsePositives := 0
+	for i := range 10000 {
+		if filter.Contains(fmt.Sprintf("unlisted-%d", i)) {
+			falsePositives++
+		}
+	}
+
+	if falsePositives > 200 {
+		t.Fatalf("expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+}
+
+func TestLoadBreachedPasswordsDisabled(t *testing.T) {
+	breached, err := pkg.LoadBreachedPasswords("", "")
+	if err != nil || breached != nil {
+		t.Fatalf("expected no check without a file, got %v, %v", breached, err)
+	}
+
+	if _, err := pkg.ReadBloomFilter(strings.NewReader("garbage")); err == nil {
+		t.Fatal("expected a file without the bloom filter header to be rejected")
+	}
+}
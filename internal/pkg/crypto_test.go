@@ -1,6 +1,7 @@
 package pkg_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/fazriegi/go-boilerplate/internal/pkg"
@@ -55,3 +56,14 @@ func TestEncryptDecrypt(t *testing.T) {
 		t.Fatal("Decrypt should fail when using the wrong key")
 	}
 }
+
+func TestGenerateNumericCode(t *testing.T) {
+	code, err := pkg.GenerateNumericCode(6)
+	if err != nil {
+		t.Fatalf("GenerateNumericCode returned error: %v", err)
+	}
+
+	if len(code) != 6 || strings.Trim(code, "0123456789") != "" {
+		t.Fatalf("expected 6 digits, got %q", code)
+	}
+}
@@ -8,4 +8,13 @@ var (
 	ErrParseQueryParam = errors.New("error parsing query param")
 	ErrValidation      = errors.New("validation error")
 	ErrNotAuthorized   = errors.New("you're not authorized")
+	ErrCSRF            = errors.New("invalid csrf token")
+	ErrForbidden       = errors.New("you don't have permission to access this resource")
+	ErrUsernameTaken   = errors.New("username already exists")
+	ErrEmailTaken      = errors.New("email already exists")
+	ErrIdentityLinked  = errors.New("this account is already linked to another user")
+	ErrProviderLinked  = errors.New("an account of this provider is already linked")
+	ErrInvalidAPIKey   = errors.New("invalid, expired or revoked api key")
+	ErrRoleExists      = errors.New("role already exists")
+	ErrImpersonating   = errors.New("this action is not allowed while impersonating")
 )
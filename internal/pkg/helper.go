@@ -3,6 +3,7 @@ package pkg
 import (
 	"errors"
 	"reflect"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -24,6 +25,21 @@ func Intersection[T comparable](slice1, slice2 []T) []T {
 	return matches
 }
 
+// returns s cut down to at most n runes
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n])
+}
+
+// returns the canonical form of a username or an email, both are compared case-insensitively
+func NormalizeIdentifier(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
 func ScanRowsIntoStructs(rows *sqlx.Rows, destSlice interface{}) error {
 	destVal := reflect.ValueOf(destSlice)
 	if destVal.Kind() != reflect.Ptr || destVal.Elem().Kind() != reflect.Slice {
@@ -21,6 +21,43 @@ func TestIntersection(t *testing.T) {
 	}
 }
 
+func TestNormalizeIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"alice":               "alice",
+		"  Alice ":            "alice",
+		"Alice@Example.COM\t": "alice@example.com",
+		"":                    "",
+	}
+
+	for input, expected := range tests {
+		if result := pkg.NormalizeIdentifier(input); result != expected {
+			t.Errorf("NormalizeIdentifier(%q): expected %q, got %q", input, expected, result)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		input    string
+		n        int
+		expected string
+	}{
+		{"firefox", 10, "firefox"},
+		{"firefox", 7, "firefox"},
+		{"firefox", 4, "fire"},
+		// cut by runes, never inside a multi-byte character
+		{"héllo wörld", 7, "héllo w"},
+		{"日本語のブラウザ", 3, "日本語"},
+		{"", 3, ""},
+	}
+
+	for _, tt := range tests {
+		if result := pkg.Truncate(tt.input, tt.n); result != tt.expected {
+			t.Errorf("Truncate(%q, %d): expected %q, got %q", tt.input, tt.n, tt.expected, result)
+		}
+	}
+}
+
 type userRow struct {
 	ID   int    `db:"id"`
 	Name string `db:"name"`
@@ -2,73 +2,357 @@ package pkg
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+type JWTConfig struct {
+	Keyring         *Keyring
+	Issuer          string
+	Audience        []string
+	Leeway          time.Duration
+	AccessTokenExp  time.Duration
+	RefreshTokenExp time.Duration
+	MFATokenExp     time.Duration
+}
+
 type JWT struct {
-	secretKey       string
-	accessTokenExp  uint
-	refreshTokenExp uint
+	keyring         *Keyring
+	issuer          string
+	audience        []string
+	leeway          time.Duration
+	accessTokenExp  time.Duration
+	refreshTokenExp time.Duration
+	mfaTokenExp     time.Duration
 }
 
-func InitJWT(secretKey string, accessTokenExp, refreshTokenExp uint) *JWT {
+func InitJWT(cfg JWTConfig) (*JWT, error) {
+	if cfg.Keyring == nil {
+		return nil, errors.New("jwt keyring is required")
+	}
+
+	var audience []string
+	for _, aud := range cfg.Audience {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			audience = append(audience, aud)
+		}
+	}
+
+	if cfg.Issuer == "" || len(audience) == 0 {
+		return nil, errors.New("jwt issuer and audience are required")
+	}
+
 	return &JWT{
-		secretKey,
-		accessTokenExp,
-		refreshTokenExp,
+		keyring:         cfg.Keyring,
+		issuer:          cfg.Issuer,
+		audience:        audience,
+		leeway:          cfg.Leeway,
+		accessTokenExp:  cfg.AccessTokenExp,
+		refreshTokenExp: cfg.RefreshTokenExp,
+		mfaTokenExp:     cfg.MFATokenExp,
+	}, nil
+}
+
+// Keyring returns the keys tokens are signed and verified with
+func (j JWT) Keyring() *Keyring {
+	return j.keyring
+}
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeMFA     = "mfa"
+)
+
+// AccessClaims are the claims of an access token, the user id is carried in sub. Roles and
+// Permissions are read when the token is issued, changes apply from the next refresh
+type AccessClaims struct {
+	Email       string   `json:"email"`
+	Username    string   `json:"username"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Type        string   `json:"type"`
+	// Act is set on the tokens of an admin impersonating the user
+	Act *ActorClaim `json:"act,omitempty"`
+	jwt.RegisteredClaims
+
+	userID uint
+}
+
+// UserID returns the user id parsed from sub while the token was verified
+func (c *AccessClaims) UserID() uint {
+	return c.userID
+}
+
+// ActorClaim is the act claim (RFC 8693) of an impersonation token, it identifies the admin
+// acting as the user of the token
+type ActorClaim struct {
+	Subject  string `json:"sub"`
+	Username string `json:"username"`
+
+	userID uint
+}
+
+func NewActorClaim(userID uint, username string) *ActorClaim {
+	return &ActorClaim{
+		Subject:  strconv.FormatUint(uint64(userID), 10),
+		Username: username,
+		userID:   userID,
 	}
 }
 
-func (j JWT) GenerateAccessToken(userID uint, email string, username string) (string, error) {
-	claims := jwt.MapClaims{
-		"id":       userID,
-		"email":    email,
-		"username": username,
-		"exp":      time.Now().Add(time.Duration(j.accessTokenExp) * time.Minute).Unix(),
-		"iat":      time.Now().Unix(),
-		"type":     "access",
+// UserID returns the user id of the actor parsed from sub
+func (a *ActorClaim) UserID() uint {
+	return a.userID
+}
+
+// RefreshClaims are the claims of a refresh token, the user id is carried in sub
+type RefreshClaims struct {
+	Type string `json:"type"`
+	jwt.RegisteredClaims
+
+	userID uint
+}
+
+// UserID returns the user id parsed from sub while the token was verified
+func (c *RefreshClaims) UserID() uint {
+	return c.userID
+}
+
+// MFAClaims are the claims of the challenge token handed out after the password step of a login
+// of a user with two-factor authentication, it carries what the second step needs to open the session
+type MFAClaims struct {
+	DeviceLabel string `json:"device_label,omitempty"`
+	Type        string `json:"type"`
+	jwt.RegisteredClaims
+
+	userID uint
+}
+
+// UserID returns the user id parsed from sub while the token was verified
+func (c *MFAClaims) UserID() uint {
+	return c.userID
+}
+
+// AccessToken is a signed access token together with the claims needed to revoke it
+type AccessToken struct {
+	Token     string
+	ID        string
+	ExpiresAt time.Time
+}
+
+func (j JWT) GenerateAccessToken(userID uint, email, username string, roles, permissions []string) (AccessToken, error) {
+	claims := AccessClaims{
+		Email:       email,
+		Username:    username,
+		Roles:       roles,
+		Permissions: permissions,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return j.generateAccessToken(userID, claims, j.accessTokenExp)
+}
 
-	return token.SignedString([]byte(j.secretKey))
+// GenerateImpersonationToken issues an access token of the user carrying actor in the act
+// claim, it lives for exp and comes without a refresh token
+func (j JWT) GenerateImpersonationToken(userID uint, email, username string, roles, permissions []string, actor *ActorClaim, exp time.Duration) (AccessToken, error) {
+	claims := AccessClaims{
+		Email:       email,
+		Username:    username,
+		Roles:       roles,
+		Permissions: permissions,
+		Act:         actor,
+	}
+
+	return j.generateAccessToken(userID, claims, exp)
+}
+
+func (j JWT) generateAccessToken(userID uint, claims AccessClaims, exp time.Duration) (AccessToken, error) {
+	jti, err := GenerateRandomString(16)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(exp)
+	claims.Type = TokenTypeAccess
+	claims.RegisteredClaims = j.registeredClaims(userID, jti, now, expiresAt)
+
+	signed, err := j.sign(claims)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	return AccessToken{
+		Token:     signed,
+		ID:        jti,
+		ExpiresAt: expiresAt,
+	}, nil
 }
 
 func (j JWT) GenerateRefreshToken(userID uint) (string, error) {
-	claims := jwt.MapClaims{
-		"id":   userID,
-		"exp":  time.Now().Add(time.Duration(j.refreshTokenExp) * 24 * time.Hour).Unix(),
-		"iat":  time.Now().Unix(),
-		"type": "refresh",
+	// a random jti keeps tokens issued for the same user within the same second unique,
+	// every session is looked up by the hash of its token
+	jti, err := GenerateRandomString(16)
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	now := time.Now()
+	claims := RefreshClaims{
+		Type:             TokenTypeRefresh,
+		RegisteredClaims: j.registeredClaims(userID, jti, now, now.Add(j.refreshTokenExp)),
+	}
 
-	return token.SignedString([]byte(j.secretKey))
+	return j.sign(claims)
 }
 
-func (j JWT) VerifyToken(tokenString string, expectedType string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
+func (j JWT) GenerateMFAToken(userID uint, deviceLabel string) (string, error) {
+	jti, err := GenerateRandomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := MFAClaims{
+		DeviceLabel:      deviceLabel,
+		Type:             TokenTypeMFA,
+		RegisteredClaims: j.registeredClaims(userID, jti, now, now.Add(j.mfaTokenExp)),
+	}
+
+	return j.sign(claims)
+}
+
+func (j JWT) registeredClaims(userID uint, jti string, now, expiresAt time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    j.issuer,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		Audience:  j.audience,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        jti,
+	}
+}
+
+// signs claims with the active key of the keyring, the kid header tells verifiers which key to use
+func (j JWT) sign(claims jwt.Claims) (string, error) {
+	key := j.keyring.Active()
+
+	method := jwt.GetSigningMethod(key.Algorithm)
+	if method == nil {
+		return "", errors.New("unsupported signing method")
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.PrivateKey)
+}
+
+func (j JWT) VerifyAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	if err := j.verify(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Type != TokenTypeAccess {
+		return nil, errors.New("invalid token type")
+	}
+
+	userID, err := parseSubject(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	claims.userID = userID
+
+	if claims.Act != nil {
+		if claims.Act.userID, err = parseSubject(claims.Act.Subject); err != nil {
+			return nil, err
 		}
-		return []byte(j.secretKey), nil
-	})
+	}
+
+	return claims, nil
+}
+
+func (j JWT) VerifyRefreshToken(tokenString string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if err := j.verify(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Type != TokenTypeRefresh {
+		return nil, errors.New("invalid token type")
+	}
 
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid or expired token")
+	userID, err := parseSubject(claims.Subject)
+	if err != nil {
+		return nil, err
 	}
+	claims.userID = userID
+
+	return claims, nil
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("invalid claims")
+func (j JWT) VerifyMFAToken(tokenString string) (*MFAClaims, error) {
+	claims := &MFAClaims{}
+	if err := j.verify(tokenString, claims); err != nil {
+		return nil, err
 	}
 
-	if claims["type"] != expectedType {
+	if claims.Type != TokenTypeMFA {
 		return nil, errors.New("invalid token type")
 	}
 
+	userID, err := parseSubject(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	claims.userID = userID
+
 	return claims, nil
 }
+
+func (j JWT) verify(tokenString string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		kid, ok := t.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("missing key id")
+		}
+
+		key, err := j.keyring.Get(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// the algorithm is bound to the key, never to the header of the token
+		if t.Method.Alg() != key.Algorithm {
+			return nil, errors.New("invalid signing method")
+		}
+
+		return key.verificationKey(), nil
+	},
+		jwt.WithIssuer(j.issuer),
+		jwt.WithAudience(j.audience...),
+		jwt.WithLeeway(j.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	if err != nil {
+		return errors.New("invalid or expired token")
+	}
+
+	return nil
+}
+
+func parseSubject(sub string) (uint, error) {
+	userID, err := strconv.ParseUint(sub, 10, 64)
+	if err != nil || userID == 0 {
+		return 0, errors.New("invalid token subject")
+	}
+
+	return uint(userID), nil
+}
@@ -0,0 +1,363 @@
+package pkg
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is a single key of the keyring, PrivateKey holds the HMAC secret ([]byte),
+// *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey depending on Algorithm
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey any
+	RetiredAt  *time.Time
+}
+
+// returns the key used to verify signatures, the secret itself for HMAC keys
+func (k *SigningKey) verificationKey() any {
+	switch key := k.PrivateKey.(type) {
+	case crypto.Signer:
+		return key.Public()
+	default:
+		return key
+	}
+}
+
+func (k *SigningKey) validate() error {
+	if k.ID == "" {
+		return errors.New("key id is required")
+	}
+
+	var ok bool
+	switch k.Algorithm {
+	case AlgHS256:
+		var secret []byte
+		secret, ok = k.PrivateKey.([]byte)
+		ok = ok && len(secret) > 0
+	case AlgRS256:
+		_, ok = k.PrivateKey.(*rsa.PrivateKey)
+	case AlgES256:
+		var key *ecdsa.PrivateKey
+		key, ok = k.PrivateKey.(*ecdsa.PrivateKey)
+		ok = ok && key.Curve == elliptic.P256()
+	case AlgEdDSA:
+		_, ok = k.PrivateKey.(ed25519.PrivateKey)
+	default:
+		return fmt.Errorf("key %s: unsupported algorithm %q", k.ID, k.Algorithm)
+	}
+
+	if !ok {
+		return fmt.Errorf("key %s: private key does not match algorithm %s", k.ID, k.Algorithm)
+	}
+
+	return nil
+}
+
+// Keyring holds the active signing key and the retired keys that are still accepted for
+// verification. A key is rotated by making it the active_kid of the keyring file and giving
+// the previous key a retired_at, the file is read on start
+type Keyring struct {
+	active      *SigningKey
+	keys        map[string]*SigningKey
+	gracePeriod time.Duration
+}
+
+// NewKeyring creates a keyring signing with active, retired keys stay valid for gracePeriod after RetiredAt
+func NewKeyring(active SigningKey, gracePeriod time.Duration, retired ...SigningKey) (*Keyring, error) {
+	k := &Keyring{
+		keys:        make(map[string]*SigningKey),
+		gracePeriod: gracePeriod,
+	}
+
+	if err := active.validate(); err != nil {
+		return nil, err
+	}
+	active.RetiredAt = nil
+	k.active = &active
+	k.keys[active.ID] = k.active
+
+	for i := range retired {
+		key := retired[i]
+		if err := key.validate(); err != nil {
+			return nil, err
+		}
+
+		if key.RetiredAt == nil {
+			return nil, fmt.Errorf("key %s: retired keys must have a retirement time", key.ID)
+		}
+
+		if _, exists := k.keys[key.ID]; exists {
+			return nil, fmt.Errorf("key %s: duplicate key id", key.ID)
+		}
+
+		k.keys[key.ID] = &key
+	}
+
+	return k, nil
+}
+
+// NewHMACKeyring creates a keyring with a single HS256 key, the kid is derived from the secret
+func NewHMACKeyring(secret string) *Keyring {
+	key := SigningKey{
+		ID:         Hash("jwt-kid", secret)[:16],
+		Algorithm:  AlgHS256,
+		PrivateKey: []byte(secret),
+	}
+
+	return &Keyring{
+		active: &key,
+		keys:   map[string]*SigningKey{key.ID: &key},
+	}
+}
+
+// Active returns the key new tokens are signed with
+func (k *Keyring) Active() *SigningKey {
+	return k.active
+}
+
+// Get returns the key identified by kid as long as it is active or within the grace period
+func (k *Keyring) Get(kid string) (*SigningKey, error) {
+	key, ok := k.keys[kid]
+	if !ok || !k.usable(key) {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (k *Keyring) usable(key *SigningKey) bool {
+	return key.RetiredAt == nil || time.Now().Before(key.RetiredAt.Add(k.gracePeriod))
+}
+
+// JSONWebKey is the public part of a signing key as described by RFC 7517
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// PublicKey decodes the RSA, P-256 or Ed25519 public key of the JWK
+func (k JSONWebKey) PublicKey() (any, error) {
+	decode := func(field, value string) ([]byte, error) {
+		bytes, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(bytes) == 0 {
+			return nil, fmt.Errorf("key %s: invalid %s", k.Kid, field)
+		}
+		return bytes, nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) > 4 {
+			return nil, fmt.Errorf("key %s: invalid e", k.Kid)
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != elliptic.P256().Params().Name {
+			return nil, fmt.Errorf("key %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("key %s: point is not on the curve", k.Kid)
+		}
+
+		return key, nil
+	case "OKP":
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %q", k.Kid, k.Kty)
+	}
+}
+
+// JWKS returns the public keys of every usable asymmetric key, HMAC secrets are never published.
+// The keys are sorted by kid so the set stays the same between requests and caches of it hold
+func (k *Keyring) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range k.keys {
+		if !k.usable(key) {
+			continue
+		}
+
+		jwk := JSONWebKey{
+			Kid: key.ID,
+			Use: "sig",
+			Alg: key.Algorithm,
+		}
+
+		switch publicKey := key.verificationKey().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (publicKey.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = publicKey.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	slices.SortFunc(set.Keys, func(a, b JSONWebKey) int { return strings.Compare(a.Kid, b.Kid) })
+
+	return set
+}
+
+type keyringFile struct {
+	ActiveKid string `json:"active_kid"`
+	Keys      []struct {
+		Kid            string     `json:"kid"`
+		Alg            string     `json:"alg"`
+		PrivateKeyFile string     `json:"private_key_file"`
+		RetiredAt      *time.Time `json:"retired_at"`
+	} `json:"keys"`
+}
+
+// LoadKeyring reads a JSON keyring file, private_key_file paths are relative to the keyring file
+//
+//	{
+//	  "active_kid": "2026-10",
+//	  "keys": [
+//	    {"kid": "2026-10", "alg": "ES256", "private_key_file": "2026-10.pem"},
+//	    {"kid": "2026-09", "alg": "RS256", "private_key_file": "2026-09.pem", "retired_at": "2026-10-01T00:00:00Z"}
+//	  ]
+//	}
+func LoadKeyring(path string, gracePeriod time.Duration) (*Keyring, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file: %w", err)
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring file: %w", err)
+	}
+
+	var (
+		active  *SigningKey
+		retired []SigningKey
+	)
+
+	for _, entry := range file.Keys {
+		keyPath := entry.PrivateKeyFile
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(filepath.Dir(path), keyPath)
+		}
+
+		pemData, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: failed to read private key: %w", entry.Kid, err)
+		}
+
+		privateKey, err := ParsePrivateKeyPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", entry.Kid, err)
+		}
+
+		key := SigningKey{
+			ID:         entry.Kid,
+			Algorithm:  entry.Alg,
+			PrivateKey: privateKey,
+			RetiredAt:  entry.RetiredAt,
+		}
+
+		if entry.Kid == file.ActiveKid {
+			active = &key
+			continue
+		}
+
+		retired = append(retired, key)
+	}
+
+	if active == nil {
+		return nil, fmt.Errorf("active key %q is not in the keyring", file.ActiveKid)
+	}
+
+	return NewKeyring(*active, gracePeriod, retired...)
+}
+
+// ParsePrivateKeyPEM parses PKCS#8, PKCS#1 (RSA) and SEC 1 (EC) encoded private keys
+func ParsePrivateKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
@@ -0,0 +1,348 @@
+package pkg_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+func generateSigningKeys(t *testing.T) map[string]any {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]any{
+		pkg.AlgHS256: []byte("test-secret"),
+		pkg.AlgRS256: rsaKey,
+		pkg.AlgES256: ecKey,
+		pkg.AlgEdDSA: edKey,
+	}
+}
+
+func newJWT(t *testing.T, keyring *pkg.Keyring) *pkg.JWT {
+	t.Helper()
+
+	jwt, err := pkg.InitJWT(pkg.JWTConfig{
+		Keyring:         keyring,
+		Issuer:          "https://auth.example.com",
+		Audience:        []string{"api"},
+		Leeway:          30 * time.Second,
+		AccessTokenExp:  15 * time.Minute,
+		RefreshTokenExp: 7 * 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("InitJWT returned error: %v", err)
+	}
+
+	return jwt
+}
+
+func TestJWTSignAndVerifyPerAlgorithm(t *testing.T) {
+	for alg, privateKey := range generateSigningKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			keyring, err := pkg.NewKeyring(pkg.SigningKey{ID: "key-" + alg, Algorithm: alg, PrivateKey: privateKey}, time.Hour)
+			if err != nil {
+				t.Fatalf("NewKeyring returned error: %v", err)
+			}
+
+			jwt := newJWT(t, keyring)
+
+			accessToken, err := jwt.GenerateAccessToken(1, "alice@example.com", "alice", []string{"admin"}, []string{"users:read"})
+			if err != nil {
+				t.Fatalf("GenerateAccessToken returned error: %v", err)
+			}
+
+			claims, err := jwt.VerifyAccessToken(accessToken.Token)
+			if err != nil {
+				t.Fatalf("VerifyAccessToken returned error: %v", err)
+			}
+
+			if claims.ID != accessToken.ID {
+				t.Errorf("expected jti %q, got %q", accessToken.ID, claims.ID)
+			}
+
+			if claims.UserID() != 1 || claims.Username != "alice" || claims.Email != "alice@example.com" ||
+				!slices.Equal(claims.Roles, []string{"admin"}) || !slices.Equal(claims.Permissions, []string{"users:read"}) {
+				t.Errorf("unexpected claims: %+v", claims)
+			}
+
+			if _, err := jwt.VerifyRefreshToken(accessToken.Token); err == nil {
+				t.Error("VerifyRefreshToken should reject a token of another type")
+			}
+		})
+	}
+}
+
+func TestJWTImpersonationTokenCarriesActor(t *testing.T) {
+	jwt := newJWT(t, pkg.NewHMACKeyring("secret"))
+
+	accessToken, err := jwt.GenerateImpersonationToken(2, "bob@example.com", "bob", nil, nil, pkg.NewActorClaim(1, "alice"), 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wait := time.Until(accessToken.ExpiresAt); wait > 5*time.Minute || wait < 4*time.Minute {
+		t.Fatalf("expected the token to live for the given duration, expires in %s", wait)
+	}
+
+	claims, err := jwt.VerifyAccessToken(accessToken.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.UserID() != 2 || claims.Act == nil || claims.Act.UserID() != 1 || claims.Act.Username != "alice" {
+		t.Fatalf("expected bob acted as by alice, got %+v", claims)
+	}
+
+	regular, err := jwt.GenerateAccessToken(1, "alice@example.com", "alice", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := jwt.VerifyAccessToken(regular.Token); err != nil || claims.Act != nil {
+		t.Fatalf("expected a regular token without actor, got %v", err)
+	}
+}
+
+func TestJWTRejectsUnknownKey(t *testing.T) {
+	signer := newJWT(t, pkg.NewHMACKeyring("secret-a"))
+	verifier := newJWT(t, pkg.NewHMACKeyring("secret-b"))
+
+	token, err := signer.GenerateRefreshToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifier.VerifyRefreshToken(token); err == nil {
+		t.Fatal("VerifyRefreshToken should reject tokens signed with an unknown key")
+	}
+}
+
+func TestJWTValidatesIssuerAndAudience(t *testing.T) {
+	keyring := pkg.NewHMACKeyring("secret")
+	token, err := newJWT(t, keyring).GenerateRefreshToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		issuer   string
+		audience []string
+	}{
+		{"other issuer", "https://other.example.com", []string{"api"}},
+		{"other audience", "https://auth.example.com", []string{"admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := pkg.InitJWT(pkg.JWTConfig{
+				Keyring:         keyring,
+				Issuer:          tt.issuer,
+				Audience:        tt.audience,
+				RefreshTokenExp: time.Hour,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := verifier.VerifyRefreshToken(token); err == nil {
+				t.Fatal("VerifyRefreshToken should reject the token")
+			}
+		})
+	}
+
+	if _, err := pkg.InitJWT(pkg.JWTConfig{Keyring: keyring, Audience: []string{""}}); err == nil {
+		t.Fatal("InitJWT should require an issuer and an audience")
+	}
+}
+
+func TestJWTRejectsMalformedSubject(t *testing.T) {
+	keyring := pkg.NewHMACKeyring("secret")
+	key := keyring.Active()
+
+	token := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, jwtlib.MapClaims{
+		"iss":  "https://auth.example.com",
+		"aud":  "api",
+		"sub":  "not-a-number",
+		"exp":  time.Now().Add(time.Minute).Unix(),
+		"type": pkg.TokenTypeAccess,
+	})
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newJWT(t, keyring).VerifyAccessToken(signed); err == nil {
+		t.Fatal("VerifyAccessToken should reject a signed token with a malformed subject")
+	}
+}
+
+func TestKeyringRotationGracePeriod(t *testing.T) {
+	keys := generateSigningKeys(t)
+
+	keyring, err := pkg.NewKeyring(pkg.SigningKey{ID: "old", Algorithm: pkg.AlgRS256, PrivateKey: keys[pkg.AlgRS256]}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := newJWT(t, keyring)
+
+	oldToken, err := jwt.GenerateRefreshToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old key was retired when the new one became active
+	retiredAt := time.Now()
+	keyring, err = pkg.NewKeyring(
+		pkg.SigningKey{ID: "new", Algorithm: pkg.AlgES256, PrivateKey: keys[pkg.AlgES256]},
+		time.Hour,
+		pkg.SigningKey{ID: "old", Algorithm: pkg.AlgRS256, PrivateKey: keys[pkg.AlgRS256], RetiredAt: &retiredAt},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newJWT(t, keyring).VerifyRefreshToken(oldToken); err != nil {
+		t.Fatalf("token of a retired key should verify within the grace period: %v", err)
+	}
+
+	if keyring.Active().ID != "new" {
+		t.Fatalf("expected new key to be active, got %s", keyring.Active().ID)
+	}
+
+	if got := keyring.JWKS().Keys; len(got) != 2 || got[0].Kid != "new" || got[1].Kid != "old" {
+		t.Fatalf("expected 2 published keys sorted by kid, got %+v", got)
+	}
+
+	retiredAt = time.Now().Add(-2 * time.Hour)
+	expired, err := pkg.NewKeyring(
+		pkg.SigningKey{ID: "new", Algorithm: pkg.AlgES256, PrivateKey: keys[pkg.AlgES256]},
+		time.Hour,
+		pkg.SigningKey{ID: "old", Algorithm: pkg.AlgRS256, PrivateKey: keys[pkg.AlgRS256], RetiredAt: &retiredAt},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newJWT(t, expired).VerifyRefreshToken(oldToken); err == nil {
+		t.Fatal("token of a key retired beyond the grace period should be rejected")
+	}
+
+	if got := len(expired.JWKS().Keys); got != 1 {
+		t.Fatalf("expected 1 published key, got %d", got)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	keys := generateSigningKeys(t)
+	dir := t.TempDir()
+
+	ecDER, err := x509.MarshalECPrivateKey(keys[pkg.AlgES256].(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edDER, err := x509.MarshalPKCS8PrivateKey(keys[pkg.AlgEdDSA])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("ec.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}))
+	writeFile("ed.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}))
+
+	keyringJSON, _ := json.Marshal(map[string]any{
+		"active_kid": "ed",
+		"keys": []map[string]any{
+			{"kid": "ed", "alg": pkg.AlgEdDSA, "private_key_file": "ed.pem"},
+			{"kid": "ec", "alg": pkg.AlgES256, "private_key_file": "ec.pem", "retired_at": time.Now().Format(time.RFC3339)},
+		},
+	})
+	writeFile("keyring.json", keyringJSON)
+
+	keyring, err := pkg.LoadKeyring(filepath.Join(dir, "keyring.json"), time.Hour)
+	if err != nil {
+		t.Fatalf("LoadKeyring returned error: %v", err)
+	}
+
+	if keyring.Active().ID != "ed" {
+		t.Fatalf("expected ed to be active, got %s", keyring.Active().ID)
+	}
+
+	for _, jwk := range keyring.JWKS().Keys {
+		switch jwk.Kid {
+		case "ed":
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.X == "" {
+				t.Errorf("unexpected Ed25519 jwk: %+v", jwk)
+			}
+		case "ec":
+			if jwk.Kty != "EC" || jwk.Crv != "P-256" || jwk.X == "" || jwk.Y == "" {
+				t.Errorf("unexpected EC jwk: %+v", jwk)
+			}
+		default:
+			t.Errorf("unexpected jwk %s", jwk.Kid)
+		}
+	}
+}
+
+func TestJSONWebKeyPublicKeyRoundTrip(t *testing.T) {
+	keys := generateSigningKeys(t)
+
+	for _, alg := range []string{pkg.AlgRS256, pkg.AlgES256, pkg.AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			keyring, err := pkg.NewKeyring(pkg.SigningKey{ID: alg, Algorithm: alg, PrivateKey: keys[alg]}, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			publicKey, err := keyring.JWKS().Keys[0].PublicKey()
+			if err != nil {
+				t.Fatalf("PublicKey returned error: %v", err)
+			}
+
+			expected := keys[alg].(interface{ Public() crypto.PublicKey }).Public()
+			if !expected.(interface{ Equal(crypto.PublicKey) bool }).Equal(publicKey) {
+				t.Fatal("expected the decoded key to equal the signing key")
+			}
+		})
+	}
+
+	if _, err := (pkg.JSONWebKey{Kty: "EC", Crv: "P-384", X: "AQ", Y: "AQ"}).PublicKey(); err == nil {
+		t.Fatal("expected an unsupported curve to be rejected")
+	}
+}
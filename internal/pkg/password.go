@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// supported password hash algorithms
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+// PasswordHasher hashes passwords into a self-describing string that records the algorithm,
+// its version and its parameters, so hashes of older configurations can still be verified
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify compares password with a hash of the hasher's format, using the parameters
+	// recorded in the hash
+	Verify(password, hash string) (bool, error)
+	// Supports reports whether hash is of the hasher's format
+	Supports(hash string) bool
+	// NeedsRehash reports whether hash was made with other parameters than the hasher's
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idHasher produces PHC formatted argon2id hashes,
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2idHasher returns a hasher with the parameters recommended by OWASP
+func NewArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{
+		Memory:      19 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(password, hash string) (bool, error) {
+	parsed, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), parsed.salt, parsed.Iterations, parsed.Memory, parsed.Parallelism, uint32(len(parsed.key)))
+
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1, nil
+}
+
+func (h Argon2idHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	parsed, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return parsed.Memory != h.Memory ||
+		parsed.Iterations != h.Iterations ||
+		parsed.Parallelism != h.Parallelism ||
+		uint32(len(parsed.salt)) != h.SaltLength ||
+		uint32(len(parsed.key)) != h.KeyLength
+}
+
+type argon2idHash struct {
+	Argon2idHasher
+	salt []byte
+	key  []byte
+}
+
+func parseArgon2id(hash string) (result argon2idHash, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return result, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return result, ErrInvalidPasswordHash
+	}
+	if version != argon2.Version {
+		return result, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &result.Memory, &result.Iterations, &result.Parallelism); err != nil {
+		return result, ErrInvalidPasswordHash
+	}
+	// argon2 panics on zero passes or lanes
+	if result.Memory == 0 || result.Iterations == 0 || result.Parallelism == 0 {
+		return result, ErrInvalidPasswordHash
+	}
+
+	if result.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return result, ErrInvalidPasswordHash
+	}
+	if result.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(result.key) == 0 {
+		return result, ErrInvalidPasswordHash
+	}
+
+	return result, nil
+}
+
+// BcryptMaxPasswordBytes is the most bcrypt reads of a password, longer ones are refused by Hash
+const BcryptMaxPasswordBytes = 72
+
+// BcryptHasher produces $2a$ bcrypt hashes of passwords up to BcryptMaxPasswordBytes
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+func (h BcryptHasher) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (h BcryptHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+type PasswordHashConfig struct {
+	// Algorithm is argon2id or bcrypt, defaults to argon2id
+	Algorithm string
+	// parameters left at 0 use the defaults of NewArgon2idHasher
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	// defaults to bcrypt.DefaultCost
+	BcryptCost int
+}
+
+// NewPasswordHasher returns the hasher of the configured algorithm
+func NewPasswordHasher(cfg PasswordHashConfig) (PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case "", PasswordHashArgon2id:
+		hasher := NewArgon2idHasher()
+		if cfg.Argon2Memory > 0 {
+			hasher.Memory = cfg.Argon2Memory
+		}
+		if cfg.Argon2Iterations > 0 {
+			hasher.Iterations = cfg.Argon2Iterations
+		}
+		if cfg.Argon2Parallelism > 0 {
+			hasher.Parallelism = cfg.Argon2Parallelism
+		}
+		return hasher, nil
+	case PasswordHashBcrypt:
+		cost := cfg.BcryptCost
+		if cost == 0 {
+			cost = bcrypt.DefaultCost
+		}
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return BcryptHasher{Cost: cost}, nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+}
+
+var (
+	passwordHasher PasswordHasher = NewArgon2idHasher()
+
+	// every format a stored hash can be in, their parameters are read from the hash
+	passwordVerifiers = []PasswordHasher{Argon2idHasher{}, BcryptHasher{}}
+)
+
+// SetPasswordHasher replaces the hasher new passwords are hashed with
+func SetPasswordHasher(hasher PasswordHasher) {
+	passwordHasher = hasher
+}
+
+func HashPassword(password string) (string, error) {
+	return passwordHasher.Hash(password)
+}
+
+// CheckPasswordHash compares password with a hash of any supported format
+func CheckPasswordHash(password, hash string) bool {
+	for _, verifier := range passwordVerifiers {
+		if verifier.Supports(hash) {
+			ok, err := verifier.Verify(password, hash)
+			return err == nil && ok
+		}
+	}
+
+	return false
+}
+
+// PasswordNeedsRehash reports whether hash is of another algorithm or other parameters than
+// the current hasher, it should be replaced after the password was verified
+func PasswordNeedsRehash(hash string) bool {
+	return !passwordHasher.Supports(hash) || passwordHasher.NeedsRehash(hash)
+}
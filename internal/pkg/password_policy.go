@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// password policy violations, reported as the tag of a ValidationErrResponse
+const (
+	PasswordTagMin              = "min"
+	PasswordTagMax              = "max"
+	PasswordTagMaxBytes         = "max_bytes"
+	PasswordTagLowercase        = "lowercase"
+	PasswordTagUppercase        = "uppercase"
+	PasswordTagDigit            = "digit"
+	PasswordTagSymbol           = "symbol"
+	PasswordTagContainsUsername = "contains_username"
+	PasswordTagContainsEmail    = "contains_email"
+	PasswordTagBreached         = "breached"
+)
+
+// identities shorter than this are too common to be refused inside a password
+const minIdentityLength = 3
+
+type PasswordPolicyConfig struct {
+	// lengths are counted in characters, 0 uses the defaults of 8 and 64
+	MinLength int
+	MaxLength int
+	// MaxBytes caps the UTF-8 encoded length, 0 leaves it uncapped. It is set to
+	// BcryptMaxPasswordBytes when passwords are hashed with bcrypt
+	MaxBytes int
+
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+
+	// DisallowIdentity refuses passwords containing the username or the email of the user
+	DisallowIdentity bool
+
+	// Breached refuses known leaked passwords, nil disables the check
+	Breached BreachedPasswords
+}
+
+// PasswordPolicy decides whether a new password is acceptable
+type PasswordPolicy struct {
+	cfg PasswordPolicyConfig
+}
+
+func NewPasswordPolicy(cfg PasswordPolicyConfig) (*PasswordPolicy, error) {
+	if cfg.MinLength == 0 {
+		cfg.MinLength = 8
+	}
+	if cfg.MaxLength == 0 {
+		cfg.MaxLength = 64
+	}
+
+	if cfg.MinLength < 1 || cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("invalid password length range %d-%d", cfg.MinLength, cfg.MaxLength)
+	}
+
+	return &PasswordPolicy{cfg}, nil
+}
+
+// Validate returns every rule password breaks, reported against field. username and email
+// belong to the user the password is for and may be empty
+func (p *PasswordPolicy) Validate(field, password, username, email string) []ValidationErrResponse {
+	var violations []ValidationErrResponse
+	violate := func(tag, value string) {
+		violations = append(violations, ValidationErrResponse{FailedField: field, Tag: tag, TagValue: value})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength {
+		violate(PasswordTagMin, strconv.Itoa(p.cfg.MinLength))
+	}
+	if length > p.cfg.MaxLength {
+		violate(PasswordTagMax, strconv.Itoa(p.cfg.MaxLength))
+	}
+	if p.cfg.MaxBytes > 0 && len(password) > p.cfg.MaxBytes {
+		violate(PasswordTagMaxBytes, strconv.Itoa(p.cfg.MaxBytes))
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.cfg.RequireLowercase && !hasLower {
+		violate(PasswordTagLowercase, "")
+	}
+	if p.cfg.RequireUppercase && !hasUpper {
+		violate(PasswordTagUppercase, "")
+	}
+	if p.cfg.RequireDigit && !hasDigit {
+		violate(PasswordTagDigit, "")
+	}
+	if p.cfg.RequireSymbol && !hasSymbol {
+		violate(PasswordTagSymbol, "")
+	}
+
+	if p.cfg.DisallowIdentity {
+		lower := strings.ToLower(password)
+
+		if containsIdentity(lower, username) {
+			violate(PasswordTagContainsUsername, "")
+		}
+
+		localPart, _, _ := strings.Cut(email, "@")
+		if containsIdentity(lower, email) || containsIdentity(lower, localPart) {
+			violate(PasswordTagContainsEmail, "")
+		}
+	}
+
+	if p.cfg.Breached != nil && p.cfg.Breached.Contains(password) {
+		violate(PasswordTagBreached, "")
+	}
+
+	return violations
+}
+
+func containsIdentity(password, identity string) bool {
+	identity = NormalizeIdentifier(identity)
+	return utf8.RuneCountInString(identity) >= minIdentityLength && strings.Contains(password, identity)
+}
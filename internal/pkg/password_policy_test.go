@@ -0,0 +1,97 @@
+package pkg_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+)
+
+type breachedSet map[string]bool
+
+func (b breachedSet) Contains(password string) bool {
+	return b[password]
+}
+
+func TestPasswordPolicyMaxBytes(t *testing.T) {
+	policy, err := pkg.NewPasswordPolicy(pkg.PasswordPolicyConfig{MaxBytes: pkg.BcryptMaxPasswordBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 64 characters of 2 bytes each are within the default length but not within bcrypt
+	password := strings.Repeat("é", 64)
+	violations := policy.Validate("password", password, "", "")
+	if len(violations) != 1 || violations[0].Tag != pkg.PasswordTagMaxBytes || violations[0].TagValue != "72" {
+		t.Fatalf("expected a max_bytes violation, got %+v", violations)
+	}
+
+	if violations := policy.Validate("password", strings.Repeat("é", 36), "", ""); len(violations) != 0 {
+		t.Fatalf("expected a password of 72 bytes to be accepted, got %+v", violations)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy, err := pkg.NewPasswordPolicy(pkg.PasswordPolicyConfig{
+		MinLength:        10,
+		MaxLength:        20,
+		RequireLowercase: true,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowIdentity: true,
+		Breached:         breachedSet{"P@ssw0rd1234": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "valid", password: "Correct-H0rse", want: nil},
+		{name: "too short", password: "Sh0rt-pw", want: []string{"min"}},
+		{name: "too long", password: "Way-T00-Long-For-The-Policy", want: []string{"max"}},
+		{name: "missing classes", password: "onlylowercase", want: []string{"uppercase", "digit", "symbol"}},
+		{name: "unicode classes", password: "Ünïcödé-pässwörd1", want: nil},
+		{name: "contains username", password: "Xx-Alice-2024", want: []string{"contains_username"}},
+		{name: "contains email local part", password: "Wonderland-99", want: []string{"contains_email"}},
+		{name: "breached", password: "P@ssw0rd1234", want: []string{"breached"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tags []string
+			for _, violation := range policy.Validate("password", tt.password, "alice", "WonderLand@example.com") {
+				if violation.FailedField != "password" {
+					t.Errorf("expected field password, got %s", violation.FailedField)
+				}
+				tags = append(tags, violation.Tag)
+			}
+
+			if !reflect.DeepEqual(tags, tt.want) {
+				t.Fatalf("expected violations %v, got %v", tt.want, tags)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyLengthValues(t *testing.T) {
+	policy, err := pkg.NewPasswordPolicy(pkg.PasswordPolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violations := policy.Validate("password", "short", "", "")
+	expected := []pkg.ValidationErrResponse{{FailedField: "password", Tag: "min", TagValue: "8"}}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("expected %v, got %v", expected, violations)
+	}
+
+	if _, err := pkg.NewPasswordPolicy(pkg.PasswordPolicyConfig{MinLength: 20, MaxLength: 10}); err == nil {
+		t.Fatal("expected a max length below the min length to be rejected")
+	}
+}
@@ -0,0 +1,162 @@
+package pkg_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2id = pkg.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	hash, err := testArgon2id.Hash("mysecurepassword")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %s", hash)
+	}
+
+	if ok, err := testArgon2id.Verify("mysecurepassword", hash); err != nil || !ok {
+		t.Fatalf("expected the password to verify, got %v, %v", ok, err)
+	}
+
+	if ok, _ := testArgon2id.Verify("wrongpassword", hash); ok {
+		t.Fatal("expected a wrong password not to verify")
+	}
+
+	if testArgon2id.NeedsRehash(hash) {
+		t.Error("expected a hash of the same parameters not to need a rehash")
+	}
+
+	stronger := testArgon2id
+	stronger.Iterations = 2
+	if !stronger.NeedsRehash(hash) {
+		t.Error("expected a hash of weaker parameters to need a rehash")
+	}
+
+	// the parameters of the hash are used, not the ones of the hasher
+	if ok, _ := stronger.Verify("mysecurepassword", hash); !ok {
+		t.Error("expected a hash of other parameters to verify")
+	}
+}
+
+func TestArgon2idHasherRejectsMalformedHash(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5",
+	} {
+		if ok, err := testArgon2id.Verify("password", hash); ok || err == nil {
+			t.Errorf("expected %q to be rejected", hash)
+		}
+	}
+
+	// zero parameters would make argon2 panic
+	for _, hash := range []string{
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=0$c2FsdA$a2V5",
+	} {
+		if ok, err := testArgon2id.Verify("password", hash); ok || !errors.Is(err, pkg.ErrInvalidPasswordHash) {
+			t.Errorf("expected %q to be rejected as invalid, got %v", hash, err)
+		}
+	}
+}
+
+func TestCheckPasswordHashVerifiesEveryFormat(t *testing.T) {
+	pkg.SetPasswordHasher(testArgon2id)
+	t.Cleanup(func() { pkg.SetPasswordHasher(pkg.NewArgon2idHasher()) })
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("mysecurepassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pkg.CheckPasswordHash("mysecurepassword", string(legacy)) {
+		t.Fatal("expected a bcrypt hash to verify while hashing with argon2id")
+	}
+	if !pkg.PasswordNeedsRehash(string(legacy)) {
+		t.Error("expected a bcrypt hash to need a rehash into argon2id")
+	}
+
+	hash, err := pkg.HashPassword("mysecurepassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.PasswordNeedsRehash(hash) {
+		t.Error("expected a hash of the current hasher not to need a rehash")
+	}
+
+	if pkg.CheckPasswordHash("mysecurepassword", "plaintext") {
+		t.Error("expected an unknown format not to verify")
+	}
+}
+
+func TestBcryptHasherNeedsRehashOnCostChange(t *testing.T) {
+	hash, err := pkg.BcryptHasher{Cost: bcrypt.MinCost}.Hash("mysecurepassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (pkg.BcryptHasher{Cost: bcrypt.MinCost}).NeedsRehash(hash) {
+		t.Error("expected a hash of the same cost not to need a rehash")
+	}
+	if !(pkg.BcryptHasher{Cost: bcrypt.MinCost + 1}).NeedsRehash(hash) {
+		t.Error("expected a hash of another cost to need a rehash")
+	}
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	hasher, err := pkg.NewPasswordHasher(pkg.PasswordHashConfig{Argon2Iterations: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if argon2id, ok := hasher.(pkg.Argon2idHasher); !ok || argon2id.Iterations != 3 || argon2id.Memory != pkg.NewArgon2idHasher().Memory {
+		t.Errorf("expected argon2id with the configured iterations and default memory, got %+v", hasher)
+	}
+
+	if _, err := pkg.NewPasswordHasher(pkg.PasswordHashConfig{Algorithm: "bcrypt", BcryptCost: 99}); err == nil {
+		t.Error("expected an out of range bcrypt cost to be rejected")
+	}
+
+	if _, err := pkg.NewPasswordHasher(pkg.PasswordHashConfig{Algorithm: "md5"}); err == nil {
+		t.Error("expected an unknown algorithm to be rejected")
+	}
+}
+
+// go test ./internal/pkg -run '^$' -bench PasswordHashers -benchmem
+func BenchmarkPasswordHashers(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		hasher pkg.PasswordHasher
+	}{
+		{"bcrypt cost=10", pkg.BcryptHasher{Cost: 10}},
+		{"bcrypt cost=12", pkg.BcryptHasher{Cost: 12}},
+		{"bcrypt cost=14", pkg.BcryptHasher{Cost: 14}},
+		{"argon2id m=19MiB t=2 p=1", pkg.NewArgon2idHasher()},
+		{"argon2id m=46MiB t=1 p=1", pkg.Argon2idHasher{Memory: 46 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}},
+		{"argon2id m=64MiB t=3 p=4", pkg.Argon2idHasher{Memory: 64 * 1024, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32}},
+	}
+
+	for _, bm := range benchmarks {
+		hash, err := bm.hasher.Hash("mysecurepassword")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				if ok, _ := bm.hasher.Verify("mysecurepassword", hash); !ok {
+					b.Fatal("expected the password to verify")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package pkg_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+)
+
+// RFC 6238 appendix B vectors for SHA1, truncated to 6 digits
+func TestGenerateTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := pkg.GenerateTOTPCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateTOTPCode returned error: %v", err)
+		}
+
+		if got != tt.want {
+			t.Errorf("at %d expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret, err := pkg.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	previous, _ := pkg.GenerateTOTPCode(secret, now.Add(-pkg.TOTPPeriod))
+	stale, _ := pkg.GenerateTOTPCode(secret, now.Add(-3*pkg.TOTPPeriod))
+
+	step, ok := pkg.ValidateTOTPCode(secret, previous, now, 1)
+	if !ok {
+		t.Fatal("code of the previous step should be accepted within the skew")
+	}
+	if step != pkg.TOTPStep(now)-1 {
+		t.Errorf("expected step %d, got %d", pkg.TOTPStep(now)-1, step)
+	}
+
+	if _, ok := pkg.ValidateTOTPCode(secret, stale, now, 1); ok {
+		t.Error("code outside the skew should be rejected")
+	}
+
+	if _, ok := pkg.ValidateTOTPCode(secret, "12345", now, 1); ok {
+		t.Error("code of the wrong length should be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(pkg.TOTPURI("JBSWY3DPEHPK3PXP", "Go Boilerplate", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+
+	if uri.Path != "/Go Boilerplate:alice@example.com" {
+		t.Errorf("unexpected label %q", uri.Path)
+	}
+
+	if uri.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || uri.Query().Get("issuer") != "Go Boilerplate" {
+		t.Errorf("unexpected query %s", uri.RawQuery)
+	}
+}
@@ -2,7 +2,6 @@ package pkg
 
 import (
 	"reflect"
-	"regexp"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -18,7 +17,6 @@ func ValidateRequest(data any) []ValidationErrResponse {
 	var validationErrors []ValidationErrResponse
 
 	validate := validator.New()
-	validate.RegisterValidation("password", password) // register custom validator
 
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		name := fld.Tag.Get("json")
@@ -46,18 +44,3 @@ func ValidateRequest(data any) []ValidationErrResponse {
 
 	return validationErrors
 }
-
-func password(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
-
-	if password != "" {
-		hasDigit := regexp.MustCompile(`\d`).MatchString(password)
-		hasSpecialChar := regexp.MustCompile(`[^\w\s]`).MatchString(password)
-		hasLower := regexp.MustCompile(`[a-z]`).MatchString(password)
-		hasUpper := regexp.MustCompile(`[A-Z]`).MatchString(password)
-
-		return hasDigit && hasSpecialChar && hasLower && hasUpper && len(password) > 8
-	}
-
-	return true
-}
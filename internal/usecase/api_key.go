@@ -0,0 +1,220 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// apiKeyPrefix marks the keys so they are recognizable, e.g. by secret scanners
+	apiKeyPrefix = "ak_"
+	// length of the start of a key that is stored in clear to tell keys apart
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+	// last_used_at is written at most this often per key
+	apiKeyLastUsedInterval = time.Minute
+)
+
+type APIKeyUsecase interface {
+	Create(userID uint, props *entity.CreateAPIKeyRequest, client entity.ClientInfo) (resp pkg.Response)
+	List(userID uint) (resp pkg.Response)
+	Revoke(userID, keyID uint, client entity.ClientInfo) (resp pkg.Response)
+	Authenticate(key string) (user entity.User, apiKey entity.APIKey, err error)
+}
+
+type apiKeyUsecase struct {
+	userRepo   repository.UserRepository
+	apiKeyRepo repository.APIKeyRepository
+	roleRepo   repository.RoleRepository
+	audit      AuditLogger
+	log        *logrus.Logger
+}
+
+func NewAPIKeyUsecase(userRepo repository.UserRepository, apiKeyRepo repository.APIKeyRepository, roleRepo repository.RoleRepository, audit AuditLogger) APIKeyUsecase {
+	log := logger.Get()
+
+	return &apiKeyUsecase{
+		userRepo,
+		apiKeyRepo,
+		roleRepo,
+		audit,
+		log,
+	}
+}
+
+// Create issues a new key, the key itself is only part of this response
+func (u *apiKeyUsecase) Create(userID uint, props *entity.CreateAPIKeyRequest, client entity.ClientInfo) (resp pkg.Response) {
+	random, err := pkg.GenerateRandomString(32)
+	if err != nil {
+		u.log.Errorf("pkg.GenerateRandomString: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	key := apiKeyPrefix + random
+
+	scopes := slices.Clone(props.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	now := time.Now()
+	data := entity.APIKey{
+		UserId:    userID,
+		Name:      strings.TrimSpace(props.Name),
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   hashToken(key),
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: now,
+	}
+	if props.ExpiresInDays > 0 {
+		expiredAt := now.Add(time.Duration(props.ExpiresInDays) * 24 * time.Hour)
+		data.ExpiredAt = &expiredAt
+	}
+
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	data.ID, err = u.apiKeyRepo.Insert(data, tx)
+	if err != nil {
+		u.log.Errorf("apiKeyRepo.Insert: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventAPIKeyCreated, UserId: auditUser(userID)}, client)
+
+	response := apiKeyResponse(data)
+	response.Key = key
+
+	return pkg.NewResponse(http.StatusCreated, "api key created, store it now as it won't be shown again", response, nil)
+}
+
+func (u *apiKeyUsecase) List(userID uint) (resp pkg.Response) {
+	keys, err := u.apiKeyRepo.GetActiveByUserId(userID, database.Get())
+	if err != nil {
+		u.log.Errorf("apiKeyRepo.GetActiveByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	result := make([]entity.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, apiKeyResponse(key))
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", result, nil)
+}
+
+func (u *apiKeyUsecase) Revoke(userID, keyID uint, client entity.ClientInfo) (resp pkg.Response) {
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	revoked, err := u.apiKeyRepo.Revoke(keyID, userID, tx)
+	if err != nil {
+		u.log.Errorf("apiKeyRepo.Revoke: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// keys of other users are reported as not found as well
+	if !revoked {
+		return pkg.NewResponse(http.StatusNotFound, "api key not found", nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventAPIKeyRevoked, UserId: auditUser(userID)}, client)
+
+	return pkg.NewResponse(http.StatusOK, "api key revoked", nil, nil)
+}
+
+// Authenticate resolves a key to its user with its roles and permissions, unknown, expired and revoked keys all return
+// pkg.ErrInvalidAPIKey
+func (u *apiKeyUsecase) Authenticate(key string) (user entity.User, apiKey entity.APIKey, err error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return user, apiKey, pkg.ErrInvalidAPIKey
+	}
+
+	db := database.Get()
+
+	apiKey, err = u.apiKeyRepo.GetByHash(hashToken(key), db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, apiKey, pkg.ErrInvalidAPIKey
+	} else if err != nil {
+		return user, apiKey, fmt.Errorf("apiKeyRepo.GetByHash: %w", err)
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiredAt != nil && !now.Before(*apiKey.ExpiredAt)) {
+		return user, apiKey, pkg.ErrInvalidAPIKey
+	}
+
+	user, err = u.userRepo.GetById(apiKey.UserId, db)
+	if err != nil {
+		return user, apiKey, fmt.Errorf("userRepo.GetById: %w", err)
+	}
+
+	// a key acts with the current roles of its user, limited by its scopes
+	if user.Roles, user.Permissions, err = userAccess(u.roleRepo, user.ID, db); err != nil {
+		return user, apiKey, err
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyLastUsedInterval {
+		// tracking is best effort, a failed write does not refuse the request
+		if err := u.updateLastUsed(apiKey.ID, now); err != nil {
+			u.log.Errorf("u.updateLastUsed: %s", err.Error())
+		} else {
+			apiKey.LastUsedAt = &now
+		}
+	}
+
+	return user, apiKey, nil
+}
+
+func (u *apiKeyUsecase) updateLastUsed(id uint, usedAt time.Time) error {
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := u.apiKeyRepo.UpdateLastUsed(id, usedAt, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func apiKeyResponse(key entity.APIKey) entity.APIKeyResponse {
+	return entity.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiredAt:  key.ExpiredAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
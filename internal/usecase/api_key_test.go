@@ -0,0 +1,140 @@
+package usecase_test
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+type fakeAPIKeyRepo struct {
+	keys []entity.APIKey
+}
+
+func (r *fakeAPIKeyRepo) GetByHash(keyHash string, db *sqlx.DB) (entity.APIKey, error) {
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			return key, nil
+		}
+	}
+	return entity.APIKey{}, sql.ErrNoRows
+}
+
+func (r *fakeAPIKeyRepo) GetActiveByUserId(userId uint, db *sqlx.DB) (result []entity.APIKey, err error) {
+	for _, key := range r.keys {
+		if key.UserId == userId && key.RevokedAt == nil {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeAPIKeyRepo) Insert(data entity.APIKey, tx *sqlx.Tx) (uint, error) {
+	data.ID = uint(len(r.keys) + 1)
+	r.keys = append(r.keys, data)
+	return data.ID, nil
+}
+
+func (r *fakeAPIKeyRepo) Revoke(id, userId uint, tx *sqlx.Tx) (bool, error) {
+	for i, key := range r.keys {
+		if key.ID == id && key.UserId == userId && key.RevokedAt == nil {
+			now := time.Now()
+			r.keys[i].RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeAPIKeyRepo) UpdateLastUsed(id uint, usedAt time.Time, tx *sqlx.Tx) error {
+	for i, key := range r.keys {
+		if key.ID == id {
+			r.keys[i].LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	s := newAuthTestSuite(t)
+	apiKeyRepo := &fakeAPIKeyRepo{}
+	uc := usecase.NewAPIKeyUsecase(s.userRepo, apiKeyRepo, s.roleRepo, s.audit)
+
+	s.expectTx()
+	resp := uc.Create(1, &entity.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"read", "write", "read"}, ExpiresInDays: 30}, entity.ClientInfo{})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected create to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	if event, ok := s.audit.last(entity.AuditEventAPIKeyCreated); !ok || event.UserId == nil || *event.UserId != 1 {
+		t.Fatalf("expected the new key to be audited, got %+v", event)
+	}
+
+	created := resp.Data.(entity.APIKeyResponse)
+	if len(created.Scopes) != 2 || created.ExpiredAt == nil {
+		t.Fatalf("expected deduplicated scopes and an expiry, got %v %v", created.Scopes, created.ExpiredAt)
+	}
+	if stored := apiKeyRepo.keys[0]; stored.KeyHash == created.Key || stored.Prefix != created.Key[:len(stored.Prefix)] {
+		t.Fatal("expected only the hash and the prefix of the key to be stored")
+	}
+
+	// the key is never shown again
+	if listed := uc.List(1).Data.([]entity.APIKeyResponse); len(listed) != 1 || listed[0].Key != "" {
+		t.Fatalf("expected one listed key without its secret, got %+v", listed)
+	}
+
+	s.expectTx()
+	user, apiKey, err := uc.Authenticate(created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || !apiKey.HasScope(entity.APIKeyScopeWrite) || apiKeyRepo.keys[0].LastUsedAt == nil {
+		t.Fatalf("expected alice with the write scope and a tracked use, got user %d key %+v", user.ID, apiKeyRepo.keys[0])
+	}
+
+	// uses within the interval are not written again
+	if _, _, err := uc.Authenticate(created.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	if resp := uc.Revoke(2, created.ID, entity.ClientInfo{}); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected key of another user to be not found, got %d", resp.Code)
+	}
+
+	s.expectTx()
+	if resp := uc.Revoke(1, created.ID, entity.ClientInfo{}); resp.Code != http.StatusOK {
+		t.Fatalf("expected revoke to succeed, got %d", resp.Code)
+	}
+	if event, ok := s.audit.last(entity.AuditEventAPIKeyRevoked); !ok || event.UserId == nil || *event.UserId != 1 {
+		t.Fatalf("expected the revoke to be audited, got %+v", event)
+	}
+
+	if _, _, err := uc.Authenticate(created.Key); !errors.Is(err, pkg.ErrInvalidAPIKey) {
+		t.Fatalf("expected revoked key to be refused, got %v", err)
+	}
+}
+
+func TestAPIKeyAuthenticateRefusesExpiredAndUnknownKeys(t *testing.T) {
+	s := newAuthTestSuite(t)
+	apiKeyRepo := &fakeAPIKeyRepo{}
+	uc := usecase.NewAPIKeyUsecase(s.userRepo, apiKeyRepo, s.roleRepo, s.audit)
+
+	s.expectTx()
+	created := uc.Create(1, &entity.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"read"}, ExpiresInDays: 1}, entity.ClientInfo{}).Data.(entity.APIKeyResponse)
+
+	expired := time.Now().Add(-time.Second)
+	apiKeyRepo.keys[0].ExpiredAt = &expired
+
+	for _, key := range []string{created.Key, "ak_unknown", "not-an-api-key"} {
+		if _, _, err := uc.Authenticate(key); !errors.Is(err, pkg.ErrInvalidAPIKey) {
+			t.Fatalf("expected %q to be refused, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAuditPageLimit = 20
+	maxAuditPageLimit     = 100
+	// events read at a time while walking the chain
+	auditVerifyBatchSize = 500
+)
+
+// auditSortFields are the columns the audit log can be sorted by
+var auditSortFields = map[string]struct{}{
+	"id":         {},
+	"event":      {},
+	"user_id":    {},
+	"created_at": {},
+}
+
+// AuditLogger records authentication and account events together with the client that
+// caused them
+type AuditLogger interface {
+	// Record stores event, a failed write is logged and never fails the recorded action.
+	// Chaining an event locks the head of the chain, so Record runs one at a time across
+	// every instance and bounds the rate of the actions it is called from
+	Record(event entity.AuditEvent, client entity.ClientInfo)
+}
+
+type auditLogger struct {
+	auditRepo repository.AuditRepository
+	log       *logrus.Logger
+}
+
+func NewAuditLogger(auditRepo repository.AuditRepository) AuditLogger {
+	log := logger.Get()
+
+	return &auditLogger{
+		auditRepo,
+		log,
+	}
+}
+
+func (l *auditLogger) Record(event entity.AuditEvent, client entity.ClientInfo) {
+	event.IPAddress = client.IPAddress
+	event.UserAgent = pkg.Truncate(client.UserAgent, 255)
+	event.RequestId = pkg.Truncate(client.RequestID, 64)
+	event.Username = pkg.Truncate(event.Username, 255)
+	event.Details = pkg.Truncate(event.Details, 255)
+	// the column keeps no fractions of a second, the hash has to cover the stored time
+	event.CreatedAt = time.Now().Truncate(time.Second)
+
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		l.log.Errorf("error start transaction: %s", err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	head, err := l.auditRepo.GetChainHead(tx)
+	if err != nil {
+		l.log.Errorf("auditRepo.GetChainHead: %s", err.Error())
+		return
+	}
+
+	event.PrevHash = head.Hash
+	event.Hash = auditEventHash(event, auditChainKey())
+
+	id, err := l.auditRepo.Insert(event, tx)
+	if err != nil {
+		l.log.Errorf("auditRepo.Insert: %s", err.Error())
+		return
+	}
+
+	if err := l.auditRepo.UpdateChainHead(entity.AuditChainHead{LastEventId: id, Hash: event.Hash}, tx); err != nil {
+		l.log.Errorf("auditRepo.UpdateChainHead: %s", err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		l.log.Errorf("failed commit tx: %s", err.Error())
+	}
+}
+
+// auditUser points an audit event at a user
+func auditUser(userID uint) *uint {
+	return &userID
+}
+
+// auditChainKey keys the hashes of the audit chain, ENCRYPTION_KEY is used when the chain has
+// no key of its own
+func auditChainKey() string {
+	if key := config.GetString("AUDIT_CHAIN_KEY"); key != "" {
+		return key
+	}
+
+	return config.GetString("ENCRYPTION_KEY")
+}
+
+// auditEventHash links event to the hash of the event before it, the contents are JSON
+// encoded so that no two different events hash the same input
+func auditEventHash(event entity.AuditEvent, key string) string {
+	var userID any
+	if event.UserId != nil {
+		userID = *event.UserId
+	}
+
+	fields := []any{
+		event.Event,
+		userID,
+		event.Username,
+		event.Reason,
+		event.IPAddress,
+		event.UserAgent,
+		event.RequestId,
+		event.CreatedAt.Unix(),
+	}
+
+	// the actor and details came later, events without them hash as they did before
+	if event.ActorId != nil || event.Details != "" {
+		var actorID any
+		if event.ActorId != nil {
+			actorID = *event.ActorId
+		}
+		fields = append(fields, actorID, event.Details)
+	}
+
+	content, _ := json.Marshal(fields)
+
+	return pkg.Hash(event.PrevHash+string(content), key)
+}
+
+func auditCheckpointSignature(checkpoint entity.AuditCheckpoint, key string) string {
+	return pkg.Hash(fmt.Sprintf("%d:%s:%d", checkpoint.LastEventId, checkpoint.Hash, checkpoint.CreatedAt.Unix()), key)
+}
+
+type AuditUsecase interface {
+	List(props *entity.ListAuditEventsRequest) (resp pkg.Response)
+	Verify() (resp pkg.Response)
+	// VerifyChain walks the audit chain from its first event and stops at the first broken link
+	VerifyChain() (report entity.AuditChainReport, err error)
+	// Checkpoint signs the current head of the chain, it returns nil when no event was
+	// recorded since the last checkpoint
+	Checkpoint() (result *entity.AuditCheckpoint, err error)
+	// ExportCheckpoints writes every checkpoint to w as JSON lines
+	ExportCheckpoints(w io.Writer) error
+}
+
+type auditUsecase struct {
+	auditRepo repository.AuditRepository
+	log       *logrus.Logger
+}
+
+func NewAuditUsecase(auditRepo repository.AuditRepository) AuditUsecase {
+	log := logger.Get()
+
+	return &auditUsecase{
+		auditRepo,
+		log,
+	}
+}
+
+// List returns a page of the audit log, newest first unless sorted otherwise
+func (u *auditUsecase) List(props *entity.ListAuditEventsRequest) (resp pkg.Response) {
+	db := database.Get()
+
+	if props.Sort != nil {
+		if fields := strings.Fields(*props.Sort); len(fields) > 0 {
+			if _, ok := auditSortFields[fields[0]]; !ok {
+				return pkg.NewResponse(http.StatusBadRequest, "invalid sort field", nil, nil)
+			}
+		}
+	}
+
+	filter := entity.AuditEventFilter{
+		UserId:    props.UserId,
+		ActorId:   props.ActorId,
+		Event:     props.Event,
+		IPAddress: props.IPAddress,
+		RequestId: props.RequestId,
+	}
+
+	// the formats are checked by the request validation
+	if props.From != "" {
+		from, _ := time.Parse(time.RFC3339, props.From)
+		filter.From = &from
+	}
+
+	if props.To != "" {
+		to, _ := time.Parse(time.RFC3339, props.To)
+		filter.To = &to
+	}
+
+	page, limit := uint(1), uint(defaultAuditPageLimit)
+	if props.Page != nil && *props.Page > 0 {
+		page = *props.Page
+	}
+	if props.Limit != nil && *props.Limit > 0 {
+		limit = min(*props.Limit, maxAuditPageLimit)
+	}
+	props.Page, props.Limit = &page, &limit
+
+	events, err := u.auditRepo.List(filter, props.PaginationRequest, db)
+	if err != nil {
+		u.log.Errorf("auditRepo.List: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	total, err := u.auditRepo.Count(filter, db)
+	if err != nil {
+		u.log.Errorf("auditRepo.Count: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if events == nil {
+		events = []entity.AuditEvent{}
+	}
+
+	meta := &pkg.PaginationMeta{
+		Page:       int(page),
+		Limit:      int(limit),
+		Total:      int(total),
+		TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", events, meta)
+}
+
+func (u *auditUsecase) Verify() (resp pkg.Response) {
+	report, err := u.VerifyChain()
+	if err != nil {
+		u.log.Errorf("auditUsecase.VerifyChain: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", report, nil)
+}
+
+func (u *auditUsecase) VerifyChain() (report entity.AuditChainReport, err error) {
+	db := database.Get()
+	key := auditChainKey()
+
+	checkpoints, err := u.auditRepo.ListCheckpoints(db)
+	if err != nil {
+		return report, fmt.Errorf("auditRepo.ListCheckpoints: %w", err)
+	}
+
+	// checkpoints by the event they pin
+	pinned := make(map[uint][]entity.AuditCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		if !hmac.Equal([]byte(auditCheckpointSignature(checkpoint, key)), []byte(checkpoint.Signature)) {
+			return brokenAuditCheckpoint(report, checkpoint, "signature does not match the checkpoint"), nil
+		}
+		pinned[checkpoint.LastEventId] = append(pinned[checkpoint.LastEventId], checkpoint)
+	}
+
+	var (
+		lastID   uint
+		prevHash string
+		chained  bool
+	)
+
+	for {
+		events, err := u.auditRepo.ListAfter(lastID, auditVerifyBatchSize, db)
+		if err != nil {
+			return report, fmt.Errorf("auditRepo.ListAfter: %w", err)
+		}
+
+		for _, event := range events {
+			lastID = event.ID
+
+			// events recorded before the chain was introduced come before the first chained
+			// one, whose prev_hash is empty
+			if !chained && event.Hash == "" {
+				report.UnchainedEvents++
+				continue
+			}
+			chained = true
+			report.CheckedEvents++
+
+			if event.PrevHash != prevHash {
+				return brokenAuditEvent(report, event, "prev_hash does not match the hash of the event before it"), nil
+			}
+
+			if !hmac.Equal([]byte(auditEventHash(event, key)), []byte(event.Hash)) {
+				return brokenAuditEvent(report, event, "hash does not match the contents of the event"), nil
+			}
+			prevHash = event.Hash
+
+			for _, checkpoint := range pinned[event.ID] {
+				if checkpoint.Hash != event.Hash {
+					return brokenAuditCheckpoint(report, checkpoint, "hash does not match the checkpointed event"), nil
+				}
+				report.CheckedCheckpoints++
+			}
+			delete(pinned, event.ID)
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			break
+		}
+	}
+
+	// the walk ended before a checkpointed event, the end of the chain was cut off
+	for _, checkpoint := range checkpoints {
+		if _, ok := pinned[checkpoint.LastEventId]; ok {
+			return brokenAuditCheckpoint(report, checkpoint, "checkpointed event is missing from the chain"), nil
+		}
+	}
+
+	report.Valid = true
+	return report, nil
+}
+
+func brokenAuditEvent(report entity.AuditChainReport, event entity.AuditEvent, reason string) entity.AuditChainReport {
+	report.BrokenEventId = &event.ID
+	report.Reason = reason
+	return report
+}
+
+func brokenAuditCheckpoint(report entity.AuditChainReport, checkpoint entity.AuditCheckpoint, reason string) entity.AuditChainReport {
+	report.BrokenCheckpointId = &checkpoint.ID
+	report.Reason = reason
+	return report
+}
+
+func (u *auditUsecase) Checkpoint() (result *entity.AuditCheckpoint, err error) {
+	db := database.Get()
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// events are not appended while the head is locked
+	head, err := u.auditRepo.GetChainHead(tx)
+	if err != nil {
+		return nil, fmt.Errorf("auditRepo.GetChainHead: %w", err)
+	}
+
+	if head.LastEventId == 0 {
+		return nil, nil
+	}
+
+	last, err := u.auditRepo.GetLastCheckpoint(db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("auditRepo.GetLastCheckpoint: %w", err)
+	}
+
+	if err == nil && last.LastEventId == head.LastEventId {
+		return nil, nil
+	}
+
+	checkpoint := entity.AuditCheckpoint{
+		LastEventId: head.LastEventId,
+		Hash:        head.Hash,
+		CreatedAt:   time.Now().Truncate(time.Second),
+	}
+	checkpoint.Signature = auditCheckpointSignature(checkpoint, auditChainKey())
+
+	if err := u.auditRepo.InsertCheckpoint(checkpoint, tx); err != nil {
+		return nil, fmt.Errorf("auditRepo.InsertCheckpoint: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	return &checkpoint, nil
+}
+
+func (u *auditUsecase) ExportCheckpoints(w io.Writer) error {
+	checkpoints, err := u.auditRepo.ListCheckpoints(database.Get())
+	if err != nil {
+		return fmt.Errorf("auditRepo.ListCheckpoints: %w", err)
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, checkpoint := range checkpoints {
+		if err := encoder.Encode(checkpoint); err != nil {
+			return fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
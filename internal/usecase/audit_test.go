@@ -0,0 +1,288 @@
+package usecase_test
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+type fakeAuditLogger struct {
+	events []entity.AuditEvent
+}
+
+func (l *fakeAuditLogger) Record(event entity.AuditEvent, client entity.ClientInfo) {
+	event.IPAddress = client.IPAddress
+	event.UserAgent = client.UserAgent
+	event.RequestId = client.RequestID
+	l.events = append(l.events, event)
+}
+
+// last returns the last recorded event of the given type
+func (l *fakeAuditLogger) last(event string) (entity.AuditEvent, bool) {
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if l.events[i].Event == event {
+			return l.events[i], true
+		}
+	}
+	return entity.AuditEvent{}, false
+}
+
+type fakeAuditRepo struct {
+	events      []entity.AuditEvent
+	head        entity.AuditChainHead
+	checkpoints []entity.AuditCheckpoint
+	filter      entity.AuditEventFilter
+	pagination  pkg.PaginationRequest
+}
+
+func (r *fakeAuditRepo) Insert(data entity.AuditEvent, tx *sqlx.Tx) (uint, error) {
+	data.ID = uint(len(r.events) + 1)
+	r.events = append(r.events, data)
+	return data.ID, nil
+}
+
+func (r *fakeAuditRepo) List(filter entity.AuditEventFilter, pagination pkg.PaginationRequest, db *sqlx.DB) ([]entity.AuditEvent, error) {
+	r.filter, r.pagination = filter, pagination
+
+	offset := int((*pagination.Page - 1) * *pagination.Limit)
+	end := min(offset+int(*pagination.Limit), len(r.events))
+	if offset >= end {
+		return nil, nil
+	}
+	return r.events[offset:end], nil
+}
+
+func (r *fakeAuditRepo) Count(filter entity.AuditEventFilter, db *sqlx.DB) (uint, error) {
+	return uint(len(r.events)), nil
+}
+
+func (r *fakeAuditRepo) ListAfter(id, limit uint, db *sqlx.DB) (result []entity.AuditEvent, err error) {
+	for _, event := range r.events {
+		if event.ID > id && uint(len(result)) < limit {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeAuditRepo) GetChainHead(tx *sqlx.Tx) (entity.AuditChainHead, error) {
+	return r.head, nil
+}
+
+func (r *fakeAuditRepo) UpdateChainHead(data entity.AuditChainHead, tx *sqlx.Tx) error {
+	r.head = data
+	return nil
+}
+
+func (r *fakeAuditRepo) InsertCheckpoint(data entity.AuditCheckpoint, tx *sqlx.Tx) error {
+	data.ID = uint(len(r.checkpoints) + 1)
+	r.checkpoints = append(r.checkpoints, data)
+	return nil
+}
+
+func (r *fakeAuditRepo) GetLastCheckpoint(db *sqlx.DB) (entity.AuditCheckpoint, error) {
+	if len(r.checkpoints) == 0 {
+		return entity.AuditCheckpoint{}, sql.ErrNoRows
+	}
+	return r.checkpoints[len(r.checkpoints)-1], nil
+}
+
+func (r *fakeAuditRepo) ListCheckpoints(db *sqlx.DB) ([]entity.AuditCheckpoint, error) {
+	return r.checkpoints, nil
+}
+
+func TestAuditRecordsLoginOutcomes(t *testing.T) {
+	s := newAuthTestSuite(t)
+	client := entity.ClientInfo{IPAddress: "10.0.0.1", UserAgent: "test-agent", RequestID: "req-1"}
+
+	s.usecase.Login(&entity.LoginRequest{Username: "nobody", Password: "secret"}, client)
+	failed, ok := s.audit.last(entity.AuditEventLoginFailed)
+	if !ok || failed.Reason != entity.AuditReasonUnknownUser || failed.Username != "nobody" || failed.UserId != nil {
+		t.Fatalf("expected a failed login of an unknown user, got %+v", failed)
+	}
+
+	s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "wrong"}, client)
+	failed, _ = s.audit.last(entity.AuditEventLoginFailed)
+	if failed.Reason != entity.AuditReasonInvalidPassword || failed.UserId == nil || *failed.UserId != 1 {
+		t.Fatalf("expected a failed login of alice, got %+v", failed)
+	}
+
+	if failed.IPAddress != "10.0.0.1" || failed.UserAgent != "test-agent" || failed.RequestId != "req-1" {
+		t.Fatalf("expected the client to be recorded, got %+v", failed)
+	}
+
+	refreshToken := s.login(t)
+	if _, ok := s.audit.last(entity.AuditEventLoginSucceeded); !ok {
+		t.Fatal("expected a successful login to be recorded")
+	}
+
+	resp := s.refresh(t, refreshToken)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	if _, ok := s.audit.last(entity.AuditEventTokenRefreshed); !ok {
+		t.Fatal("expected the refresh to be recorded")
+	}
+
+	// the consumed token is presented again
+	s.expectTx()
+	s.usecase.RefreshToken(refreshToken, client)
+	if _, ok := s.audit.last(entity.AuditEventRefreshTokenReused); !ok {
+		t.Fatal("expected the reuse to be recorded")
+	}
+
+	s.expectTx()
+	s.usecase.Logout(s.login(t), client)
+	if _, ok := s.audit.last(entity.AuditEventLoggedOut); !ok {
+		t.Fatal("expected the logout to be recorded")
+	}
+}
+
+func TestAuditListPaginates(t *testing.T) {
+	newAuthTestSuite(t)
+
+	repo := &fakeAuditRepo{}
+	for range 45 {
+		repo.events = append(repo.events, entity.AuditEvent{Event: entity.AuditEventLoginSucceeded})
+	}
+	uc := usecase.NewAuditUsecase(repo)
+
+	resp := uc.List(&entity.ListAuditEventsRequest{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected list to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	meta := resp.PaginationMeta
+	if meta == nil || meta.Page != 1 || meta.Limit != 20 || meta.Total != 45 || meta.TotalPages != 3 {
+		t.Fatalf("expected the first page of 20, got %+v", meta)
+	}
+
+	page, limit := uint(3), uint(500)
+	resp = uc.List(&entity.ListAuditEventsRequest{
+		PaginationRequest: pkg.PaginationRequest{Page: &page, Limit: &limit},
+		From:              "2026-01-02T15:04:05Z",
+	})
+	if resp.PaginationMeta.Limit != 100 {
+		t.Fatalf("expected the limit to be capped, got %d", resp.PaginationMeta.Limit)
+	}
+	if events := resp.Data.([]entity.AuditEvent); len(events) != 0 {
+		t.Fatalf("expected an empty page past the end, got %d events", len(events))
+	}
+	if repo.filter.From == nil || repo.filter.From.Year() != 2026 {
+		t.Fatalf("expected from to be parsed, got %v", repo.filter.From)
+	}
+
+	sort := "password desc"
+	resp = uc.List(&entity.ListAuditEventsRequest{PaginationRequest: pkg.PaginationRequest{Sort: &sort}})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected sorting by an unknown field to be refused, got %d", resp.Code)
+	}
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	// an event recorded before the chain was introduced
+	repo := &fakeAuditRepo{events: []entity.AuditEvent{{ID: 1, Event: entity.AuditEventRegistered}}}
+	audit := usecase.NewAuditLogger(repo)
+	uc := usecase.NewAuditUsecase(repo)
+
+	client := entity.ClientInfo{IPAddress: "10.0.0.1", UserAgent: "test-agent", RequestID: "req-1"}
+	for _, event := range []string{entity.AuditEventLoginSucceeded, entity.AuditEventTokenRefreshed, entity.AuditEventLoggedOut} {
+		s.expectTx()
+		audit.Record(entity.AuditEvent{Event: event, UserId: new(uint)}, client)
+	}
+
+	s.expectTx()
+	checkpoint, err := uc.Checkpoint()
+	if err != nil || checkpoint == nil || checkpoint.LastEventId != 4 {
+		t.Fatalf("expected a checkpoint at the last event, got %+v: %v", checkpoint, err)
+	}
+
+	// nothing was recorded since
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	if checkpoint, err := uc.Checkpoint(); err != nil || checkpoint != nil {
+		t.Fatalf("expected no new checkpoint, got %+v: %v", checkpoint, err)
+	}
+
+	s.expectTx()
+	audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, Username: "bob"}, client)
+	s.expectTx()
+	audit.Record(entity.AuditEvent{Event: entity.AuditEventImpersonationStarted, UserId: new(uint), ActorId: new(uint), Details: "ticket 42"}, client)
+
+	report, err := uc.VerifyChain()
+	if err != nil || !report.Valid {
+		t.Fatalf("expected the chain to verify, got %+v: %v", report, err)
+	}
+	if report.CheckedEvents != 5 || report.UnchainedEvents != 1 || report.CheckedCheckpoints != 1 {
+		t.Fatalf("expected 5 chained events, 1 unchained and 1 checkpoint, got %+v", report)
+	}
+
+	events := slices.Clone(repo.events)
+	tests := []struct {
+		name       string
+		tamper     func()
+		event      uint
+		checkpoint uint
+	}{
+		{
+			name:   "edited event",
+			tamper: func() { repo.events[2].Reason = "edited" },
+			event:  3,
+		},
+		{
+			name:   "edited actor",
+			tamper: func() { repo.events[5].ActorId = nil },
+			event:  6,
+		},
+		{
+			name:   "deleted event",
+			tamper: func() { repo.events = slices.Delete(repo.events, 2, 3) },
+			event:  4,
+		},
+		{
+			name:   "unchained first event",
+			tamper: func() { repo.events[1].Hash = "" },
+			event:  3,
+		},
+		{
+			name:       "truncated chain",
+			tamper:     func() { repo.events = repo.events[:3] },
+			checkpoint: 1,
+		},
+		{
+			name:       "forged checkpoint",
+			tamper:     func() { repo.checkpoints[0].LastEventId = 3 },
+			checkpoint: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.events = slices.Clone(events)
+			saved := repo.checkpoints[0]
+			defer func() { repo.checkpoints[0] = saved }()
+
+			tt.tamper()
+
+			report, err := uc.VerifyChain()
+			if err != nil || report.Valid {
+				t.Fatalf("expected the chain to be broken, got %+v: %v", report, err)
+			}
+
+			if tt.event != 0 && (report.BrokenEventId == nil || *report.BrokenEventId != tt.event) {
+				t.Fatalf("expected event %d to be reported, got %+v", tt.event, report)
+			}
+			if tt.checkpoint != 0 && (report.BrokenCheckpointId == nil || *report.BrokenCheckpointId != tt.checkpoint) {
+				t.Fatalf("expected checkpoint %d to be reported, got %+v", tt.checkpoint, report)
+			}
+		})
+	}
+}
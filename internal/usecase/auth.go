@@ -3,7 +3,9 @@ package usecase
 import (
 	"database/sql"
 	"errors"
+	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/fazriegi/go-boilerplate/internal/entity"
@@ -11,36 +13,64 @@ import (
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
 	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
 	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 )
 
 type AuthUsecase interface {
-	Register(props *entity.RegisterRequest) (resp pkg.Response)
-	Login(props *entity.LoginRequest) (resp pkg.Response)
-	RefreshToken(refreshToken string) (resp pkg.Response)
-	Logout(refreshToken string) (resp pkg.Response)
+	Register(props *entity.RegisterRequest, client entity.ClientInfo) (resp pkg.Response)
+	Login(props *entity.LoginRequest, client entity.ClientInfo) (resp pkg.Response)
+	VerifyMFA(props *entity.MFALoginRequest, client entity.ClientInfo) (resp pkg.Response)
+	ResendMFACode(props *entity.MFACodeRequest) (resp pkg.Response)
+	CompleteLogin(user entity.User, deviceLabel string, client entity.ClientInfo) (resp pkg.Response)
+	RefreshToken(refreshToken string, client entity.ClientInfo) (resp pkg.Response)
+	Logout(refreshToken string, client entity.ClientInfo) (resp pkg.Response)
+	LogoutAll(userID uint, client entity.ClientInfo) (resp pkg.Response)
 }
 
 type authUsecase struct {
-	userRepo repository.UserRepository
-	authRepo repository.AuthRepository
-	log      *logrus.Logger
-	jwt      *pkg.JWT
+	userRepo        repository.UserRepository
+	authRepo        repository.AuthRepository
+	mfaRepo         repository.MFARepository
+	roleRepo        repository.RoleRepository
+	verificationUC  EmailVerificationUsecase
+	emailOTP        EmailOTPUsecase
+	audit           AuditLogger
+	revocationStore store.RevocationStore
+	loginGuard      loginGuard
+	passwordPolicy  *pkg.PasswordPolicy
+	log             *logrus.Logger
+	jwt             *pkg.JWT
 }
 
-func NewAuthUsecase(userRepo repository.UserRepository, authRepo repository.AuthRepository, jwt *pkg.JWT) AuthUsecase {
+func NewAuthUsecase(userRepo repository.UserRepository, authRepo repository.AuthRepository, mfaRepo repository.MFARepository, roleRepo repository.RoleRepository, verificationUC EmailVerificationUsecase, emailOTP EmailOTPUsecase, audit AuditLogger, revocationStore store.RevocationStore, loginAttemptStore store.LoginAttemptStore, passwordPolicy *pkg.PasswordPolicy, jwt *pkg.JWT) AuthUsecase {
 	log := logger.Get()
+	loginGuard := loginGuard{loginAttemptStore, log}
 
 	return &authUsecase{
 		userRepo,
 		authRepo,
+		mfaRepo,
+		roleRepo,
+		verificationUC,
+		emailOTP,
+		audit,
+		revocationStore,
+		loginGuard,
+		passwordPolicy,
 		log,
 		jwt,
 	}
 }
 
-func (u *authUsecase) Register(props *entity.RegisterRequest) (resp pkg.Response) {
+// hashes opaque tokens (refresh, verification, ...) before they are stored or looked up
+func hashToken(token string) string {
+	return pkg.Hash(token, config.GetString("JWT_SECRET"))
+}
+
+func (u *authUsecase) Register(props *entity.RegisterRequest, client entity.ClientInfo) (resp pkg.Response) {
 	var (
 		err            error
 		user           entity.User
@@ -48,14 +78,8 @@ func (u *authUsecase) Register(props *entity.RegisterRequest) (resp pkg.Response
 		db             = database.Get()
 	)
 
-	user, err = u.userRepo.GetByUsername(props.Username, db)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		u.log.Errorf("userRepo.GetByUsername: %s", err.Error())
-		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
-	}
-
-	if user.Username != "" {
-		return pkg.NewResponse(http.StatusBadRequest, "username already exists", nil, nil)
+	if resp, ok := checkPasswordPolicy(u.passwordPolicy, "password", props.Password, props.Username, props.Email); !ok {
+		return resp
 	}
 
 	if hashedPassword, err = pkg.HashPassword(props.Password); err != nil {
@@ -72,13 +96,15 @@ func (u *authUsecase) Register(props *entity.RegisterRequest) (resp pkg.Response
 
 	user = entity.User{
 		Name:     props.Name,
-		Email:    props.Email,
+		Email:    pkg.NormalizeIdentifier(props.Email),
 		Password: hashedPassword,
-		Username: props.Username,
+		Username: pkg.NormalizeIdentifier(props.Username),
 	}
 
-	_, err = u.userRepo.Insert(&user, tx)
-	if err != nil {
+	user.ID, err = u.userRepo.Insert(&user, tx)
+	if errors.Is(err, pkg.ErrUsernameTaken) || errors.Is(err, pkg.ErrEmailTaken) {
+		return pkg.NewResponse(http.StatusConflict, err.Error(), nil, nil)
+	} else if err != nil {
 		u.log.Errorf("userRepo.Insert: %s", err.Error())
 		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
 	}
@@ -88,6 +114,13 @@ func (u *authUsecase) Register(props *entity.RegisterRequest) (resp pkg.Response
 		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
 	}
 
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventRegistered, UserId: auditUser(user.ID)}, client)
+
+	// the account exists at this point, a failed email can be requested again through resend
+	if err := u.verificationUC.SendVerification(user); err != nil {
+		u.log.Errorf("verificationUC.SendVerification: %s", err.Error())
+	}
+
 	createdUser := entity.UserResponse{
 		Name:     user.Name,
 		Email:    user.Email,
@@ -97,34 +130,171 @@ func (u *authUsecase) Register(props *entity.RegisterRequest) (resp pkg.Response
 	return pkg.NewResponse(http.StatusCreated, "success", createdUser, nil)
 }
 
-func (u *authUsecase) Login(props *entity.LoginRequest) (resp pkg.Response) {
+func (u *authUsecase) Login(props *entity.LoginRequest, client entity.ClientInfo) (resp pkg.Response) {
 	db := database.Get()
+	login := pkg.NormalizeIdentifier(props.Username)
+	usernameKey, ipKey := usernameLoginKey(login), ipLoginKey(client.IPAddress)
 
-	existingUser, err := u.userRepo.GetByUsername(props.Username, db)
+	if wait := u.loginGuard.retryAfter(usernameKey, ipKey); wait > 0 {
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, Username: login, Reason: entity.AuditReasonLocked}, client)
+		return tooManyLoginAttempts(wait)
+	}
+
+	existingUser, err := u.getUserByLogin(login, db)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		u.loginGuard.fail(usernameKey, ipKey)
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, Username: login, Reason: entity.AuditReasonUnknownUser}, client)
 		return pkg.NewResponse(http.StatusUnauthorized, "invalid username or password", nil, nil)
 	} else if err != nil {
-		u.log.Errorf("userRepo.GetByUsername: %s", err.Error())
+		u.log.Errorf("u.getUserByLogin: %s", err.Error())
 		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
 	}
 
+	// logins by email and by username count against the same account
+	if login != existingUser.Username {
+		usernameKey = usernameLoginKey(existingUser.Username)
+		if wait := u.loginGuard.retryAfter(usernameKey); wait > 0 {
+			u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(existingUser.ID), Reason: entity.AuditReasonLocked}, client)
+			return tooManyLoginAttempts(wait)
+		}
+	}
+
 	if !pkg.CheckPasswordHash(props.Password, existingUser.Password) {
+		u.loginGuard.fail(usernameKey, ipKey)
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(existingUser.ID), Reason: entity.AuditReasonInvalidPassword}, client)
 		return pkg.NewResponse(http.StatusUnauthorized, "invalid username or password", nil, nil)
 	}
 
-	accessToken, err := u.jwt.GenerateAccessToken(existingUser.ID, existingUser.Email, existingUser.Username)
-	if err != nil {
-		u.log.Errorf("u.jwt.GenerateAccessToken: %s", err.Error())
+	u.loginGuard.succeed(usernameKey)
+
+	if pkg.PasswordNeedsRehash(existingUser.Password) {
+		u.rehashPassword(existingUser.ID, props.Password)
+	}
+
+	return u.CompleteLogin(existingUser, props.DeviceLabel, client)
+}
+
+// CompleteLogin signs in a user whose credentials were already checked, by password or by an
+// external provider, with the second factor still to be checked when it is enabled
+func (u *authUsecase) CompleteLogin(user entity.User, deviceLabel string, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	if config.GetBool("AUTH_REQUIRE_VERIFIED_EMAIL") && user.EmailVerifiedAt == nil {
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(user.ID), Reason: entity.AuditReasonEmailNotVerified}, client)
+		return pkg.NewResponse(http.StatusForbidden, "email is not verified", nil, nil)
+	}
+
+	mfa, err := u.mfaRepo.GetByUserId(user.ID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
 		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
 	}
 
-	refreshToken, err := u.jwt.GenerateRefreshToken(existingUser.ID)
+	// with two-factor authentication the session is only opened by VerifyMFA
+	if err == nil && mfa.EnabledAt != nil {
+		mfaToken, err := u.jwt.GenerateMFAToken(user.ID, deviceLabel)
+		if err != nil {
+			u.log.Errorf("u.jwt.GenerateMFAToken: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+
+		if mfa.Method == entity.MFAMethodEmail {
+			retryAfter, err := u.emailOTP.Send(user)
+			if err != nil {
+				u.log.Errorf("emailOTP.Send: %s", err.Error())
+				return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+			}
+
+			if retryAfter > 0 {
+				return tooManyCodeRequests(retryAfter)
+			}
+		}
+
+		data := map[string]any{
+			"mfa_required": true,
+			"mfa_method":   mfa.Method,
+			"mfa_token":    mfaToken,
+		}
+
+		return pkg.NewResponse(http.StatusOK, "two-factor authentication required", data, nil)
+	}
+
+	return u.issueSession(user, deviceLabel, client)
+}
+
+// rehashPassword replaces a hash of an outdated algorithm or outdated parameters, the login
+// goes on with the old hash when it fails
+func (u *authUsecase) rehashPassword(userID uint, password string) {
+	hashedPassword, err := pkg.HashPassword(password)
 	if err != nil {
-		u.log.Errorf("u.jwt.GenerateRefreshToken: %s", err.Error())
+		u.log.Errorf("pkg.HashPassword: %s", err.Error())
+		return
+	}
+
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	if err := u.userRepo.UpdatePassword(userID, hashedPassword, tx); err != nil {
+		u.log.Errorf("userRepo.UpdatePassword: %s", err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+	}
+}
+
+// getUserByLogin finds the user by email when login looks like one. New usernames can't
+// contain an @, the ones registered before that rule are found by username when no email
+// matches
+func (u *authUsecase) getUserByLogin(login string, db *sqlx.DB) (entity.User, error) {
+	if strings.Contains(login, "@") {
+		user, err := u.userRepo.GetByEmail(login, db)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return user, err
+		}
+	}
+
+	return u.userRepo.GetByUsername(login, db)
+}
+
+// VerifyMFA is the second step of a login of a user with two-factor authentication
+func (u *authUsecase) VerifyMFA(props *entity.MFALoginRequest, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	claims, resp, ok := u.verifyMFAToken(props.MFAToken)
+	if !ok {
+		return resp
+	}
+
+	existingUser, err := u.userRepo.GetById(claims.UserID(), db)
+	if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	// codes are guessed against the same counters as passwords
+	usernameKey, ipKey := usernameLoginKey(existingUser.Username), ipLoginKey(client.IPAddress)
+	if wait := u.loginGuard.retryAfter(usernameKey, ipKey); wait > 0 {
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(existingUser.ID), Reason: entity.AuditReasonLocked}, client)
+		return tooManyLoginAttempts(wait)
+	}
+
+	mfa, err := u.mfaRepo.GetByUserId(existingUser.ID, db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	} else if err != nil {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
 		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
 	}
 
-	hashedRefreshToken := pkg.Hash(refreshToken, config.GetString("JWT_SECRET"))
+	if mfa.EnabledAt == nil {
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
 
 	tx, err := db.Beginx()
 	if err != nil {
@@ -133,16 +303,161 @@ func (u *authUsecase) Login(props *entity.LoginRequest) (resp pkg.Response) {
 	}
 	defer tx.Rollback()
 
-	err = u.authRepo.DeleteRefreshTokenByUserId(existingUser.ID, tx)
+	valid, err := verifySecondFactor(u.mfaRepo, u.emailOTP, mfa, props.Code, tx)
+	if err != nil {
+		u.log.Errorf("verifySecondFactor: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !valid {
+		u.loginGuard.fail(usernameKey, ipKey)
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(existingUser.ID), Reason: entity.AuditReasonInvalidMFACode}, client)
+		return pkg.NewResponse(http.StatusUnauthorized, "invalid two-factor authentication code", nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.loginGuard.succeed(usernameKey)
+
+	// the challenge is spent, it can't open a second session
+	if err := u.revocationStore.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		u.log.Errorf("revocationStore.Revoke: %s", err.Error())
+	}
+
+	return u.issueSession(existingUser, claims.DeviceLabel, client)
+}
+
+// verifyMFAToken verifies the challenge token of a login, ok is false with the answer to give
+// when it is invalid or was already used
+func (u *authUsecase) verifyMFAToken(token string) (claims *pkg.MFAClaims, resp pkg.Response, ok bool) {
+	claims, err := u.jwt.VerifyMFAToken(token)
+	if err != nil {
+		return nil, pkg.NewResponse(http.StatusUnauthorized, err.Error(), nil, nil), false
+	}
+
+	revoked, err := u.revocationStore.IsRevoked(claims.ID)
+	if err != nil {
+		u.log.Errorf("revocationStore.IsRevoked: %s", err.Error())
+		return nil, pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil), false
+	}
+
+	if revoked {
+		return nil, pkg.NewResponse(http.StatusUnauthorized, "token has been revoked", nil, nil), false
+	}
+
+	return claims, resp, true
+}
+
+// ResendMFACode emails a new code for the challenge token of a login with the email second factor
+func (u *authUsecase) ResendMFACode(props *entity.MFACodeRequest) (resp pkg.Response) {
+	db := database.Get()
+
+	claims, resp, ok := u.verifyMFAToken(props.MFAToken)
+	if !ok {
+		return resp
+	}
+
+	existingUser, err := u.userRepo.GetById(claims.UserID(), db)
 	if err != nil {
-		u.log.Errorf("authRepo.DeleteRefreshTokenByUserId: %s", err.Error())
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
 		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
 	}
 
+	mfa, err := u.mfaRepo.GetByUserId(existingUser.ID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err != nil || mfa.EnabledAt == nil || mfa.Method != entity.MFAMethodEmail {
+		return pkg.NewResponse(http.StatusBadRequest, "two-factor authentication by email is not enabled", nil, nil)
+	}
+
+	retryAfter, err := u.emailOTP.Send(existingUser)
+	if err != nil {
+		u.log.Errorf("emailOTP.Send: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if retryAfter > 0 {
+		return tooManyCodeRequests(retryAfter)
+	}
+
+	return pkg.NewResponse(http.StatusOK, "a new code has been sent to your email", nil, nil)
+}
+
+// tooManyCodeRequests is returned while a user can't be sent another email code, retry_after
+// is sent to the client as the Retry-After header
+func tooManyCodeRequests(wait time.Duration) pkg.Response {
+	data := map[string]any{
+		"retry_after": int(math.Ceil(wait.Seconds())),
+	}
+
+	return pkg.NewResponse(http.StatusTooManyRequests, "too many codes requested, try again later", data, nil)
+}
+
+// tooManyLoginAttempts is returned while a login key is locked, retry_after is sent to the
+// client as the Retry-After header
+func tooManyLoginAttempts(wait time.Duration) pkg.Response {
+	data := map[string]any{
+		"retry_after": int(math.Ceil(wait.Seconds())),
+	}
+
+	return pkg.NewResponse(http.StatusTooManyRequests, "too many failed login attempts, try again later", data, nil)
+}
+
+// issueSession opens a new session for user and returns its tokens, every login opens its
+// own session so other devices of the user stay signed in
+func (u *authUsecase) issueSession(user entity.User, deviceLabel string, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	roles, permissions, err := userAccess(u.roleRepo, user.ID, db)
+	if err != nil {
+		u.log.Errorf("userAccess: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	accessToken, err := u.jwt.GenerateAccessToken(user.ID, user.Email, user.Username, roles, permissions)
+	if err != nil {
+		u.log.Errorf("u.jwt.GenerateAccessToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	refreshToken, err := u.jwt.GenerateRefreshToken(user.ID)
+	if err != nil {
+		u.log.Errorf("u.jwt.GenerateRefreshToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	hashedRefreshToken := hashToken(refreshToken)
+
+	familyID, err := pkg.GenerateRandomString(16)
+	if err != nil {
+		u.log.Errorf("pkg.GenerateRandomString: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
 	refreshTokenData := entity.RefreshToken{
-		UserId:    existingUser.ID,
-		Token:     hashedRefreshToken,
-		ExpiredAt: time.Now().Add(time.Duration(config.GetUint("JWT_REFRESH_EXP_DAY")) * 24 * time.Hour),
+		UserId:          user.ID,
+		Token:           hashedRefreshToken,
+		FamilyId:        familyID,
+		AccessTokenId:   accessToken.ID,
+		AccessExpiredAt: &accessToken.ExpiresAt,
+		DeviceLabel:     deviceLabel,
+		UserAgent:       pkg.Truncate(client.UserAgent, 255),
+		IPAddress:       client.IPAddress,
+		ExpiredAt:       time.Now().Add(time.Duration(config.GetUint("JWT_REFRESH_EXP_DAY")) * 24 * time.Hour),
+		CreatedAt:       time.Now(),
 	}
 
 	_, err = u.authRepo.InsertRefreshToken(refreshTokenData, tx)
@@ -156,28 +471,30 @@ func (u *authUsecase) Login(props *entity.LoginRequest) (resp pkg.Response) {
 		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
 	}
 
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginSucceeded, UserId: auditUser(user.ID)}, client)
+
 	data := map[string]any{
-		"access_token":  accessToken,
+		"access_token":  accessToken.Token,
 		"refresh_token": refreshToken,
 		"user": entity.UserResponse{
-			Name:     existingUser.Name,
-			Username: existingUser.Username,
-			Email:    existingUser.Email,
+			Name:     user.Name,
+			Username: user.Username,
+			Email:    user.Email,
 		},
 	}
 
 	return pkg.NewResponse(http.StatusOK, "success", data, nil)
 }
 
-func (u *authUsecase) RefreshToken(refreshToken string) (resp pkg.Response) {
+func (u *authUsecase) RefreshToken(refreshToken string, client entity.ClientInfo) (resp pkg.Response) {
 	db := database.Get()
 
-	claims, err := u.jwt.VerifyToken(refreshToken, "refresh")
+	claims, err := u.jwt.VerifyRefreshToken(refreshToken)
 	if err != nil {
 		return pkg.NewResponse(http.StatusUnauthorized, err.Error(), nil, nil)
 	}
 
-	userID := uint(claims["id"].(float64))
+	userID := claims.UserID()
 
 	existingUser, err := u.userRepo.GetById(userID, db)
 	if err != nil {
@@ -185,80 +502,178 @@ func (u *authUsecase) RefreshToken(refreshToken string) (resp pkg.Response) {
 		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
 	}
 
-	tokenHashed := pkg.Hash(refreshToken, config.GetString("JWT_SECRET"))
+	tokenHashed := hashToken(refreshToken)
 
-	existingToken, err := u.authRepo.GetValidRefreshToken(tokenHashed, userID, db)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		u.log.Errorf("u.authRepo.GetValidRefreshToken: %s", err.Error())
+	existingToken, err := u.authRepo.GetRefreshTokenByToken(tokenHashed, db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	} else if err != nil {
+		u.log.Errorf("u.authRepo.GetRefreshTokenByToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	if existingToken.UserId != userID {
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	// a consumed token being presented again means it was copied, the whole family is revoked
+	if existingToken.ConsumedAt != nil {
+		u.revokeReusedTokenFamily(existingToken, client)
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	if existingToken.RevokedAt != nil || !existingToken.ExpiredAt.After(time.Now()) {
 		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
 	}
 
-	newAccessToken, err := u.jwt.GenerateAccessToken(existingUser.ID, existingUser.Email, existingUser.Username)
+	// roles are read again so changes apply from the next refresh
+	roles, permissions, err := userAccess(u.roleRepo, existingUser.ID, db)
+	if err != nil {
+		u.log.Errorf("userAccess: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	newAccessToken, err := u.jwt.GenerateAccessToken(existingUser.ID, existingUser.Email, existingUser.Username, roles, permissions)
 	if err != nil {
 		u.log.Errorf("u.jwt.GenerateAccessToken: %s", err.Error())
 		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
 	}
 
-	newRefreshToken := refreshToken
+	newRefreshToken, err := u.jwt.GenerateRefreshToken(userID)
+	if err != nil {
+		u.log.Errorf("u.jwt.GenerateRefreshToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
 
-	if existingToken.ID == 0 {
-		newRefreshToken, err = u.jwt.GenerateRefreshToken(userID)
-		if err != nil {
-			u.log.Errorf("u.jwt.GenerateRefreshToken: %s", err.Error())
-			return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
-		}
+	hashedNewRefreshToken := hashToken(newRefreshToken)
 
-		hasheNewdRefreshToken := pkg.Hash(newRefreshToken, config.GetString("JWT_SECRET"))
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+	defer tx.Rollback()
 
-		tx, err := db.Beginx()
-		if err != nil {
-			u.log.Errorf("error start transaction: %s", err.Error())
-			return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
-		}
-		defer tx.Rollback()
+	consumed, err := u.authRepo.ConsumeRefreshToken(existingToken.ID, tx)
+	if err != nil {
+		u.log.Errorf("authRepo.ConsumeRefreshToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
 
-		err = u.authRepo.DeleteRefreshTokenByUserId(existingUser.ID, tx)
-		if err != nil {
-			u.log.Errorf("authRepo.DeleteRefreshTokenByUserId: %s", err.Error())
-			return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
-		}
+	// another request consumed the token in the meantime
+	if !consumed {
+		tx.Rollback()
+		u.revokeReusedTokenFamily(existingToken, client)
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
 
-		refreshTokenData := entity.RefreshToken{
-			UserId:    existingUser.ID,
-			Token:     hasheNewdRefreshToken,
-			ExpiredAt: time.Now().Add(time.Duration(config.GetUint("JWT_REFRESH_EXP_DAY")) * 24 * time.Hour),
-		}
+	// the child keeps the session metadata, created_at stays the time the session was opened
+	now := time.Now()
+	refreshTokenData := entity.RefreshToken{
+		UserId:          existingToken.UserId,
+		Token:           hashedNewRefreshToken,
+		FamilyId:        existingToken.FamilyId,
+		ParentId:        &existingToken.ID,
+		AccessTokenId:   newAccessToken.ID,
+		AccessExpiredAt: &newAccessToken.ExpiresAt,
+		DeviceLabel:     existingToken.DeviceLabel,
+		UserAgent:       existingToken.UserAgent,
+		IPAddress:       existingToken.IPAddress,
+		ExpiredAt:       now.Add(time.Duration(config.GetUint("JWT_REFRESH_EXP_DAY")) * 24 * time.Hour),
+		CreatedAt:       existingToken.CreatedAt,
+		LastUsedAt:      &now,
+	}
 
-		_, err = u.authRepo.InsertRefreshToken(refreshTokenData, tx)
-		if err != nil {
-			u.log.Errorf("authRepo.InsertRefreshToken: %s", err.Error())
-			return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
-		}
+	_, err = u.authRepo.InsertRefreshToken(refreshTokenData, tx)
+	if err != nil {
+		u.log.Errorf("authRepo.InsertRefreshToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
 
-		if err := tx.Commit(); err != nil {
-			u.log.Errorf("failed commit tx: %s", err.Error())
-			return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
-		}
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
 	}
 
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventTokenRefreshed, UserId: auditUser(existingUser.ID)}, client)
+
 	data := map[string]any{
-		"access_token":  newAccessToken,
+		"access_token":  newAccessToken.Token,
 		"refresh_token": newRefreshToken,
 	}
 
 	return pkg.NewResponse(http.StatusOK, "success", data, nil)
 }
 
-func (u *authUsecase) Logout(refreshToken string) (resp pkg.Response) {
+func (u *authUsecase) revokeReusedTokenFamily(token entity.RefreshToken, client entity.ClientInfo) {
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventRefreshTokenReused, UserId: auditUser(token.UserId)}, client)
+
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	if err := u.authRepo.RevokeRefreshTokenFamily(token.FamilyId, tx); err != nil {
+		u.log.Errorf("authRepo.RevokeRefreshTokenFamily: %s", err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return
+	}
+
+	u.revokeFamilyAccessTokens(token.FamilyId)
+}
+
+// adds the live access tokens of the family to the revocation store
+func (u *authUsecase) revokeFamilyAccessTokens(familyID string) {
+	tokens, err := u.authRepo.GetLiveAccessTokensByFamily(familyID, database.Get())
+	if err != nil {
+		u.log.Errorf("authRepo.GetLiveAccessTokensByFamily: %s", err.Error())
+		return
+	}
+
+	revokeAccessTokens(u.revocationStore, tokens, u.log)
+}
+
+func revokeAccessTokens(revocationStore store.RevocationStore, tokens []entity.RefreshToken, log *logrus.Logger) {
+	for _, token := range tokens {
+		if token.AccessExpiredAt == nil {
+			continue
+		}
+
+		if err := revocationStore.Revoke(token.AccessTokenId, *token.AccessExpiredAt); err != nil {
+			log.Errorf("revocationStore.Revoke: %s", err.Error())
+		}
+	}
+}
+
+func (u *authUsecase) Logout(refreshToken string, client entity.ClientInfo) (resp pkg.Response) {
 	db := database.Get()
 	resp = pkg.NewResponse(http.StatusOK, "success", nil, nil)
 
-	claims, err := u.jwt.VerifyToken(refreshToken, "refresh")
+	claims, err := u.jwt.VerifyRefreshToken(refreshToken)
+	if err != nil {
+		return
+	}
+
+	userID := claims.UserID()
+	tokenHashed := hashToken(refreshToken)
+
+	existingToken, err := u.authRepo.GetRefreshTokenByToken(tokenHashed, db)
 	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			u.log.Errorf("authRepo.GetRefreshTokenByToken: %s", err.Error())
+		}
 		return
 	}
 
-	userID := uint(claims["id"].(float64))
+	if existingToken.UserId != userID {
+		return
+	}
 
 	tx, err := db.Beginx()
 	if err != nil {
@@ -267,9 +682,10 @@ func (u *authUsecase) Logout(refreshToken string) (resp pkg.Response) {
 	}
 	defer tx.Rollback()
 
-	err = u.authRepo.DeleteRefreshTokenByUserId(userID, tx)
+	// only the current session is revoked, other devices stay signed in
+	err = u.authRepo.RevokeRefreshTokenFamily(existingToken.FamilyId, tx)
 	if err != nil {
-		u.log.Errorf("authRepo.DeleteRefreshTokenByUserId: %s", err.Error())
+		u.log.Errorf("authRepo.RevokeRefreshTokenFamily: %s", err.Error())
 		return
 	}
 
@@ -278,5 +694,44 @@ func (u *authUsecase) Logout(refreshToken string) (resp pkg.Response) {
 		return
 	}
 
+	u.revokeFamilyAccessTokens(existingToken.FamilyId)
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoggedOut, UserId: auditUser(userID)}, client)
+
 	return
 }
+
+func (u *authUsecase) LogoutAll(userID uint, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	// read before revoking so every session still holding a live access token is covered
+	tokens, err := u.authRepo.GetLiveAccessTokensByUserId(userID, db)
+	if err != nil {
+		u.log.Errorf("authRepo.GetLiveAccessTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	err = u.authRepo.RevokeRefreshTokensByUserId(userID, tx)
+	if err != nil {
+		u.log.Errorf("authRepo.RevokeRefreshTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	revokeAccessTokens(u.revocationStore, tokens, u.log)
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoggedOutAll, UserId: auditUser(userID)}, client)
+
+	return pkg.NewResponse(http.StatusOK, "success", nil, nil)
+}
@@ -0,0 +1,613 @@
+package usecase_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/mailer"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/scheduler"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeUserRepo struct {
+	users map[uint]entity.User
+}
+
+func (r *fakeUserRepo) GetById(id uint, db *sqlx.DB) (entity.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return entity.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepo) GetByUsername(username string, db *sqlx.DB) (entity.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return entity.User{}, sql.ErrNoRows
+}
+
+func (r *fakeUserRepo) GetByEmail(email string, db *sqlx.DB) (entity.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return entity.User{}, sql.ErrNoRows
+}
+
+func (r *fakeUserRepo) MarkEmailVerified(id uint, tx *sqlx.Tx) error {
+	user, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	r.users[id] = user
+	return nil
+}
+
+func (r *fakeUserRepo) UpdatePassword(id uint, password string, tx *sqlx.Tx) error {
+	user, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.Password = password
+	r.users[id] = user
+	return nil
+}
+
+func (r *fakeUserRepo) Insert(data *entity.User, tx *sqlx.Tx) (uint, error) {
+	for _, user := range r.users {
+		if user.Username == data.Username {
+			return 0, pkg.ErrUsernameTaken
+		}
+		if user.Email == data.Email {
+			return 0, pkg.ErrEmailTaken
+		}
+	}
+
+	data.ID = uint(len(r.users) + 1)
+	r.users[data.ID] = *data
+	return data.ID, nil
+}
+
+type fakeAuthRepo struct {
+	tokens []entity.RefreshToken
+}
+
+func (r *fakeAuthRepo) InsertRefreshToken(data entity.RefreshToken, tx *sqlx.Tx) (uint, error) {
+	data.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, data)
+	return data.ID, nil
+}
+
+func (r *fakeAuthRepo) GetRefreshTokenByToken(token string, db *sqlx.DB) (entity.RefreshToken, error) {
+	for _, t := range r.tokens {
+		if t.Token == token {
+			return t, nil
+		}
+	}
+	return entity.RefreshToken{}, sql.ErrNoRows
+}
+
+func (r *fakeAuthRepo) GetActiveRefreshTokenById(id, userId uint, db *sqlx.DB) (entity.RefreshToken, error) {
+	for _, t := range r.tokens {
+		if t.ID == id && t.UserId == userId && isActive(t) {
+			return t, nil
+		}
+	}
+	return entity.RefreshToken{}, sql.ErrNoRows
+}
+
+func (r *fakeAuthRepo) GetActiveRefreshTokensByUserId(userId uint, db *sqlx.DB) (result []entity.RefreshToken, err error) {
+	for _, t := range r.tokens {
+		if t.UserId == userId && isActive(t) {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeAuthRepo) ConsumeRefreshToken(id uint, tx *sqlx.Tx) (bool, error) {
+	for i, t := range r.tokens {
+		if t.ID == id && t.ConsumedAt == nil && t.RevokedAt == nil {
+			now := time.Now()
+			r.tokens[i].ConsumedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeAuthRepo) GetLiveAccessTokensByFamily(familyId string, db *sqlx.DB) (result []entity.RefreshToken, err error) {
+	for _, t := range r.tokens {
+		if t.FamilyId == familyId && t.AccessExpiredAt != nil && t.AccessExpiredAt.After(time.Now()) {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeAuthRepo) GetLiveAccessTokensByUserId(userId uint, db *sqlx.DB) (result []entity.RefreshToken, err error) {
+	for _, t := range r.tokens {
+		if t.UserId == userId && t.AccessExpiredAt != nil && t.AccessExpiredAt.After(time.Now()) {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeAuthRepo) RevokeRefreshTokenFamily(familyId string, tx *sqlx.Tx) error {
+	for i, t := range r.tokens {
+		if t.FamilyId == familyId && t.RevokedAt == nil {
+			now := time.Now()
+			r.tokens[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) RevokeRefreshTokensByUserId(userId uint, tx *sqlx.Tx) error {
+	for i, t := range r.tokens {
+		if t.UserId == userId && t.RevokedAt == nil {
+			now := time.Now()
+			r.tokens[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) RevokeRefreshTokensByUserIdExceptFamily(userId uint, familyId string, tx *sqlx.Tx) error {
+	for i, t := range r.tokens {
+		if t.UserId == userId && t.FamilyId != familyId && t.RevokedAt == nil {
+			now := time.Now()
+			r.tokens[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) DeleteExpiredRefreshTokens(before time.Time, limit uint, tx *sqlx.Tx) (deleted int64, err error) {
+	kept := r.tokens[:0]
+	for _, t := range r.tokens {
+		if t.ExpiredAt.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+		kept = append(kept, t)
+	}
+	r.tokens = kept
+	return deleted, nil
+}
+
+func isActive(t entity.RefreshToken) bool {
+	return t.ConsumedAt == nil && t.RevokedAt == nil && t.ExpiredAt.After(time.Now())
+}
+
+type authTestSuite struct {
+	usecase         usecase.AuthUsecase
+	userRepo        *fakeUserRepo
+	authRepo        *fakeAuthRepo
+	mfaRepo         *fakeMFARepo
+	emailCodeRepo   *fakeMFAEmailCodeRepo
+	emailOTP        usecase.EmailOTPUsecase
+	audit           *fakeAuditLogger
+	roleRepo        *fakeRoleRepo
+	revocationStore *store.MemoryRevocationStore
+	attemptStore    *store.MemoryLoginAttemptStore
+	passwordPolicy  *pkg.PasswordPolicy
+	mailer          *mailer.CaptureMailer
+	background      *scheduler.Background
+	mock            sqlmock.Sqlmock
+}
+
+func newAuthTestSuite(t *testing.T) *authTestSuite {
+	t.Helper()
+
+	config.Set("JWT_SECRET", "test-secret")
+	config.Set("JWT_REFRESH_EXP_DAY", 7)
+	config.Set("ENCRYPTION_KEY", "test-encryption-key")
+	pkg.SetPasswordHasher(pkg.BcryptHasher{Cost: bcrypt.MinCost})
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	logger.LOGGER = log
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	database.DB = sqlx.NewDb(db, "sqlmock")
+
+	password, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userRepo := &fakeUserRepo{users: map[uint]entity.User{
+		1: {ID: 1, Name: "Alice", Username: "alice", Email: "alice@example.com", Password: string(password)},
+	}}
+	authRepo := &fakeAuthRepo{}
+	mfaRepo := &fakeMFARepo{}
+	roleRepo := newFakeRoleRepo()
+	revocationStore := store.NewMemoryRevocationStore(time.Minute)
+	t.Cleanup(revocationStore.Close)
+	attemptStore := store.NewMemoryLoginAttemptStore(time.Minute)
+	t.Cleanup(attemptStore.Close)
+	captureMailer := mailer.NewCaptureMailer()
+	verificationUC := usecase.NewEmailVerificationUsecase(userRepo, newFakeEmailVerificationRepo(), captureMailer)
+	emailCodeRepo := newFakeMFAEmailCodeRepo()
+	rateLimitStore := store.NewMemoryRateLimitStore(time.Minute)
+	t.Cleanup(rateLimitStore.Close)
+	emailOTP := usecase.NewEmailOTPUsecase(emailCodeRepo, rateLimitStore, captureMailer)
+	audit := &fakeAuditLogger{}
+	jwt := newTestJWT(t)
+
+	passwordPolicy, err := pkg.NewPasswordPolicy(pkg.PasswordPolicyConfig{
+		MinLength:        8,
+		RequireLowercase: true,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowIdentity: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authTestSuite{
+		usecase:         usecase.NewAuthUsecase(userRepo, authRepo, mfaRepo, roleRepo, verificationUC, emailOTP, audit, revocationStore, attemptStore, passwordPolicy, jwt),
+		userRepo:        userRepo,
+		authRepo:        authRepo,
+		mfaRepo:         mfaRepo,
+		emailCodeRepo:   emailCodeRepo,
+		emailOTP:        emailOTP,
+		audit:           audit,
+		roleRepo:        roleRepo,
+		revocationStore: revocationStore,
+		attemptStore:    attemptStore,
+		passwordPolicy:  passwordPolicy,
+		mailer:          captureMailer,
+		background:      scheduler.NewBackground(),
+		mock:            mock,
+	}
+}
+
+// waitBackground waits for the emails the usecases left to background tasks
+func (s *authTestSuite) waitBackground(t *testing.T) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := s.background.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestJWT(t *testing.T) *pkg.JWT {
+	t.Helper()
+
+	jwt, err := pkg.InitJWT(pkg.JWTConfig{
+		Keyring:         pkg.NewHMACKeyring("test-secret"),
+		Issuer:          "test-issuer",
+		Audience:        []string{"test-audience"},
+		AccessTokenExp:  15 * time.Minute,
+		RefreshTokenExp: 7 * 24 * time.Hour,
+		MFATokenExp:     5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return jwt
+}
+
+// expects a single transaction that is committed
+func (s *authTestSuite) expectTx() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectCommit()
+}
+
+func (s *authTestSuite) login(t *testing.T) string {
+	t.Helper()
+
+	s.expectTx()
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{IPAddress: "127.0.0.1"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected login to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	return resp.Data.(map[string]any)["refresh_token"].(string)
+}
+
+func (s *authTestSuite) refresh(t *testing.T, refreshToken string) pkg.Response {
+	t.Helper()
+
+	s.expectTx()
+	return s.usecase.RefreshToken(refreshToken, entity.ClientInfo{})
+}
+
+func TestRegisterNormalizesUsernameAndEmail(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	s.expectTx()
+	resp := s.usecase.Register(&entity.RegisterRequest{Name: "Bob", Username: " Bob ", Email: "Bob@Example.com", Password: "Str0ng-Passw0rd"}, entity.ClientInfo{})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.Code, resp.Message)
+	}
+
+	user, err := s.userRepo.GetByUsername("bob", nil)
+	if err != nil {
+		t.Fatalf("expected the username to be stored lower-cased: %v", err)
+	}
+	if user.Email != "bob@example.com" {
+		t.Errorf("expected the email to be stored lower-cased, got %s", user.Email)
+	}
+}
+
+func TestRegisterDuplicateIsConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		message  string
+	}{
+		{name: "username", username: "ALICE", email: "other@example.com", message: pkg.ErrUsernameTaken.Error()},
+		{name: "email", username: "other", email: " Alice@Example.com", message: pkg.ErrEmailTaken.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAuthTestSuite(t)
+
+			s.mock.ExpectBegin()
+			s.mock.ExpectRollback()
+			resp := s.usecase.Register(&entity.RegisterRequest{Name: "Other", Username: tt.username, Email: tt.email, Password: "Str0ng-Passw0rd"}, entity.ClientInfo{})
+			if resp.Code != http.StatusConflict {
+				t.Fatalf("expected 409, got %d", resp.Code)
+			}
+			if resp.Message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, resp.Message)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsPasswordPolicyViolations(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	resp := s.usecase.Register(&entity.RegisterRequest{Name: "Bob", Username: "bob", Email: "bob@example.com", Password: "bobpass"}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+
+	var tags []string
+	for _, violation := range resp.Data.(map[string]any)["errors"].([]pkg.ValidationErrResponse) {
+		if violation.FailedField != "password" {
+			t.Errorf("expected violations of the password field, got %s", violation.FailedField)
+		}
+		tags = append(tags, violation.Tag)
+	}
+
+	expected := []string{"min", "uppercase", "digit", "symbol", "contains_username", "contains_email"}
+	if strings.Join(tags, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected violations %v, got %v", expected, tags)
+	}
+}
+
+func TestLoginWithEmail(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	s.expectTx()
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "ALICE@example.com ", Password: "secret"}, entity.ClientInfo{IPAddress: "127.0.0.1"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected login by email to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+}
+
+func TestLoginWithLegacyUsernameContainingAt(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	password := s.userRepo.users[1].Password
+	s.userRepo.users[2] = entity.User{ID: 2, Name: "Bob", Username: "bob@work", Email: "bob@example.com", Password: password}
+
+	s.expectTx()
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "bob@work", Password: "secret"}, entity.ClientInfo{IPAddress: "127.0.0.1"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected a username registered with an @ to still log in, got %d: %s", resp.Code, resp.Message)
+	}
+}
+
+func TestLoginRehashesOutdatedPassword(t *testing.T) {
+	s := newAuthTestSuite(t)
+	pkg.SetPasswordHasher(pkg.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	t.Cleanup(func() { pkg.SetPasswordHasher(pkg.BcryptHasher{Cost: bcrypt.MinCost}) })
+
+	// one transaction for the rehash, one for the session
+	s.expectTx()
+	s.login(t)
+
+	hash := s.userRepo.users[1].Password
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("expected the bcrypt hash to be replaced by argon2id, got %s", hash)
+	}
+	if !pkg.CheckPasswordHash("secret", hash) {
+		t.Fatal("expected the new hash to verify the password")
+	}
+
+	// an up to date hash is kept
+	s.login(t)
+	if s.userRepo.users[1].Password != hash {
+		t.Fatal("expected an up to date hash not to be replaced")
+	}
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshTokenRotatesWithinFamily(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	first := s.login(t)
+
+	resp := s.refresh(t, first)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	second := resp.Data.(map[string]any)["refresh_token"].(string)
+	if second == first {
+		t.Fatal("refresh should issue a new refresh token")
+	}
+
+	if len(s.authRepo.tokens) != 2 {
+		t.Fatalf("expected 2 tokens, got %d", len(s.authRepo.tokens))
+	}
+
+	parent, child := s.authRepo.tokens[0], s.authRepo.tokens[1]
+	if parent.ConsumedAt == nil {
+		t.Error("presented token should be consumed")
+	}
+	if child.FamilyId != parent.FamilyId {
+		t.Errorf("expected child in family %q, got %q", parent.FamilyId, child.FamilyId)
+	}
+	if child.ParentId == nil || *child.ParentId != parent.ID {
+		t.Errorf("expected child parent to be %d, got %v", parent.ID, child.ParentId)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	first := s.login(t)
+	other := s.login(t)
+
+	resp := s.refresh(t, first)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	second := resp.Data.(map[string]any)["refresh_token"].(string)
+
+	// replaying the consumed token revokes the family in its own transaction
+	resp = s.refresh(t, first)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected replay to be rejected, got %d", resp.Code)
+	}
+
+	resp = s.usecase.RefreshToken(second, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected token of revoked family to be rejected, got %d", resp.Code)
+	}
+
+	for _, token := range s.authRepo.tokens {
+		if token.FamilyId == s.authRepo.tokens[0].FamilyId && token.RevokedAt == nil {
+			t.Errorf("token %d of the reused family should be revoked", token.ID)
+		}
+	}
+
+	// sessions on other devices are untouched
+	resp = s.refresh(t, other)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected other session to refresh, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshTokenUnknownTokenIsRejected(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	token, err := newTestJWT(t).GenerateRefreshToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := s.usecase.RefreshToken(token, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected unknown token to be rejected, got %d", resp.Code)
+	}
+
+	if len(s.authRepo.tokens) != 0 {
+		t.Fatalf("no token should be issued, got %d", len(s.authRepo.tokens))
+	}
+}
+
+func TestLogoutRevokesLiveAccessTokensOfSession(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	first := s.login(t)
+	s.login(t)
+
+	resp := s.refresh(t, first)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	current := resp.Data.(map[string]any)["refresh_token"].(string)
+
+	s.expectTx()
+	s.usecase.Logout(current, entity.ClientInfo{})
+
+	family := s.authRepo.tokens[0].FamilyId
+	for _, token := range s.authRepo.tokens {
+		revoked, _ := s.revocationStore.IsRevoked(token.AccessTokenId)
+		if token.FamilyId == family && !revoked {
+			t.Errorf("access token of session token %d should be revoked", token.ID)
+		}
+		if token.FamilyId != family && revoked {
+			t.Errorf("access token of other session token %d should stay valid", token.ID)
+		}
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	s.login(t)
+	s.login(t)
+
+	s.expectTx()
+	resp := s.usecase.LogoutAll(1, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected logout all to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	for _, token := range s.authRepo.tokens {
+		if token.RevokedAt == nil {
+			t.Errorf("refresh token %d should be revoked", token.ID)
+		}
+		if revoked, _ := s.revocationStore.IsRevoked(token.AccessTokenId); !revoked {
+			t.Errorf("access token of refresh token %d should be revoked", token.ID)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultCleanupBatchSize = 1000
+
+// CleanupUsecase deletes rows that are no longer needed, it is run by background jobs
+type CleanupUsecase interface {
+	// PurgeExpiredRefreshTokens deletes the refresh tokens that have expired, batchSize rows
+	// per transaction so the table is never locked for long. A batchSize of 0 keeps the
+	// default of 1000
+	PurgeExpiredRefreshTokens(ctx context.Context, batchSize uint) (deleted int64, err error)
+	// PurgeExpired deletes the expired rows of table, one of repository.ExpiringTables, in
+	// batches the same way as PurgeExpiredRefreshTokens
+	PurgeExpired(ctx context.Context, table string, batchSize uint) (deleted int64, err error)
+}
+
+type cleanupUsecase struct {
+	authRepo       repository.AuthRepository
+	expiredRowRepo repository.ExpiredRowRepository
+	log            *logrus.Logger
+}
+
+func NewCleanupUsecase(authRepo repository.AuthRepository, expiredRowRepo repository.ExpiredRowRepository) CleanupUsecase {
+	log := logger.Get()
+
+	return &cleanupUsecase{
+		authRepo,
+		expiredRowRepo,
+		log,
+	}
+}
+
+func (u *cleanupUsecase) PurgeExpiredRefreshTokens(ctx context.Context, batchSize uint) (deleted int64, err error) {
+	return u.purgeInBatches(ctx, batchSize, func(before time.Time, limit uint, tx *sqlx.Tx) (int64, error) {
+		n, err := u.authRepo.DeleteExpiredRefreshTokens(before, limit, tx)
+		if err != nil {
+			return n, fmt.Errorf("authRepo.DeleteExpiredRefreshTokens: %w", err)
+		}
+		return n, nil
+	})
+}
+
+func (u *cleanupUsecase) PurgeExpired(ctx context.Context, table string, batchSize uint) (deleted int64, err error) {
+	return u.purgeInBatches(ctx, batchSize, func(before time.Time, limit uint, tx *sqlx.Tx) (int64, error) {
+		n, err := u.expiredRowRepo.DeleteExpired(table, before, limit, tx)
+		if err != nil {
+			return n, fmt.Errorf("expiredRowRepo.DeleteExpired: %w", err)
+		}
+		return n, nil
+	})
+}
+
+// purgeInBatches calls deleteBatch in its own transaction until it deletes fewer than batchSize
+// rows
+func (u *cleanupUsecase) purgeInBatches(ctx context.Context, batchSize uint, deleteBatch func(before time.Time, limit uint, tx *sqlx.Tx) (int64, error)) (deleted int64, err error) {
+	db := database.Get()
+	now := time.Now()
+
+	if batchSize == 0 {
+		batchSize = defaultCleanupBatchSize
+	}
+
+	for {
+		// stop between batches once the scheduler stops
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		tx, err := db.Beginx()
+		if err != nil {
+			return deleted, fmt.Errorf("error start transaction: %w", err)
+		}
+
+		n, err := deleteBatch(now, batchSize, tx)
+		if err != nil {
+			tx.Rollback()
+			return deleted, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return deleted, fmt.Errorf("failed commit tx: %w", err)
+		}
+
+		deleted += n
+		if n < int64(batchSize) {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func TestCleanupPurgesExpiredRefreshTokensInBatches(t *testing.T) {
+	s := newAuthTestSuite(t)
+
+	for i := range 5 {
+		s.authRepo.tokens = append(s.authRepo.tokens, entity.RefreshToken{ID: uint(i + 1), ExpiredAt: time.Now().Add(-time.Hour)})
+	}
+	s.authRepo.tokens = append(s.authRepo.tokens, entity.RefreshToken{ID: 6, ExpiredAt: time.Now().Add(time.Hour)})
+
+	uc := usecase.NewCleanupUsecase(s.authRepo, newFakeExpiredRowRepo())
+
+	// batches of 2, 2 and 1
+	for range 3 {
+		s.expectTx()
+	}
+
+	deleted, err := uc.PurgeExpiredRefreshTokens(context.Background(), 2)
+	if err != nil || deleted != 5 {
+		t.Fatalf("expected 5 tokens to be deleted, got %d: %v", deleted, err)
+	}
+
+	if len(s.authRepo.tokens) != 1 || s.authRepo.tokens[0].ID != 6 {
+		t.Fatalf("expected the live token to be kept, got %+v", s.authRepo.tokens)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := uc.PurgeExpiredRefreshTokens(ctx, 2); err == nil {
+		t.Fatal("expected a stopped purge to return the context error")
+	}
+}
+
+// fakeExpiredRowRepo keeps the expired_at of the rows of each table
+type fakeExpiredRowRepo struct {
+	rows map[string][]time.Time
+}
+
+func newFakeExpiredRowRepo() *fakeExpiredRowRepo {
+	return &fakeExpiredRowRepo{rows: map[string][]time.Time{}}
+}
+
+func (r *fakeExpiredRowRepo) DeleteExpired(table string, before time.Time, limit uint, tx *sqlx.Tx) (deleted int64, err error) {
+	kept := r.rows[table][:0]
+	for _, expiredAt := range r.rows[table] {
+		if expiredAt.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+		kept = append(kept, expiredAt)
+	}
+	r.rows[table] = kept
+	return deleted, nil
+}
+
+func TestCleanupPurgesEveryExpiringTable(t *testing.T) {
+	s := newAuthTestSuite(t)
+	rowRepo := newFakeExpiredRowRepo()
+
+	for _, table := range repository.ExpiringTables {
+		rowRepo.rows[table] = []time.Time{
+			time.Now().Add(-2 * time.Hour),
+			time.Now().Add(-time.Hour),
+			time.Now().Add(time.Hour),
+		}
+	}
+
+	uc := usecase.NewCleanupUsecase(s.authRepo, rowRepo)
+
+	for _, table := range repository.ExpiringTables {
+		// batches of 1, 1 and an empty one
+		for range 3 {
+			s.expectTx()
+		}
+
+		deleted, err := uc.PurgeExpired(context.Background(), table, 1)
+		if err != nil || deleted != 2 {
+			t.Fatalf("expected 2 rows of %s to be deleted, got %d: %v", table, deleted, err)
+		}
+
+		if len(rowRepo.rows[table]) != 1 || !rowRepo.rows[table][0].After(time.Now()) {
+			t.Fatalf("expected the live row of %s to be kept, got %v", table, rowRepo.rows[table])
+		}
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
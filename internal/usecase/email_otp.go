@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/mailer"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+const emailOTPDigits = 6
+
+// EmailOTPUsecase sends and checks the one-time codes of the email second factor
+type EmailOTPUsecase interface {
+	// Send replaces any pending code of the user and emails a new one, retryAfter is set
+	// instead when the user asked for too many codes
+	Send(user entity.User) (retryAfter time.Duration, err error)
+	// Verify consumes the pending code of the user within tx when code matches it
+	Verify(userID uint, code string, tx *sqlx.Tx) (bool, error)
+}
+
+type emailOTPUsecase struct {
+	codeRepo       repository.MFAEmailCodeRepository
+	rateLimitStore store.RateLimitStore
+	mailer         mailer.Mailer
+	log            *logrus.Logger
+}
+
+func NewEmailOTPUsecase(codeRepo repository.MFAEmailCodeRepository, rateLimitStore store.RateLimitStore, mailer mailer.Mailer) EmailOTPUsecase {
+	log := logger.Get()
+
+	return &emailOTPUsecase{
+		codeRepo,
+		rateLimitStore,
+		mailer,
+		log,
+	}
+}
+
+func emailOTPExp() time.Duration {
+	return time.Duration(config.GetUint("EMAIL_OTP_EXP_MINUTE")) * time.Minute
+}
+
+func (u *emailOTPUsecase) Send(user entity.User) (retryAfter time.Duration, err error) {
+	db := database.Get()
+
+	// counted per user so a challenge token or a session can't be used to flood the mailbox,
+	// an unavailable store doesn't block sign ins
+	limit, window := config.GetInt("EMAIL_OTP_SEND_LIMIT"), time.Duration(config.GetUint("EMAIL_OTP_SEND_WINDOW_MINUTE"))*time.Minute
+	if limit > 0 && window > 0 {
+		result, err := u.rateLimitStore.FixedWindow("email_otp_send:"+strconv.FormatUint(uint64(user.ID), 10), limit, window)
+		if err != nil {
+			u.log.Errorf("rateLimitStore.FixedWindow: %s", err.Error())
+		} else if !result.Allowed {
+			return max(time.Until(result.RetryAt), time.Second), nil
+		}
+	}
+
+	code, err := pkg.GenerateNumericCode(emailOTPDigits)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := u.codeRepo.InvalidateByUserId(user.ID, tx); err != nil {
+		return 0, fmt.Errorf("codeRepo.InvalidateByUserId: %w", err)
+	}
+
+	_, err = u.codeRepo.Insert(entity.MFAEmailCode{
+		UserId:    user.ID,
+		Code:      hashToken(code),
+		ExpiredAt: time.Now().Add(emailOTPExp()),
+		CreatedAt: time.Now(),
+	}, tx)
+	if err != nil {
+		return 0, fmt.Errorf("codeRepo.Insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	return 0, u.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign in code",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour sign in code is:\n\n%s\n\nThe code expires in %d minutes. If you did not try to sign in, change your password.\n",
+			user.Name,
+			code,
+			config.GetUint("EMAIL_OTP_EXP_MINUTE"),
+		),
+	})
+}
+
+func (u *emailOTPUsecase) Verify(userID uint, code string, tx *sqlx.Tx) (bool, error) {
+	db := database.Get()
+
+	pending, err := u.codeRepo.GetValidByUserId(userID, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("codeRepo.GetValidByUserId: %w", err)
+	}
+
+	// every guess counts against the code, a code guessed wrong too often has to be replaced
+	// by a new one
+	if maxAttempts := config.GetUint("EMAIL_OTP_MAX_ATTEMPTS"); maxAttempts > 0 {
+		added, err := u.codeRepo.AddAttempt(pending.ID, maxAttempts, db)
+		if err != nil {
+			return false, fmt.Errorf("codeRepo.AddAttempt: %w", err)
+		}
+
+		if !added {
+			return false, nil
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(strings.TrimSpace(code))), []byte(pending.Code)) != 1 {
+		return false, nil
+	}
+
+	return u.codeRepo.MarkUsed(pending.ID, tx)
+}
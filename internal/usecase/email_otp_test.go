@@ -0,0 +1,211 @@
+package usecase_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func newEmailOTPSuite(t *testing.T) *authTestSuite {
+	t.Helper()
+
+	s := newAuthTestSuite(t)
+	config.Set("EMAIL_OTP_EXP_MINUTE", 5)
+	config.Set("EMAIL_OTP_MAX_ATTEMPTS", 5)
+	config.Set("EMAIL_OTP_SEND_LIMIT", 0)
+	config.Set("EMAIL_OTP_SEND_WINDOW_MINUTE", 15)
+
+	return s
+}
+
+// lastEmailCode reads the code of the last email sent to alice
+func (s *authTestSuite) lastEmailCode(t *testing.T) string {
+	t.Helper()
+
+	msg, ok := s.mailer.Last("alice@example.com")
+	if !ok {
+		t.Fatal("expected a code email")
+	}
+	_, rest, _ := strings.Cut(msg.Body, "code is:")
+
+	return strings.Fields(rest)[0]
+}
+
+// enables two-factor authentication by email for alice, returning the recovery codes
+func enableEmailMFA(t *testing.T, s *authTestSuite) []string {
+	t.Helper()
+
+	uc := usecase.NewMFAUsecase(s.userRepo, s.mfaRepo, s.emailOTP, s.audit, s.attemptStore)
+
+	s.expectTx() // enrollment
+	s.expectTx() // code
+	resp := uc.EnrollEmail(1)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected enroll to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	s.expectTx()
+	resp = uc.Confirm(1, &entity.ConfirmMFARequest{Code: s.lastEmailCode(t)}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected confirm to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	return resp.Data.(map[string]any)["recovery_codes"].([]string)
+}
+
+// emailChallenge runs the password step of a login of alice, which emails a code
+func (s *authTestSuite) emailChallenge(t *testing.T) string {
+	t.Helper()
+
+	s.expectTx()
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected password step to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	data := resp.Data.(map[string]any)
+	if data["mfa_required"] != true || data["mfa_method"] != entity.MFAMethodEmail {
+		t.Fatalf("expected an email mfa challenge, got %v", data)
+	}
+
+	return data["mfa_token"].(string)
+}
+
+func TestLoginWithEmailCode(t *testing.T) {
+	s := newEmailOTPSuite(t)
+	enableEmailMFA(t, s)
+
+	mfaToken := s.emailChallenge(t)
+	code := s.lastEmailCode(t)
+
+	if stored := s.emailCodeRepo.tokens[len(s.emailCodeRepo.tokens)-1]; stored.Code == code {
+		t.Fatal("code should be stored hashed")
+	}
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp := s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: wrong}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected wrong code to be rejected, got %d", resp.Code)
+	}
+
+	s.expectTx()
+	s.expectTx()
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected second step to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	if _, ok := resp.Data.(map[string]any)["refresh_token"]; !ok {
+		t.Fatal("expected session tokens")
+	}
+
+	// the challenge is spent once it opened a session
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected used challenge to be rejected, got %d", resp.Code)
+	}
+
+	// the same code cannot be replayed with a new challenge either
+	mfaToken = s.emailChallenge(t)
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected replayed code to be rejected, got %d", resp.Code)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmailCodeRefusedAfterMaxAttempts(t *testing.T) {
+	s := newEmailOTPSuite(t)
+	config.Set("EMAIL_OTP_MAX_ATTEMPTS", 3)
+	enableEmailMFA(t, s)
+
+	mfaToken := s.emailChallenge(t)
+	code := s.lastEmailCode(t)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for range 3 {
+		s.mock.ExpectBegin()
+		s.mock.ExpectRollback()
+		s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: wrong}, entity.ClientInfo{})
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp := s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the right code to be refused after too many guesses, got %d", resp.Code)
+	}
+
+	// a new code can be asked for with the same challenge
+	s.expectTx()
+	resp = s.usecase.ResendMFACode(&entity.MFACodeRequest{MFAToken: mfaToken})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected resend to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	s.expectTx()
+	s.expectTx()
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: s.lastEmailCode(t)}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected the new code to be accepted, got %d: %s", resp.Code, resp.Message)
+	}
+}
+
+func TestEmailCodeSendRateLimitedPerUser(t *testing.T) {
+	s := newEmailOTPSuite(t)
+	enableEmailMFA(t, s)
+	config.Set("EMAIL_OTP_SEND_LIMIT", 2)
+
+	mfaToken := s.emailChallenge(t)
+
+	s.expectTx()
+	resp := s.usecase.ResendMFACode(&entity.MFACodeRequest{MFAToken: mfaToken})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected resend to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	sent := len(s.mailer.Messages())
+
+	resp = s.usecase.ResendMFACode(&entity.MFACodeRequest{MFAToken: mfaToken})
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected resend to be rate limited, got %d", resp.Code)
+	}
+
+	if retryAfter := resp.Data.(map[string]any)["retry_after"].(int); retryAfter <= 0 {
+		t.Fatalf("expected a retry after, got %d", retryAfter)
+	}
+
+	if got := len(s.mailer.Messages()); got != sent {
+		t.Fatalf("expected no further email, got %d", got-sent)
+	}
+}
+
+func TestResendMFACodeRequiresEmailMethod(t *testing.T) {
+	s := newEmailOTPSuite(t)
+	enableMFA(t, s)
+
+	mfaToken := s.mfaChallenge(t)
+
+	resp := s.usecase.ResendMFACode(&entity.MFACodeRequest{MFAToken: mfaToken})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected resend for a TOTP user to be refused, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultImpersonationExp = 15 * time.Minute
+
+type ImpersonationUsecase interface {
+	Start(actor entity.User, userID uint, props *entity.ImpersonateRequest, client entity.ClientInfo) (resp pkg.Response)
+	Stop(claims *pkg.AccessClaims, client entity.ClientInfo) (resp pkg.Response)
+}
+
+type impersonationUsecase struct {
+	userRepo          repository.UserRepository
+	roleRepo          repository.RoleRepository
+	impersonationRepo repository.ImpersonationRepository
+	revocationStore   store.RevocationStore
+	audit             AuditLogger
+	log               *logrus.Logger
+	jwt               *pkg.JWT
+}
+
+func NewImpersonationUsecase(userRepo repository.UserRepository, roleRepo repository.RoleRepository, impersonationRepo repository.ImpersonationRepository, revocationStore store.RevocationStore, audit AuditLogger, jwt *pkg.JWT) ImpersonationUsecase {
+	log := logger.Get()
+
+	return &impersonationUsecase{
+		userRepo,
+		roleRepo,
+		impersonationRepo,
+		revocationStore,
+		audit,
+		log,
+		jwt,
+	}
+}
+
+// Start issues a short-lived access token of the user to the admin actor, the token carries
+// the admin in its act claim and comes without a refresh token
+func (u *impersonationUsecase) Start(actor entity.User, userID uint, props *entity.ImpersonateRequest, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	if actor.ID == userID {
+		return pkg.NewResponse(http.StatusBadRequest, "you can't impersonate yourself", nil, nil)
+	}
+
+	user, err := u.userRepo.GetById(userID, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusNotFound, "user not found", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	roles, permissions, err := userAccess(u.roleRepo, user.ID, db)
+	if err != nil {
+		u.log.Errorf("userAccess: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// impersonating another admin would hand over their privileges
+	if slices.Contains(roles, entity.RoleAdmin) || slices.Contains(permissions, entity.PermissionUsersImpersonate) {
+		return pkg.NewResponse(http.StatusForbidden, "administrators can't be impersonated", nil, nil)
+	}
+
+	exp := time.Duration(config.GetUint("IMPERSONATION_EXP_MINUTE")) * time.Minute
+	if exp == 0 {
+		exp = defaultImpersonationExp
+	}
+
+	accessToken, err := u.jwt.GenerateImpersonationToken(user.ID, user.Email, user.Username, roles, permissions, pkg.NewActorClaim(actor.ID, actor.Username), exp)
+	if err != nil {
+		u.log.Errorf("u.jwt.GenerateImpersonationToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	_, err = u.impersonationRepo.Insert(entity.ImpersonationSession{
+		ActorId:       actor.ID,
+		UserId:        user.ID,
+		AccessTokenId: accessToken.ID,
+		Reason:        props.Reason,
+		IPAddress:     client.IPAddress,
+		ExpiredAt:     accessToken.ExpiresAt,
+		CreatedAt:     time.Now(),
+	}, tx)
+	if err != nil {
+		u.log.Errorf("impersonationRepo.Insert: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventImpersonationStarted, UserId: auditUser(user.ID), ActorId: auditUser(actor.ID), Username: actor.Username, Details: props.Reason}, client)
+
+	data := map[string]any{
+		"access_token": accessToken.Token,
+		"expired_at":   accessToken.ExpiresAt,
+		"user": entity.UserResponse{
+			Name:     user.Name,
+			Username: user.Username,
+			Email:    user.Email,
+		},
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", data, nil)
+}
+
+// Stop ends the impersonation of the token claims were read from and revokes the token
+func (u *impersonationUsecase) Stop(claims *pkg.AccessClaims, client entity.ClientInfo) (resp pkg.Response) {
+	if claims.Act == nil {
+		return pkg.NewResponse(http.StatusBadRequest, "you are not impersonating anyone", nil, nil)
+	}
+
+	db := database.Get()
+
+	// the reason of the session goes to the audit log with the stop
+	session, err := u.impersonationRepo.GetByAccessTokenId(claims.ID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("impersonationRepo.GetByAccessTokenId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	if _, err := u.impersonationRepo.End(claims.ID, time.Now(), tx); err != nil {
+		u.log.Errorf("impersonationRepo.End: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := u.revocationStore.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		u.log.Errorf("revocationStore.Revoke: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventImpersonationStopped, UserId: auditUser(claims.UserID()), ActorId: auditUser(claims.Act.UserID()), Username: claims.Act.Username, Details: session.Reason}, client)
+
+	return pkg.NewResponse(http.StatusOK, "impersonation stopped", nil, nil)
+}
@@ -0,0 +1,113 @@
+package usecase_test
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+type fakeImpersonationRepo struct {
+	sessions []entity.ImpersonationSession
+}
+
+func (r *fakeImpersonationRepo) Insert(data entity.ImpersonationSession, tx *sqlx.Tx) (uint, error) {
+	data.ID = uint(len(r.sessions) + 1)
+	r.sessions = append(r.sessions, data)
+	return data.ID, nil
+}
+
+func (r *fakeImpersonationRepo) GetByAccessTokenId(accessTokenId string, db *sqlx.DB) (entity.ImpersonationSession, error) {
+	for _, session := range r.sessions {
+		if session.AccessTokenId == accessTokenId {
+			return session, nil
+		}
+	}
+	return entity.ImpersonationSession{}, sql.ErrNoRows
+}
+
+func (r *fakeImpersonationRepo) End(accessTokenId string, endedAt time.Time, tx *sqlx.Tx) (bool, error) {
+	for i, session := range r.sessions {
+		if session.AccessTokenId == accessTokenId && session.EndedAt == nil {
+			r.sessions[i].EndedAt = &endedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestImpersonation(t *testing.T) {
+	s := newAuthTestSuite(t)
+	s.seedRoles(t)
+	impersonationRepo := &fakeImpersonationRepo{}
+	jwt := newTestJWT(t)
+	uc := usecase.NewImpersonationUsecase(s.userRepo, s.roleRepo, impersonationRepo, s.revocationStore, s.audit, jwt)
+	bob := entity.User{ID: 2, Username: "bob"}
+
+	s.expectTx()
+	resp := uc.Start(bob, 1, &entity.ImpersonateRequest{Reason: "ticket 42"}, entity.ClientInfo{IPAddress: "10.0.0.1"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected impersonation to start, got %d: %s", resp.Code, resp.Message)
+	}
+
+	claims, err := jwt.VerifyAccessToken(resp.Data.(map[string]any)["access_token"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID() != 1 || claims.Act == nil || claims.Act.UserID() != 2 {
+		t.Fatalf("expected alice acted as by bob, got %+v", claims)
+	}
+
+	if session := impersonationRepo.sessions[0]; session.ActorId != 2 || session.UserId != 1 || session.AccessTokenId != claims.ID || session.Reason != "ticket 42" {
+		t.Fatalf("expected the start to be recorded, got %+v", session)
+	}
+	if event, ok := s.audit.last(entity.AuditEventImpersonationStarted); !ok || event.UserId == nil || *event.UserId != 1 || event.ActorId == nil || *event.ActorId != 2 || event.Details != "ticket 42" || event.IPAddress != "10.0.0.1" {
+		t.Fatalf("expected the start to be audited with the admin, got %+v", event)
+	}
+
+	s.expectTx()
+	if resp := uc.Stop(claims, entity.ClientInfo{}); resp.Code != http.StatusOK {
+		t.Fatalf("expected impersonation to stop, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if impersonationRepo.sessions[0].EndedAt == nil {
+		t.Fatal("expected the stop to be recorded")
+	}
+	if revoked, _ := s.revocationStore.IsRevoked(claims.ID); !revoked {
+		t.Fatal("expected the impersonation token to be revoked")
+	}
+	if event, ok := s.audit.last(entity.AuditEventImpersonationStopped); !ok || event.UserId == nil || *event.UserId != 1 || event.ActorId == nil || *event.ActorId != 2 || event.Details != "ticket 42" {
+		t.Fatalf("expected the stop to be audited with the admin, got %+v", event)
+	}
+
+	if resp := uc.Stop(&pkg.AccessClaims{}, entity.ClientInfo{}); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected stop without impersonation to be refused, got %d", resp.Code)
+	}
+}
+
+func TestImpersonationRefusesSelfAndAdmins(t *testing.T) {
+	s := newAuthTestSuite(t)
+	roleUC := s.seedRoles(t)
+	uc := usecase.NewImpersonationUsecase(s.userRepo, s.roleRepo, &fakeImpersonationRepo{}, s.revocationStore, s.audit, newTestJWT(t))
+	alice := entity.User{ID: 1, Username: "alice"}
+	bob := entity.User{ID: 2, Username: "bob"}
+
+	if resp := uc.Start(bob, 2, &entity.ImpersonateRequest{Reason: "test"}, entity.ClientInfo{}); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected self impersonation to be refused, got %d", resp.Code)
+	}
+
+	s.expectTx()
+	roleUC.AssignRole(2, 1, &entity.AssignRoleRequest{Role: entity.RoleAdmin})
+
+	if resp := uc.Start(bob, 1, &entity.ImpersonateRequest{Reason: "test"}, entity.ClientInfo{}); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected impersonating an admin to be refused, got %d", resp.Code)
+	}
+	if resp := uc.Start(alice, 99, &entity.ImpersonateRequest{Reason: "test"}, entity.ClientInfo{}); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown user to be not found, got %d", resp.Code)
+	}
+}
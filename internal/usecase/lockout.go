@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+type LockoutUsecase interface {
+	Clear(actorID uint, props *entity.ClearLockoutRequest) (resp pkg.Response)
+}
+
+type lockoutUsecase struct {
+	loginAttemptStore store.LoginAttemptStore
+	log               *logrus.Logger
+}
+
+func NewLockoutUsecase(loginAttemptStore store.LoginAttemptStore) LockoutUsecase {
+	log := logger.Get()
+
+	return &lockoutUsecase{
+		loginAttemptStore,
+		log,
+	}
+}
+
+// Clear drops the failed login counters and locks of a username and/or a client IP
+func (u *lockoutUsecase) Clear(actorID uint, props *entity.ClearLockoutRequest) (resp pkg.Response) {
+	var keys []loginKey
+	if props.Username != "" {
+		keys = append(keys, usernameLoginKey(props.Username))
+	}
+	if props.IPAddress != "" {
+		keys = append(keys, ipLoginKey(props.IPAddress))
+	}
+
+	for _, key := range keys {
+		if err := u.loginAttemptStore.Reset(key.key); err != nil {
+			u.log.Errorf("loginAttemptStore.Reset: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+
+		u.log.WithFields(logrus.Fields{
+			"event":    "login_unlocked",
+			"key":      key.key,
+			"reason":   "admin",
+			"actor_id": actorID,
+		}).Info("login key unlocked")
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", nil, nil)
+}
@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// loginKey is a login attempt counter together with the failures it tolerates before locking
+type loginKey struct {
+	key         string
+	maxAttempts int
+}
+
+func usernameLoginKey(username string) loginKey {
+	return loginKey{
+		key:         "username:" + pkg.NormalizeIdentifier(username),
+		maxAttempts: config.GetInt("LOGIN_MAX_ATTEMPTS_PER_USERNAME"),
+	}
+}
+
+func ipLoginKey(ip string) loginKey {
+	return loginKey{
+		key:         "ip:" + ip,
+		maxAttempts: config.GetInt("LOGIN_MAX_ATTEMPTS_PER_IP"),
+	}
+}
+
+// loginGuard applies exponential backoff to failed logins, once a key reaches its threshold
+// every further failure locks it for twice as long as the previous one
+type loginGuard struct {
+	store store.LoginAttemptStore
+	log   *logrus.Logger
+}
+
+// retryAfter returns how long the longest lock among keys still lasts, zero when none is locked
+func (g loginGuard) retryAfter(keys ...loginKey) time.Duration {
+	now := time.Now()
+
+	var wait time.Duration
+	for _, key := range keys {
+		attempt, err := g.store.Get(key.key)
+		if err != nil {
+			g.log.Errorf("loginAttemptStore.Get: %s", err.Error())
+			continue
+		}
+
+		if attempt.Locked(now) {
+			wait = max(wait, attempt.LockedUntil.Sub(now))
+		}
+	}
+
+	return wait
+}
+
+func (g loginGuard) fail(keys ...loginKey) {
+	window := time.Duration(config.GetUint("LOGIN_ATTEMPT_WINDOW_MINUTE")) * time.Minute
+
+	for _, key := range keys {
+		if key.maxAttempts <= 0 {
+			continue
+		}
+
+		attempt, err := g.store.RecordFailure(key.key, window)
+		if err != nil {
+			g.log.Errorf("loginAttemptStore.RecordFailure: %s", err.Error())
+			continue
+		}
+
+		if attempt.Failures < key.maxAttempts {
+			continue
+		}
+
+		lockedUntil := time.Now().Add(lockoutDuration(attempt.Failures - key.maxAttempts))
+		if err := g.store.Lock(key.key, lockedUntil); err != nil {
+			g.log.Errorf("loginAttemptStore.Lock: %s", err.Error())
+			continue
+		}
+
+		g.log.WithFields(logrus.Fields{
+			"event":        "login_locked",
+			"key":          key.key,
+			"failures":     attempt.Failures,
+			"locked_until": lockedUntil,
+		}).Warn("too many failed logins, key locked")
+	}
+}
+
+// succeed clears the counter of key, a lock that was still recorded is logged as released
+func (g loginGuard) succeed(key loginKey) {
+	attempt, err := g.store.Get(key.key)
+	if err != nil {
+		g.log.Errorf("loginAttemptStore.Get: %s", err.Error())
+		return
+	}
+
+	if attempt.Failures == 0 && attempt.LockedUntil.IsZero() {
+		return
+	}
+
+	if err := g.store.Reset(key.key); err != nil {
+		g.log.Errorf("loginAttemptStore.Reset: %s", err.Error())
+		return
+	}
+
+	if !attempt.LockedUntil.IsZero() {
+		g.log.WithFields(logrus.Fields{
+			"event":  "login_unlocked",
+			"key":    key.key,
+			"reason": "successful_login",
+		}).Info("login key unlocked")
+	}
+}
+
+// lockoutDuration doubles LOGIN_LOCKOUT_BASE_SECOND for every failure beyond the threshold,
+// capped at LOGIN_LOCKOUT_MAX_SECOND
+func lockoutDuration(excess int) time.Duration {
+	base := time.Duration(config.GetUint("LOGIN_LOCKOUT_BASE_SECOND")) * time.Second
+	limit := time.Duration(config.GetUint("LOGIN_LOCKOUT_MAX_SECOND")) * time.Second
+
+	lockout := base
+	for range excess {
+		if limit > 0 && lockout >= limit {
+			break
+		}
+		lockout *= 2
+	}
+
+	if limit > 0 && lockout > limit {
+		return limit
+	}
+
+	return lockout
+}
@@ -0,0 +1,114 @@
+package usecase_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func newLockoutSuite(t *testing.T) *authTestSuite {
+	t.Helper()
+
+	s := newAuthTestSuite(t)
+	config.Set("LOGIN_MAX_ATTEMPTS_PER_USERNAME", 3)
+	config.Set("LOGIN_MAX_ATTEMPTS_PER_IP", 10)
+	config.Set("LOGIN_ATTEMPT_WINDOW_MINUTE", 15)
+	config.Set("LOGIN_LOCKOUT_BASE_SECOND", 30)
+	config.Set("LOGIN_LOCKOUT_MAX_SECOND", 3600)
+	t.Cleanup(func() {
+		config.Set("LOGIN_MAX_ATTEMPTS_PER_USERNAME", 0)
+		config.Set("LOGIN_MAX_ATTEMPTS_PER_IP", 0)
+	})
+
+	return s
+}
+
+func (s *authTestSuite) failLogin(t *testing.T, ip string) pkg.Response {
+	t.Helper()
+
+	return s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "wrong"}, entity.ClientInfo{IPAddress: ip})
+}
+
+func TestLoginLockSharedByUsernameAndEmail(t *testing.T) {
+	s := newLockoutSuite(t)
+
+	for range 3 {
+		s.failLogin(t, "10.0.0.1")
+	}
+
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "alice@example.com", Password: "secret"}, entity.ClientInfo{IPAddress: "10.0.0.2"})
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected login by email of a locked username to be refused, got %d", resp.Code)
+	}
+}
+
+func TestLoginLocksUsernameAfterThreshold(t *testing.T) {
+	s := newLockoutSuite(t)
+
+	for range 3 {
+		if resp := s.failLogin(t, "10.0.0.1"); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected failed login, got %d", resp.Code)
+		}
+	}
+
+	// the correct password is refused while locked, from any address
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{IPAddress: "10.0.0.2"})
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected locked login to be refused, got %d", resp.Code)
+	}
+
+	if retryAfter := resp.Data.(map[string]any)["retry_after"].(int); retryAfter <= 0 || retryAfter > 30 {
+		t.Fatalf("expected retry after within the base lockout, got %d", retryAfter)
+	}
+
+	resp = usecase.NewLockoutUsecase(s.attemptStore).Clear(99, &entity.ClearLockoutRequest{Username: "Alice"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected clear to succeed, got %d", resp.Code)
+	}
+
+	s.login(t)
+}
+
+func TestLoginLockoutBacksOffExponentially(t *testing.T) {
+	s := newLockoutSuite(t)
+
+	for range 3 {
+		s.failLogin(t, "10.0.0.1")
+	}
+
+	// once the first lock has passed, the next failure locks twice as long
+	s.attemptStore.Lock("username:alice", time.Now())
+	s.failLogin(t, "10.0.0.1")
+
+	attempt, _ := s.attemptStore.Get("username:alice")
+	if wait := time.Until(attempt.LockedUntil); wait <= 30*time.Second || wait > time.Minute {
+		t.Fatalf("expected a lock of about a minute, got %s", wait)
+	}
+}
+
+func TestLoginLocksClientIP(t *testing.T) {
+	s := newLockoutSuite(t)
+	config.Set("LOGIN_MAX_ATTEMPTS_PER_USERNAME", 0)
+	config.Set("LOGIN_MAX_ATTEMPTS_PER_IP", 2)
+
+	for range 2 {
+		s.usecase.Login(&entity.LoginRequest{Username: "unknown", Password: "wrong"}, entity.ClientInfo{IPAddress: "10.0.0.1"})
+	}
+
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{IPAddress: "10.0.0.1"})
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected locked address to be refused, got %d", resp.Code)
+	}
+
+	// other addresses are not affected
+	s.expectTx()
+	resp = s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{IPAddress: "10.0.0.2"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected login from another address to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+}
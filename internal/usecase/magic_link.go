@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/mailer"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/scheduler"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+type MagicLinkUsecase interface {
+	Send(props *entity.MagicLinkRequest, client entity.ClientInfo) (resp pkg.Response)
+	Verify(props *entity.VerifyMagicLinkRequest, nonce string, client entity.ClientInfo) (resp pkg.Response)
+}
+
+type magicLinkUsecase struct {
+	userRepo      repository.UserRepository
+	magicLinkRepo repository.MagicLinkRepository
+	authUC        AuthUsecase
+	mailer        mailer.Mailer
+	audit         AuditLogger
+	background    *scheduler.Background
+	log           *logrus.Logger
+}
+
+func NewMagicLinkUsecase(userRepo repository.UserRepository, magicLinkRepo repository.MagicLinkRepository, authUC AuthUsecase, mailer mailer.Mailer, audit AuditLogger, background *scheduler.Background) MagicLinkUsecase {
+	log := logger.Get()
+
+	return &magicLinkUsecase{
+		userRepo,
+		magicLinkRepo,
+		authUC,
+		mailer,
+		audit,
+		background,
+		log,
+	}
+}
+
+// Send emails a sign in link to the user, the returned nonce binds the link to the browser
+// that asked for it. It always answers the same way and at once so it cannot be used to find
+// registered emails, the lookup and the email are left to the background
+func (u *magicLinkUsecase) Send(props *entity.MagicLinkRequest, client entity.ClientInfo) (resp pkg.Response) {
+	nonce, err := pkg.GenerateRandomString(32)
+	if err != nil {
+		u.log.Errorf("pkg.GenerateRandomString: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	email := pkg.NormalizeIdentifier(props.Email)
+	deviceLabel := props.DeviceLabel
+
+	u.background.Go("magic_link", func() error {
+		return u.requestLink(email, nonce, deviceLabel, client)
+	})
+
+	data := map[string]any{
+		"nonce": nonce,
+	}
+
+	return pkg.NewResponse(http.StatusOK, "if the email is registered, a sign in link has been sent", data, nil)
+}
+
+func (u *magicLinkUsecase) requestLink(email, nonce, deviceLabel string, client entity.ClientInfo) error {
+	user, err := u.userRepo.GetByEmail(email, database.Get())
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventMagicLinkRequested, Username: email, Reason: entity.AuditReasonUnknownUser}, client)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("userRepo.GetByEmail: %w", err)
+	}
+
+	if err := u.sendLink(user, nonce, deviceLabel); err != nil {
+		return fmt.Errorf("sendLink: %w", err)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventMagicLinkRequested, UserId: auditUser(user.ID)}, client)
+
+	return nil
+}
+
+// replaces any pending link of the user and emails a new one
+func (u *magicLinkUsecase) sendLink(user entity.User, nonce, deviceLabel string) error {
+	db := database.Get()
+
+	token, err := pkg.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := u.magicLinkRepo.InvalidateByUserId(user.ID, tx); err != nil {
+		return fmt.Errorf("magicLinkRepo.InvalidateByUserId: %w", err)
+	}
+
+	_, err = u.magicLinkRepo.Insert(entity.MagicLinkToken{
+		UserId:      user.ID,
+		Token:       hashToken(token),
+		Nonce:       hashToken(nonce),
+		DeviceLabel: deviceLabel,
+		ExpiredAt:   time.Now().Add(time.Duration(config.GetUint("MAGIC_LINK_EXP_MINUTE")) * time.Minute),
+		CreatedAt:   time.Now(),
+	}, tx)
+	if err != nil {
+		return fmt.Errorf("magicLinkRepo.Insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	return u.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign in link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below in the browser you requested it from to sign in:\n\n%s/magic-link?token=%s\n\nThe link expires in %d minutes and works once. If you did not request it, you can ignore this email.\n",
+			user.Name,
+			config.GetString("APP_URL"),
+			token,
+			config.GetUint("MAGIC_LINK_EXP_MINUTE"),
+		),
+	})
+}
+
+// Verify exchanges a link for a session like a login, nonce has to be the one Send returned
+// to the browser that asked for the link
+func (u *magicLinkUsecase) Verify(props *entity.VerifyMagicLinkRequest, nonce string, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	link, err := u.magicLinkRepo.GetValidByToken(hashToken(props.Token), db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusBadRequest, "invalid or expired sign in link", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("magicLinkRepo.GetValidByToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// a link opened in another browser is left usable for the one that asked for it
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(hashToken(nonce)), []byte(link.Nonce)) != 1 {
+		return pkg.NewResponse(http.StatusBadRequest, "the sign in link has to be opened in the browser it was requested from", nil, nil)
+	}
+
+	user, err := u.userRepo.GetById(link.UserId, db)
+	if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	used, err := u.magicLinkRepo.MarkUsed(link.ID, tx)
+	if err != nil {
+		u.log.Errorf("magicLinkRepo.MarkUsed: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !used {
+		return pkg.NewResponse(http.StatusBadRequest, "invalid or expired sign in link", nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventMagicLinkUsed, UserId: auditUser(user.ID)}, client)
+
+	return u.authUC.CompleteLogin(user, link.DeviceLabel, client)
+}
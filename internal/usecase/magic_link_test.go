@@ -0,0 +1,137 @@
+package usecase_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func newMagicLinkUsecase(t *testing.T) (usecase.MagicLinkUsecase, *fakeMagicLinkRepo, *authTestSuite) {
+	t.Helper()
+
+	s := newAuthTestSuite(t)
+	config.Set("MAGIC_LINK_EXP_MINUTE", 15)
+	config.Set("APP_URL", "https://app.example.com")
+
+	repo := newFakeMagicLinkRepo()
+
+	return usecase.NewMagicLinkUsecase(s.userRepo, repo, s.usecase, s.mailer, s.audit, s.background), repo, s
+}
+
+// sendMagicLink asks for a link for alice and returns its token and the nonce of the browser
+func sendMagicLink(t *testing.T, uc usecase.MagicLinkUsecase, s *authTestSuite) (token, nonce string) {
+	t.Helper()
+
+	s.expectTx()
+	resp := uc.Send(&entity.MagicLinkRequest{Email: "Alice@example.com"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected send to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	s.waitBackground(t)
+
+	msg, ok := s.mailer.Last("alice@example.com")
+	if !ok {
+		t.Fatal("expected a sign in email")
+	}
+	_, rest, _ := strings.Cut(msg.Body, "/magic-link?token=")
+
+	return strings.Fields(rest)[0], resp.Data.(map[string]any)["nonce"].(string)
+}
+
+func TestMagicLinkDoesNotRevealUnknownEmail(t *testing.T) {
+	uc, repo, s := newMagicLinkUsecase(t)
+
+	resp := uc.Send(&entity.MagicLinkRequest{Email: "nobody@example.com"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected unknown email to look successful, got %d", resp.Code)
+	}
+
+	if nonce, _ := resp.Data.(map[string]any)["nonce"].(string); nonce == "" {
+		t.Error("expected a nonce even for an unknown email")
+	}
+	s.waitBackground(t)
+
+	if got := len(s.mailer.Messages()); got != 0 {
+		t.Fatalf("expected no email, got %d", got)
+	}
+
+	if len(repo.tokens) != 0 {
+		t.Fatal("expected no link to be stored")
+	}
+
+	event, ok := s.audit.last(entity.AuditEventMagicLinkRequested)
+	if !ok || event.UserId != nil || event.Username != "nobody@example.com" || event.Reason != entity.AuditReasonUnknownUser {
+		t.Fatalf("expected the request for an unknown email to be audited, got %+v", event)
+	}
+}
+
+func TestMagicLinkSignsInOnce(t *testing.T) {
+	uc, repo, s := newMagicLinkUsecase(t)
+
+	token, nonce := sendMagicLink(t, uc, s)
+
+	if stored := repo.tokens[0]; stored.Token == token || stored.Nonce == nonce {
+		t.Fatal("expected the token and the nonce to be hashed at rest")
+	}
+
+	s.expectTx() // mark used
+	s.expectTx() // session
+	resp := uc.Verify(&entity.VerifyMagicLinkRequest{Token: token}, nonce, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected verify to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	data := resp.Data.(map[string]any)
+	if data["access_token"] == nil || data["refresh_token"] == nil {
+		t.Fatal("expected a session to be issued")
+	}
+	if event, ok := s.audit.last(entity.AuditEventMagicLinkUsed); !ok || event.UserId == nil || *event.UserId != 1 {
+		t.Fatalf("expected the sign in to be audited, got %+v", event)
+	}
+
+	resp = uc.Verify(&entity.VerifyMagicLinkRequest{Token: token}, nonce, entity.ClientInfo{})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected used link to be rejected, got %d", resp.Code)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMagicLinkBoundToRequestingBrowser(t *testing.T) {
+	uc, _, s := newMagicLinkUsecase(t)
+
+	token, nonce := sendMagicLink(t, uc, s)
+
+	for _, other := range []string{"", "another-browser"} {
+		resp := uc.Verify(&entity.VerifyMagicLinkRequest{Token: token}, other, entity.ClientInfo{})
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected link from another browser to be rejected, got %d", resp.Code)
+		}
+	}
+
+	// the requesting browser can still use it
+	s.expectTx()
+	s.expectTx()
+	resp := uc.Verify(&entity.VerifyMagicLinkRequest{Token: token}, nonce, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected verify to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+}
+
+func TestMagicLinkReplacesPendingLink(t *testing.T) {
+	uc, _, s := newMagicLinkUsecase(t)
+
+	first, firstNonce := sendMagicLink(t, uc, s)
+	sendMagicLink(t, uc, s)
+
+	resp := uc.Verify(&entity.VerifyMagicLinkRequest{Token: first}, firstNonce, entity.ClientInfo{})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected replaced link to be rejected, got %d", resp.Code)
+	}
+}
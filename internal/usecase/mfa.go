@@ -0,0 +1,436 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	recoveryCodeCount = 10
+	// number of TOTP steps accepted before and after the current one to tolerate clock drift
+	totpSkew = 1
+)
+
+type MFAUsecase interface {
+	Enroll(userID uint) (resp pkg.Response)
+	EnrollEmail(userID uint) (resp pkg.Response)
+	SendEmailCode(userID uint) (resp pkg.Response)
+	Confirm(userID uint, props *entity.ConfirmMFARequest, client entity.ClientInfo) (resp pkg.Response)
+	Disable(userID uint, props *entity.DisableMFARequest, client entity.ClientInfo) (resp pkg.Response)
+}
+
+type mfaUsecase struct {
+	userRepo   repository.UserRepository
+	mfaRepo    repository.MFARepository
+	emailOTP   EmailOTPUsecase
+	audit      AuditLogger
+	loginGuard loginGuard
+	log        *logrus.Logger
+}
+
+func NewMFAUsecase(userRepo repository.UserRepository, mfaRepo repository.MFARepository, emailOTP EmailOTPUsecase, audit AuditLogger, loginAttemptStore store.LoginAttemptStore) MFAUsecase {
+	log := logger.Get()
+	loginGuard := loginGuard{loginAttemptStore, log}
+
+	return &mfaUsecase{
+		userRepo,
+		mfaRepo,
+		emailOTP,
+		audit,
+		loginGuard,
+		log,
+	}
+}
+
+// Enroll generates a new TOTP secret, two-factor authentication stays off until Confirm
+func (u *mfaUsecase) Enroll(userID uint) (resp pkg.Response) {
+	db := database.Get()
+
+	user, err := u.userRepo.GetById(userID, db)
+	if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	mfa, err := u.mfaRepo.GetByUserId(userID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err == nil && mfa.EnabledAt != nil {
+		return pkg.NewResponse(http.StatusConflict, "two-factor authentication is already enabled", nil, nil)
+	}
+
+	secret, err := pkg.GenerateTOTPSecret()
+	if err != nil {
+		u.log.Errorf("pkg.GenerateTOTPSecret: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	encryptedSecret, err := pkg.Encrypt("", secret)
+	if err != nil {
+		u.log.Errorf("pkg.Encrypt: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	err = u.mfaRepo.Upsert(entity.UserMFA{
+		UserId:    userID,
+		Method:    entity.MFAMethodTOTP,
+		Secret:    encryptedSecret,
+		CreatedAt: time.Now(),
+	}, tx)
+	if err != nil {
+		u.log.Errorf("mfaRepo.Upsert: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	data := entity.MFAEnrollResponse{
+		Secret: secret,
+		URI:    pkg.TOTPURI(secret, mfaIssuer(), user.Email),
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", data, nil)
+}
+
+// EnrollEmail starts two-factor authentication by email for users without an authenticator
+// app, a code is sent to the email of the user and it stays off until Confirm
+func (u *mfaUsecase) EnrollEmail(userID uint) (resp pkg.Response) {
+	db := database.Get()
+
+	user, err := u.userRepo.GetById(userID, db)
+	if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	mfa, err := u.mfaRepo.GetByUserId(userID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err == nil && mfa.EnabledAt != nil {
+		return pkg.NewResponse(http.StatusConflict, "two-factor authentication is already enabled", nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	err = u.mfaRepo.Upsert(entity.UserMFA{
+		UserId:    userID,
+		Method:    entity.MFAMethodEmail,
+		CreatedAt: time.Now(),
+	}, tx)
+	if err != nil {
+		u.log.Errorf("mfaRepo.Upsert: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return u.sendEmailCode(user)
+}
+
+// SendEmailCode emails a new code to a user enrolled in two-factor authentication by email,
+// for confirming the enrollment or disabling it
+func (u *mfaUsecase) SendEmailCode(userID uint) (resp pkg.Response) {
+	db := database.Get()
+
+	user, err := u.userRepo.GetById(userID, db)
+	if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	mfa, err := u.mfaRepo.GetByUserId(userID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err != nil || mfa.Method != entity.MFAMethodEmail {
+		return pkg.NewResponse(http.StatusBadRequest, "two-factor authentication by email is not enrolled", nil, nil)
+	}
+
+	return u.sendEmailCode(user)
+}
+
+func (u *mfaUsecase) sendEmailCode(user entity.User) (resp pkg.Response) {
+	retryAfter, err := u.emailOTP.Send(user)
+	if err != nil {
+		u.log.Errorf("emailOTP.Send: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if retryAfter > 0 {
+		return tooManyCodeRequests(retryAfter)
+	}
+
+	return pkg.NewResponse(http.StatusOK, "a code has been sent to your email", nil, nil)
+}
+
+// Confirm turns two-factor authentication on once the user proves the authenticator app
+// holds the secret, or with the code sent by email. The recovery codes are returned only once
+func (u *mfaUsecase) Confirm(userID uint, props *entity.ConfirmMFARequest, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	mfa, err := u.mfaRepo.GetByUserId(userID, db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusBadRequest, "two-factor authentication is not enrolled", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if mfa.EnabledAt != nil {
+		return pkg.NewResponse(http.StatusConflict, "two-factor authentication is already enabled", nil, nil)
+	}
+
+	// email codes are checked within the transaction that consumes them
+	var step int64
+	if mfa.Method != entity.MFAMethodEmail {
+		secret, err := pkg.Decrypt("", mfa.Secret)
+		if err != nil {
+			u.log.Errorf("pkg.Decrypt: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+
+		var ok bool
+		step, ok = pkg.ValidateTOTPCode(secret, props.Code, time.Now(), totpSkew)
+		if !ok {
+			return pkg.NewResponse(http.StatusBadRequest, "invalid two-factor authentication code", nil, nil)
+		}
+	}
+
+	recoveryCodes, recoveryCodeData, err := generateRecoveryCodes(userID)
+	if err != nil {
+		u.log.Errorf("generateRecoveryCodes: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	if mfa.Method == entity.MFAMethodEmail {
+		valid, err := u.emailOTP.Verify(userID, props.Code, tx)
+		if err != nil {
+			u.log.Errorf("emailOTP.Verify: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+
+		if !valid {
+			return pkg.NewResponse(http.StatusBadRequest, "invalid two-factor authentication code", nil, nil)
+		}
+	} else {
+		// a step that was already used can't be replayed to confirm
+		used, err := u.mfaRepo.UseStep(userID, step, tx)
+		if err != nil {
+			u.log.Errorf("mfaRepo.UseStep: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+
+		if !used {
+			return pkg.NewResponse(http.StatusBadRequest, "invalid two-factor authentication code", nil, nil)
+		}
+	}
+
+	if err := u.mfaRepo.Enable(userID, tx); err != nil {
+		u.log.Errorf("mfaRepo.Enable: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := u.mfaRepo.DeleteRecoveryCodesByUserId(userID, tx); err != nil {
+		u.log.Errorf("mfaRepo.DeleteRecoveryCodesByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := u.mfaRepo.InsertRecoveryCodes(recoveryCodeData, tx); err != nil {
+		u.log.Errorf("mfaRepo.InsertRecoveryCodes: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventMFAEnabled, UserId: auditUser(userID)}, client)
+
+	data := map[string]any{
+		"recovery_codes": recoveryCodes,
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", data, nil)
+}
+
+// Disable turns two-factor authentication off, it requires the password and a second factor
+func (u *mfaUsecase) Disable(userID uint, props *entity.DisableMFARequest, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	user, err := u.userRepo.GetById(userID, db)
+	if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	// the password and the code are guessed against the same counters as a login
+	usernameKey, ipKey := usernameLoginKey(user.Username), ipLoginKey(client.IPAddress)
+	if wait := u.loginGuard.retryAfter(usernameKey, ipKey); wait > 0 {
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(user.ID), Reason: entity.AuditReasonLocked}, client)
+		return tooManyLoginAttempts(wait)
+	}
+
+	if !pkg.CheckPasswordHash(props.Password, user.Password) {
+		u.loginGuard.fail(usernameKey, ipKey)
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(user.ID), Reason: entity.AuditReasonInvalidPassword}, client)
+		return pkg.NewResponse(http.StatusBadRequest, "password is incorrect", nil, nil)
+	}
+
+	mfa, err := u.mfaRepo.GetByUserId(userID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("mfaRepo.GetByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err != nil || mfa.EnabledAt == nil {
+		return pkg.NewResponse(http.StatusBadRequest, "two-factor authentication is not enabled", nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	valid, err := verifySecondFactor(u.mfaRepo, u.emailOTP, mfa, props.Code, tx)
+	if err != nil {
+		u.log.Errorf("verifySecondFactor: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !valid {
+		u.loginGuard.fail(usernameKey, ipKey)
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(user.ID), Reason: entity.AuditReasonInvalidMFACode}, client)
+		return pkg.NewResponse(http.StatusBadRequest, "invalid two-factor authentication code", nil, nil)
+	}
+
+	if err := u.mfaRepo.DeleteRecoveryCodesByUserId(userID, tx); err != nil {
+		u.log.Errorf("mfaRepo.DeleteRecoveryCodesByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := u.mfaRepo.Delete(userID, tx); err != nil {
+		u.log.Errorf("mfaRepo.Delete: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.loginGuard.succeed(usernameKey)
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventMFADisabled, UserId: auditUser(userID)}, client)
+
+	return pkg.NewResponse(http.StatusOK, "success", nil, nil)
+}
+
+// the issuer shown by authenticator apps next to the account
+func mfaIssuer() string {
+	if issuer := config.GetString("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+
+	return config.GetString("NAME")
+}
+
+// verifySecondFactor accepts a code of the enrolled method, from the authenticator app or
+// sent by email, or an unused recovery code of the enrollment. Codes are consumed within tx
+// so none can be used twice
+func verifySecondFactor(mfaRepo repository.MFARepository, emailOTP EmailOTPUsecase, mfa entity.UserMFA, code string, tx *sqlx.Tx) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if mfa.Method == entity.MFAMethodEmail && len(code) == emailOTPDigits && strings.Trim(code, "0123456789") == "" {
+		return emailOTP.Verify(mfa.UserId, code, tx)
+	}
+
+	if mfa.Method != entity.MFAMethodEmail && len(code) == pkg.TOTPDigits && strings.Trim(code, "0123456789") == "" {
+		secret, err := pkg.Decrypt("", mfa.Secret)
+		if err != nil {
+			return false, fmt.Errorf("pkg.Decrypt: %w", err)
+		}
+
+		step, ok := pkg.ValidateTOTPCode(secret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+
+		return mfaRepo.UseStep(mfa.UserId, step, tx)
+	}
+
+	return mfaRepo.UseRecoveryCode(mfa.UserId, hashToken(normalizeRecoveryCode(code)), tx)
+}
+
+// returns the recovery codes to show to the user together with their hashed rows
+func generateRecoveryCodes(userID uint) (codes []string, data []entity.MFARecoveryCode, err error) {
+	for range recoveryCodeCount {
+		code, err := pkg.GenerateRandomString(5)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		data = append(data, entity.MFARecoveryCode{
+			UserId:    userID,
+			Code:      hashToken(code),
+			CreatedAt: time.Now(),
+		})
+	}
+
+	return codes, data, nil
+}
+
+// recovery codes are accepted with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(code, "-", ""), " ", ""))
+}
@@ -0,0 +1,317 @@
+package usecase_test
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+type fakeMFARepo struct {
+	enrollments   map[uint]entity.UserMFA
+	recoveryCodes []entity.MFARecoveryCode
+}
+
+func (r *fakeMFARepo) GetByUserId(userId uint, db *sqlx.DB) (entity.UserMFA, error) {
+	mfa, ok := r.enrollments[userId]
+	if !ok {
+		return entity.UserMFA{}, sql.ErrNoRows
+	}
+	return mfa, nil
+}
+
+func (r *fakeMFARepo) Upsert(data entity.UserMFA, tx *sqlx.Tx) error {
+	if r.enrollments == nil {
+		r.enrollments = make(map[uint]entity.UserMFA)
+	}
+	r.enrollments[data.UserId] = data
+	return nil
+}
+
+func (r *fakeMFARepo) Enable(userId uint, tx *sqlx.Tx) error {
+	mfa := r.enrollments[userId]
+	now := time.Now()
+	mfa.EnabledAt = &now
+	r.enrollments[userId] = mfa
+	return nil
+}
+
+func (r *fakeMFARepo) UseStep(userId uint, step int64, tx *sqlx.Tx) (bool, error) {
+	mfa, ok := r.enrollments[userId]
+	if !ok || (mfa.LastUsedStep != nil && *mfa.LastUsedStep >= step) {
+		return false, nil
+	}
+	mfa.LastUsedStep = &step
+	r.enrollments[userId] = mfa
+	return true, nil
+}
+
+func (r *fakeMFARepo) Delete(userId uint, tx *sqlx.Tx) error {
+	delete(r.enrollments, userId)
+	return nil
+}
+
+func (r *fakeMFARepo) InsertRecoveryCodes(data []entity.MFARecoveryCode, tx *sqlx.Tx) error {
+	r.recoveryCodes = append(r.recoveryCodes, data...)
+	return nil
+}
+
+func (r *fakeMFARepo) UseRecoveryCode(userId uint, code string, tx *sqlx.Tx) (bool, error) {
+	for i, c := range r.recoveryCodes {
+		if c.UserId == userId && c.Code == code && c.UsedAt == nil {
+			now := time.Now()
+			r.recoveryCodes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeMFARepo) DeleteRecoveryCodesByUserId(userId uint, tx *sqlx.Tx) error {
+	codes := r.recoveryCodes[:0]
+	for _, c := range r.recoveryCodes {
+		if c.UserId != userId {
+			codes = append(codes, c)
+		}
+	}
+	r.recoveryCodes = codes
+	return nil
+}
+
+// enrolls and confirms two-factor authentication for alice, returning the secret and recovery codes
+func enableMFA(t *testing.T, s *authTestSuite) (string, []string) {
+	t.Helper()
+
+	uc := usecase.NewMFAUsecase(s.userRepo, s.mfaRepo, s.emailOTP, s.audit, s.attemptStore)
+
+	s.expectTx()
+	resp := uc.Enroll(1)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected enroll to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	secret := resp.Data.(entity.MFAEnrollResponse).Secret
+
+	if s.mfaRepo.enrollments[1].Secret == secret {
+		t.Fatal("secret should be stored encrypted")
+	}
+
+	// a code from the previous step keeps the current one usable for the login that follows
+	code, err := pkg.GenerateTOTPCode(secret, time.Now().Add(-pkg.TOTPPeriod))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.expectTx()
+	resp = uc.Confirm(1, &entity.ConfirmMFARequest{Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected confirm to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	if _, ok := s.audit.last(entity.AuditEventMFAEnabled); !ok {
+		t.Fatal("expected enabling two-factor authentication to be audited")
+	}
+
+	return secret, resp.Data.(map[string]any)["recovery_codes"].([]string)
+}
+
+func TestConfirmMFARejectsReplayedStep(t *testing.T) {
+	s := newAuthTestSuite(t)
+	uc := usecase.NewMFAUsecase(s.userRepo, s.mfaRepo, s.emailOTP, s.audit, s.attemptStore)
+
+	s.expectTx()
+	secret := uc.Enroll(1).Data.(entity.MFAEnrollResponse).Secret
+
+	now := time.Now()
+	code, err := pkg.GenerateTOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the step of the code was already accepted by a confirm that raced this one
+	mfa := s.mfaRepo.enrollments[1]
+	step := pkg.TOTPStep(now)
+	mfa.LastUsedStep = &step
+	s.mfaRepo.enrollments[1] = mfa
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	if resp := uc.Confirm(1, &entity.ConfirmMFARequest{Code: code}, entity.ClientInfo{}); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected a replayed step to be rejected, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if s.mfaRepo.enrollments[1].EnabledAt != nil {
+		t.Fatal("two-factor authentication should stay off")
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (s *authTestSuite) mfaChallenge(t *testing.T) string {
+	t.Helper()
+
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected password step to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	data := resp.Data.(map[string]any)
+	if data["mfa_required"] != true {
+		t.Fatalf("expected an mfa challenge, got %v", data)
+	}
+	if _, ok := data["access_token"]; ok {
+		t.Fatal("no session should be opened before the second step")
+	}
+
+	return data["mfa_token"].(string)
+}
+
+func TestLoginWithTOTP(t *testing.T) {
+	s := newAuthTestSuite(t)
+	secret, _ := enableMFA(t, s)
+
+	mfaToken := s.mfaChallenge(t)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp := s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: "000000"}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected wrong code to be rejected, got %d", resp.Code)
+	}
+
+	code, err := pkg.GenerateTOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.expectTx()
+	s.expectTx()
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected second step to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	if _, ok := resp.Data.(map[string]any)["refresh_token"]; !ok {
+		t.Fatal("expected session tokens")
+	}
+
+	// the challenge is spent once it opened a session
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected used challenge to be rejected, got %d", resp.Code)
+	}
+
+	// the same code cannot be replayed with a new challenge either
+	mfaToken = s.mfaChallenge(t)
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected replayed code to be rejected, got %d", resp.Code)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDisableMFAGuessesCountAsFailedLogins(t *testing.T) {
+	s := newAuthTestSuite(t)
+	config.Set("LOGIN_MAX_ATTEMPTS_PER_USERNAME", 2)
+	config.Set("LOGIN_ATTEMPT_WINDOW_MINUTE", 15)
+	config.Set("LOGIN_LOCKOUT_BASE_SECOND", 30)
+	t.Cleanup(func() { config.Set("LOGIN_MAX_ATTEMPTS_PER_USERNAME", 0) })
+
+	_, recoveryCodes := enableMFA(t, s)
+	uc := usecase.NewMFAUsecase(s.userRepo, s.mfaRepo, s.emailOTP, s.audit, s.attemptStore)
+
+	resp := uc.Disable(1, &entity.DisableMFARequest{Password: "wrong", Code: recoveryCodes[0]}, entity.ClientInfo{})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected wrong password to be rejected, got %d", resp.Code)
+	}
+
+	failed, ok := s.audit.last(entity.AuditEventLoginFailed)
+	if !ok || failed.Reason != entity.AuditReasonInvalidPassword {
+		t.Fatalf("expected the wrong password to be audited, got %+v", failed)
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp = uc.Disable(1, &entity.DisableMFARequest{Password: "secret", Code: "000000"}, entity.ClientInfo{})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected wrong code to be rejected, got %d", resp.Code)
+	}
+
+	failed, _ = s.audit.last(entity.AuditEventLoginFailed)
+	if failed.Reason != entity.AuditReasonInvalidMFACode {
+		t.Fatalf("expected the wrong code to be audited, got %+v", failed)
+	}
+
+	resp = uc.Disable(1, &entity.DisableMFARequest{Password: "secret", Code: recoveryCodes[0]}, entity.ClientInfo{})
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a locked account to be refused, got %d", resp.Code)
+	}
+
+	if s.mfaRepo.enrollments[1].EnabledAt == nil {
+		t.Fatal("two-factor authentication should stay on")
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginWithRecoveryCode(t *testing.T) {
+	s := newAuthTestSuite(t)
+	_, recoveryCodes := enableMFA(t, s)
+
+	if len(recoveryCodes) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(recoveryCodes))
+	}
+
+	mfaToken := s.mfaChallenge(t)
+
+	s.expectTx()
+	s.expectTx()
+	resp := s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: recoveryCodes[0]}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected recovery code to be accepted, got %d: %s", resp.Code, resp.Message)
+	}
+
+	mfaToken = s.mfaChallenge(t)
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp = s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: mfaToken, Code: recoveryCodes[0]}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected used recovery code to be rejected, got %d", resp.Code)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyMFARejectsOtherTokenTypes(t *testing.T) {
+	s := newAuthTestSuite(t)
+	secret, _ := enableMFA(t, s)
+
+	refreshToken, err := newTestJWT(t).GenerateRefreshToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := pkg.GenerateTOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := s.usecase.VerifyMFA(&entity.MFALoginRequest{MFAToken: refreshToken, Code: code}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected refresh token to be rejected as challenge, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,369 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+type OAuthUsecase interface {
+	Start(provider string, linkUserID uint, deviceLabel string) (resp pkg.Response)
+	Callback(provider string, props *entity.OAuthCallbackRequest, flow string, client entity.ClientInfo) (resp pkg.Response)
+	ListIdentities(userID uint) (resp pkg.Response)
+	Unlink(userID uint, provider string) (resp pkg.Response)
+}
+
+type oauthUsecase struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	authUC       AuthUsecase
+	providers    map[string]oauth.Provider
+	log          *logrus.Logger
+}
+
+func NewOAuthUsecase(userRepo repository.UserRepository, identityRepo repository.IdentityRepository, authUC AuthUsecase, providers map[string]oauth.Provider) OAuthUsecase {
+	log := logger.Get()
+
+	return &oauthUsecase{
+		userRepo,
+		identityRepo,
+		authUC,
+		providers,
+		log,
+	}
+}
+
+// Start begins an authorization code flow with the provider, the flow is returned sealed so
+// the client can keep it until the callback. linkUserID links the external account to a
+// signed in user instead of signing in
+func (u *oauthUsecase) Start(provider string, linkUserID uint, deviceLabel string) (resp pkg.Response) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return pkg.NewResponse(http.StatusNotFound, "unknown provider", nil, nil)
+	}
+
+	flow := entity.OAuthFlow{
+		Provider:    provider,
+		DeviceLabel: deviceLabel,
+		LinkUserID:  linkUserID,
+		ExpiredAt:   time.Now().Add(time.Duration(config.GetUint("OAUTH_FLOW_EXP_MINUTE")) * time.Minute),
+	}
+
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.CodeVerifier} {
+		random, err := pkg.GenerateRandomString(32)
+		if err != nil {
+			u.log.Errorf("pkg.GenerateRandomString: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+		*value = random
+	}
+
+	authURL, err := p.AuthCodeURL(oauth.AuthRequest{State: flow.State, Nonce: flow.Nonce, CodeVerifier: flow.CodeVerifier})
+	if err != nil {
+		u.log.Errorf("provider.AuthCodeURL: %s", err.Error())
+		return pkg.NewResponse(http.StatusBadGateway, "provider is unavailable", nil, nil)
+	}
+
+	sealed, err := sealOAuthFlow(flow)
+	if err != nil {
+		u.log.Errorf("sealOAuthFlow: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	data := map[string]any{
+		"authorization_url": authURL,
+		"flow":              sealed,
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", data, nil)
+}
+
+// Callback finishes the flow: the code is exchanged for the external identity, which signs in
+// the user it is linked to, is linked to the existing user of the same verified email or
+// signs up a new user
+func (u *oauthUsecase) Callback(provider string, props *entity.OAuthCallbackRequest, sealedFlow string, client entity.ClientInfo) (resp pkg.Response) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return pkg.NewResponse(http.StatusNotFound, "unknown provider", nil, nil)
+	}
+
+	flow, err := openOAuthFlow(sealedFlow)
+	if err != nil || flow.Provider != provider || time.Now().After(flow.ExpiredAt) ||
+		subtle.ConstantTimeCompare([]byte(flow.State), []byte(props.State)) != 1 {
+		return pkg.NewResponse(http.StatusBadRequest, "invalid or expired sign in request", nil, nil)
+	}
+
+	if props.Error != "" {
+		return pkg.NewResponse(http.StatusUnauthorized, "sign in was cancelled at the provider", nil, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	identity, err := p.Exchange(ctx, props.Code, oauth.AuthRequest{State: flow.State, Nonce: flow.Nonce, CodeVerifier: flow.CodeVerifier})
+	if err != nil {
+		u.log.Errorf("provider.Exchange: %s", err.Error())
+		return pkg.NewResponse(http.StatusUnauthorized, "failed to sign in with the provider", nil, nil)
+	}
+
+	if flow.LinkUserID != 0 {
+		return u.link(flow.LinkUserID, provider, identity)
+	}
+
+	return u.login(provider, identity, flow.DeviceLabel, client)
+}
+
+func (u *oauthUsecase) login(provider string, identity oauth.Identity, deviceLabel string, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	linked, err := u.identityRepo.GetByProviderSubject(provider, identity.Subject, db)
+	if err == nil {
+		user, err := u.userRepo.GetById(linked.UserId, db)
+		if err != nil {
+			u.log.Errorf("userRepo.GetById: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+
+		return u.authUC.CompleteLogin(user, deviceLabel, client)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("identityRepo.GetByProviderSubject: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// a first sign in is matched to users by email, which only proves anything when verified
+	if identity.Email == "" || !identity.EmailVerified {
+		return pkg.NewResponse(http.StatusForbidden, "the provider did not share a verified email", nil, nil)
+	}
+
+	email := pkg.NormalizeIdentifier(identity.Email)
+
+	user, err := u.userRepo.GetByEmail(email, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("userRepo.GetByEmail: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// whoever registered an unverified email may not own it, the owner has to sign in with
+	// the password and link the provider
+	if err == nil && user.EmailVerifiedAt == nil {
+		return pkg.NewResponse(http.StatusConflict, "an account with this email already exists, sign in to link the provider", nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	if user.ID == 0 {
+		if user, err = u.createUser(identity, email, tx); errors.Is(err, pkg.ErrEmailTaken) {
+			return pkg.NewResponse(http.StatusConflict, err.Error(), nil, nil)
+		} else if err != nil {
+			u.log.Errorf("u.createUser: %s", err.Error())
+			return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+		}
+	}
+
+	if resp, ok := u.insertIdentity(user.ID, provider, identity, tx); !ok {
+		return resp
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return u.authUC.CompleteLogin(user, deviceLabel, client)
+}
+
+func (u *oauthUsecase) link(userID uint, provider string, identity oauth.Identity) (resp pkg.Response) {
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	if resp, ok := u.insertIdentity(userID, provider, identity, tx); !ok {
+		return resp
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return pkg.NewResponse(http.StatusOK, "account linked", nil, nil)
+}
+
+func (u *oauthUsecase) insertIdentity(userID uint, provider string, identity oauth.Identity, tx *sqlx.Tx) (resp pkg.Response, ok bool) {
+	data := entity.UserIdentity{
+		UserId:    userID,
+		Provider:  provider,
+		Subject:   identity.Subject,
+		CreatedAt: time.Now(),
+	}
+	if identity.Email != "" {
+		email := pkg.NormalizeIdentifier(identity.Email)
+		data.Email = &email
+	}
+
+	_, err := u.identityRepo.Insert(data, tx)
+	if errors.Is(err, pkg.ErrIdentityLinked) || errors.Is(err, pkg.ErrProviderLinked) {
+		return pkg.NewResponse(http.StatusConflict, err.Error(), nil, nil), false
+	} else if err != nil {
+		u.log.Errorf("identityRepo.Insert: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil), false
+	}
+
+	u.log.WithFields(logrus.Fields{
+		"event":    "identity_linked",
+		"user_id":  userID,
+		"provider": provider,
+	}).Info("external account linked")
+
+	return resp, true
+}
+
+var usernameDisallowed = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// createUser signs up the user of an external identity, the password is random so the user
+// can only sign in with the provider until a password is set through the reset flow
+func (u *oauthUsecase) createUser(identity oauth.Identity, email string, tx *sqlx.Tx) (user entity.User, err error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = pkg.Truncate(usernameDisallowed.ReplaceAllString(pkg.NormalizeIdentifier(base), ""), 40)
+	if base == "" {
+		base = "user"
+	}
+
+	password, err := pkg.GenerateRandomString(32)
+	if err != nil {
+		return user, err
+	}
+
+	hashedPassword, err := pkg.HashPassword(password)
+	if err != nil {
+		return user, fmt.Errorf("pkg.HashPassword: %w", err)
+	}
+
+	now := time.Now()
+	user = entity.User{
+		Name:            identity.Name,
+		Email:           email,
+		EmailVerifiedAt: &now,
+		Password:        hashedPassword,
+	}
+	if user.Name == "" {
+		user.Name = base
+	}
+
+	// the handle of the provider may already be taken here, a random suffix is tried next
+	for attempt := 0; attempt < 3; attempt++ {
+		user.Username = base
+		if attempt > 0 {
+			suffix, err := pkg.GenerateRandomString(3)
+			if err != nil {
+				return user, err
+			}
+			user.Username = base + "-" + suffix
+		}
+
+		user.ID, err = u.userRepo.Insert(&user, tx)
+		if !errors.Is(err, pkg.ErrUsernameTaken) {
+			return user, err
+		}
+	}
+
+	return user, err
+}
+
+func (u *oauthUsecase) ListIdentities(userID uint) (resp pkg.Response) {
+	identities, err := u.identityRepo.GetByUserId(userID, database.Get())
+	if err != nil {
+		u.log.Errorf("identityRepo.GetByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if identities == nil {
+		identities = []entity.UserIdentity{}
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", identities, nil)
+}
+
+func (u *oauthUsecase) Unlink(userID uint, provider string) (resp pkg.Response) {
+	tx, err := database.Get().Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	deleted, err := u.identityRepo.Delete(userID, provider, tx)
+	if err != nil {
+		u.log.Errorf("identityRepo.Delete: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !deleted {
+		return pkg.NewResponse(http.StatusNotFound, "no account of this provider is linked", nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.log.WithFields(logrus.Fields{
+		"event":    "identity_unlinked",
+		"user_id":  userID,
+		"provider": provider,
+	}).Info("external account unlinked")
+
+	return pkg.NewResponse(http.StatusOK, "account unlinked", nil, nil)
+}
+
+// the flow is encrypted so the client can neither read the nonce and verifier nor forge a flow
+func sealOAuthFlow(flow entity.OAuthFlow) (string, error) {
+	content, err := json.Marshal(flow)
+	if err != nil {
+		return "", err
+	}
+
+	return pkg.Encrypt("", string(content))
+}
+
+func openOAuthFlow(sealed string) (flow entity.OAuthFlow, err error) {
+	if sealed == "" {
+		return flow, errors.New("missing flow")
+	}
+
+	content, err := pkg.Decrypt("", sealed)
+	if err != nil {
+		return flow, err
+	}
+
+	err = json.Unmarshal([]byte(content), &flow)
+	return flow, err
+}
@@ -0,0 +1,257 @@
+package usecase_test
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/oauth/oauthtest"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+type fakeIdentityRepo struct {
+	identities []entity.UserIdentity
+}
+
+func (r *fakeIdentityRepo) GetByProviderSubject(provider, subject string, db *sqlx.DB) (entity.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return entity.UserIdentity{}, sql.ErrNoRows
+}
+
+func (r *fakeIdentityRepo) GetByUserId(userId uint, db *sqlx.DB) (result []entity.UserIdentity, err error) {
+	for _, identity := range r.identities {
+		if identity.UserId == userId {
+			result = append(result, identity)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeIdentityRepo) Insert(data entity.UserIdentity, tx *sqlx.Tx) (uint, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == data.Provider && identity.Subject == data.Subject {
+			return 0, pkg.ErrIdentityLinked
+		}
+		if identity.Provider == data.Provider && identity.UserId == data.UserId {
+			return 0, pkg.ErrProviderLinked
+		}
+	}
+
+	data.ID = uint(len(r.identities) + 1)
+	r.identities = append(r.identities, data)
+	return data.ID, nil
+}
+
+func (r *fakeIdentityRepo) Delete(userId uint, provider string, tx *sqlx.Tx) (bool, error) {
+	for i, identity := range r.identities {
+		if identity.UserId == userId && identity.Provider == provider {
+			r.identities = append(r.identities[:i], r.identities[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type oauthTestSuite struct {
+	*authTestSuite
+	usecase      usecase.OAuthUsecase
+	identityRepo *fakeIdentityRepo
+	server       *oauthtest.Server
+}
+
+func newOAuthTestSuite(t *testing.T) *oauthTestSuite {
+	t.Helper()
+
+	s := newAuthTestSuite(t)
+	config.Set("OAUTH_FLOW_EXP_MINUTE", 10)
+
+	server, err := oauthtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	identityRepo := &fakeIdentityRepo{}
+	providers := map[string]oauth.Provider{
+		"test": server.Provider("test", "http://localhost:8080/api/v1/oauth/test/callback"),
+	}
+
+	return &oauthTestSuite{
+		authTestSuite: s,
+		usecase:       usecase.NewOAuthUsecase(s.userRepo, identityRepo, s.usecase, providers),
+		identityRepo:  identityRepo,
+		server:        server,
+	}
+}
+
+// signIn runs a flow where identity approves the request at the provider
+func (s *oauthTestSuite) signIn(t *testing.T, linkUserID uint, identity oauth.Identity) pkg.Response {
+	t.Helper()
+
+	resp := s.usecase.Start("test", linkUserID, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected start to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	data := resp.Data.(map[string]any)
+
+	code, state, err := s.server.Authorize(data["authorization_url"].(string), identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s.usecase.Callback("test", &entity.OAuthCallbackRequest{Code: code, State: state}, data["flow"].(string), entity.ClientInfo{IPAddress: "127.0.0.1"})
+}
+
+func TestOAuthSignsUpNewUser(t *testing.T) {
+	s := newOAuthTestSuite(t)
+
+	s.expectTx()
+	s.expectTx()
+	resp := s.signIn(t, 0, oauth.Identity{Subject: "sub-bob", Email: "Bob@Example.com", EmailVerified: true, Name: "Bob"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected sign up to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	user, err := s.userRepo.GetByEmail("bob@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "bob" || user.EmailVerifiedAt == nil {
+		t.Fatalf("expected verified user bob, got %q verified at %v", user.Username, user.EmailVerifiedAt)
+	}
+
+	// the next sign in finds the linked identity
+	s.expectTx()
+	resp = s.signIn(t, 0, oauth.Identity{Subject: "sub-bob", Email: "bob@example.com", EmailVerified: true})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected sign in to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	if len(s.userRepo.users) != 2 || len(s.identityRepo.identities) != 1 {
+		t.Fatalf("expected no new user or identity, got %d users and %d identities", len(s.userRepo.users), len(s.identityRepo.identities))
+	}
+}
+
+func TestOAuthSignUpSuffixesTakenUsername(t *testing.T) {
+	s := newOAuthTestSuite(t)
+
+	s.expectTx()
+	s.expectTx()
+	resp := s.signIn(t, 0, oauth.Identity{Subject: "sub-other", Email: "alice@other.example", EmailVerified: true, Username: "Alice"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected sign up to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	user, _ := s.userRepo.GetByEmail("alice@other.example", nil)
+	if user.Username == "alice" || len(user.Username) != len("alice-")+6 {
+		t.Fatalf("expected a suffixed username, got %q", user.Username)
+	}
+}
+
+func TestOAuthLinksVerifiedEmail(t *testing.T) {
+	s := newOAuthTestSuite(t)
+	identity := oauth.Identity{Subject: "sub-alice", Email: "alice@example.com", EmailVerified: true}
+
+	// an unverified local email may belong to someone else
+	resp := s.signIn(t, 0, identity)
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected conflict with unverified local email, got %d", resp.Code)
+	}
+
+	s.userRepo.MarkEmailVerified(1, nil)
+
+	s.expectTx()
+	s.expectTx()
+	resp = s.signIn(t, 0, identity)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected sign in to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+	if len(s.identityRepo.identities) != 1 || s.identityRepo.identities[0].UserId != 1 {
+		t.Fatalf("expected identity linked to alice, got %+v", s.identityRepo.identities)
+	}
+}
+
+func TestOAuthRefusesUnverifiedProviderEmail(t *testing.T) {
+	s := newOAuthTestSuite(t)
+
+	resp := s.signIn(t, 0, oauth.Identity{Subject: "sub-bob", Email: "bob@example.com"})
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected unverified provider email to be refused, got %d", resp.Code)
+	}
+	if len(s.userRepo.users) != 1 {
+		t.Fatal("expected no user to be created")
+	}
+}
+
+func TestOAuthLinkAndUnlink(t *testing.T) {
+	s := newOAuthTestSuite(t)
+
+	s.expectTx()
+	resp := s.signIn(t, 1, oauth.Identity{Subject: "sub-alice"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected link to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	resp = s.signIn(t, 1, oauth.Identity{Subject: "sub-alice-2"})
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected a second account of the provider to conflict, got %d", resp.Code)
+	}
+
+	if identities := s.usecase.ListIdentities(1).Data.([]entity.UserIdentity); len(identities) != 1 {
+		t.Fatalf("expected one identity, got %d", len(identities))
+	}
+
+	s.expectTx()
+	if resp := s.usecase.Unlink(1, "test"); resp.Code != http.StatusOK {
+		t.Fatalf("expected unlink to succeed, got %d", resp.Code)
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	if resp := s.usecase.Unlink(1, "test"); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected second unlink to be not found, got %d", resp.Code)
+	}
+}
+
+func TestOAuthCallbackRejectsForgedFlow(t *testing.T) {
+	s := newOAuthTestSuite(t)
+
+	data := s.usecase.Start("test", 0, "").Data.(map[string]any)
+	code, _, err := s.server.Authorize(data["authorization_url"].(string), oauth.Identity{Subject: "sub-bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		provider, state, flow string
+	}{
+		"state mismatch":   {"test", "other-state", data["flow"].(string)},
+		"missing flow":     {"test", "state", ""},
+		"tampered flow":    {"test", "state", data["flow"].(string) + "00"},
+		"unknown provider": {"other", "state", data["flow"].(string)},
+	}
+
+	for name, c := range cases {
+		resp := s.usecase.Callback(c.provider, &entity.OAuthCallbackRequest{Code: code, State: c.state}, c.flow, entity.ClientInfo{})
+		if resp.IsSuccess {
+			t.Fatalf("%s: expected callback to be rejected", name)
+		}
+	}
+
+	sealed, _ := pkg.Encrypt("", `{"provider":"test","state":"s","expired_at":"`+time.Now().Add(-time.Minute).Format(time.RFC3339)+`"}`)
+	resp := s.usecase.Callback("test", &entity.OAuthCallbackRequest{Code: code, State: "s"}, sealed, entity.ClientInfo{})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected expired flow to be rejected, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,346 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/mailer"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/scheduler"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+type PasswordUsecase interface {
+	Forgot(props *entity.ForgotPasswordRequest, client entity.ClientInfo) (resp pkg.Response)
+	Reset(props *entity.ResetPasswordRequest, client entity.ClientInfo) (resp pkg.Response)
+	Change(userID uint, accessTokenID string, props *entity.ChangePasswordRequest, client entity.ClientInfo) (resp pkg.Response)
+}
+
+type passwordUsecase struct {
+	userRepo          repository.UserRepository
+	authRepo          repository.AuthRepository
+	passwordResetRepo repository.PasswordResetRepository
+	mailer            mailer.Mailer
+	revocationStore   store.RevocationStore
+	loginGuard        loginGuard
+	audit             AuditLogger
+	passwordPolicy    *pkg.PasswordPolicy
+	background        *scheduler.Background
+	log               *logrus.Logger
+}
+
+func NewPasswordUsecase(userRepo repository.UserRepository, authRepo repository.AuthRepository, passwordResetRepo repository.PasswordResetRepository, mailer mailer.Mailer, revocationStore store.RevocationStore, loginAttemptStore store.LoginAttemptStore, audit AuditLogger, passwordPolicy *pkg.PasswordPolicy, background *scheduler.Background) PasswordUsecase {
+	log := logger.Get()
+	loginGuard := loginGuard{loginAttemptStore, log}
+
+	return &passwordUsecase{
+		userRepo,
+		authRepo,
+		passwordResetRepo,
+		mailer,
+		revocationStore,
+		loginGuard,
+		audit,
+		passwordPolicy,
+		background,
+		log,
+	}
+}
+
+// checkPasswordPolicy answers 422 with every rule of the policy password breaks, ok is false
+// when it breaks any
+func checkPasswordPolicy(policy *pkg.PasswordPolicy, field, password, username, email string) (resp pkg.Response, ok bool) {
+	violations := policy.Validate(field, password, username, email)
+	if len(violations) == 0 {
+		return resp, true
+	}
+
+	errResponse := map[string]any{
+		"errors": violations,
+	}
+
+	return pkg.NewResponse(http.StatusUnprocessableEntity, pkg.ErrValidation.Error(), errResponse, nil), false
+}
+
+// Forgot always answers the same way and at once so it cannot be used to find registered
+// emails, the lookup and the email are left to the background
+func (u *passwordUsecase) Forgot(props *entity.ForgotPasswordRequest, client entity.ClientInfo) (resp pkg.Response) {
+	email := pkg.NormalizeIdentifier(props.Email)
+
+	u.background.Go("password_reset", func() error {
+		return u.requestReset(email, client)
+	})
+
+	return pkg.NewResponse(http.StatusOK, "if the email is registered, a password reset link has been sent", nil, nil)
+}
+
+func (u *passwordUsecase) requestReset(email string, client entity.ClientInfo) error {
+	user, err := u.userRepo.GetByEmail(email, database.Get())
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventPasswordResetRequested, Username: email, Reason: entity.AuditReasonUnknownUser}, client)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("userRepo.GetByEmail: %w", err)
+	}
+
+	if err := u.sendResetLink(user); err != nil {
+		return fmt.Errorf("sendResetLink: %w", err)
+	}
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventPasswordResetRequested, UserId: auditUser(user.ID)}, client)
+
+	return nil
+}
+
+// replaces any pending reset token of the user and emails a new one
+func (u *passwordUsecase) sendResetLink(user entity.User) error {
+	db := database.Get()
+
+	token, err := pkg.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := u.passwordResetRepo.InvalidateByUserId(user.ID, tx); err != nil {
+		return fmt.Errorf("passwordResetRepo.InvalidateByUserId: %w", err)
+	}
+
+	_, err = u.passwordResetRepo.Insert(entity.PasswordResetToken{
+		UserId:    user.ID,
+		Token:     hashToken(token),
+		ExpiredAt: time.Now().Add(time.Duration(config.GetUint("PASSWORD_RESET_EXP_MINUTE")) * time.Minute),
+		CreatedAt: time.Now(),
+	}, tx)
+	if err != nil {
+		return fmt.Errorf("passwordResetRepo.Insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	return u.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one:\n\n%s/reset-password?token=%s\n\nThe link expires in %d minutes. If you did not request a reset, you can ignore this email.\n",
+			user.Name,
+			config.GetString("APP_URL"),
+			token,
+			config.GetUint("PASSWORD_RESET_EXP_MINUTE"),
+		),
+	})
+}
+
+// Reset sets the new password and signs the user out of every session
+func (u *passwordUsecase) Reset(props *entity.ResetPasswordRequest, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	resetToken, err := u.passwordResetRepo.GetValidByToken(hashToken(props.Token), db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusBadRequest, "invalid or expired reset token", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("passwordResetRepo.GetValidByToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	user, err := u.userRepo.GetById(resetToken.UserId, db)
+	if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// checked before the token is used so the user can try another password with the same link
+	if resp, ok := checkPasswordPolicy(u.passwordPolicy, "password", props.Password, user.Username, user.Email); !ok {
+		return resp
+	}
+
+	hashedPassword, err := pkg.HashPassword(props.Password)
+	if err != nil {
+		u.log.Errorf("pkg.HashPassword: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// read before revoking so every session still holding a live access token is covered
+	tokens, err := u.authRepo.GetLiveAccessTokensByUserId(resetToken.UserId, db)
+	if err != nil {
+		u.log.Errorf("authRepo.GetLiveAccessTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	used, err := u.passwordResetRepo.MarkUsed(resetToken.ID, tx)
+	if err != nil {
+		u.log.Errorf("passwordResetRepo.MarkUsed: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !used {
+		return pkg.NewResponse(http.StatusBadRequest, "invalid or expired reset token", nil, nil)
+	}
+
+	if err := u.userRepo.UpdatePassword(resetToken.UserId, hashedPassword, tx); err != nil {
+		u.log.Errorf("userRepo.UpdatePassword: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := u.authRepo.RevokeRefreshTokensByUserId(resetToken.UserId, tx); err != nil {
+		u.log.Errorf("authRepo.RevokeRefreshTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	revokeAccessTokens(u.revocationStore, tokens, u.log)
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventPasswordReset, UserId: auditUser(resetToken.UserId)}, client)
+
+	return pkg.NewResponse(http.StatusOK, "password has been reset", nil, nil)
+}
+
+// Change updates the password of a signed in user, every session but the one that issued
+// accessTokenID is revoked
+func (u *passwordUsecase) Change(userID uint, accessTokenID string, props *entity.ChangePasswordRequest, client entity.ClientInfo) (resp pkg.Response) {
+	db := database.Get()
+
+	user, err := u.userRepo.GetById(userID, db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	} else if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// the current password is guessed against the same counters as a login, a stolen access
+	// token alone can't be used to find it
+	usernameKey, ipKey := usernameLoginKey(user.Username), ipLoginKey(client.IPAddress)
+	if wait := u.loginGuard.retryAfter(usernameKey, ipKey); wait > 0 {
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(user.ID), Reason: entity.AuditReasonLocked}, client)
+		return tooManyLoginAttempts(wait)
+	}
+
+	if !pkg.CheckPasswordHash(props.CurrentPassword, user.Password) {
+		u.loginGuard.fail(usernameKey, ipKey)
+		u.audit.Record(entity.AuditEvent{Event: entity.AuditEventLoginFailed, UserId: auditUser(user.ID), Reason: entity.AuditReasonInvalidPassword}, client)
+		return pkg.NewResponse(http.StatusBadRequest, "current password is incorrect", nil, nil)
+	}
+
+	u.loginGuard.succeed(usernameKey)
+
+	if props.NewPassword == props.CurrentPassword {
+		return pkg.NewResponse(http.StatusBadRequest, "new password must be different from the current password", nil, nil)
+	}
+
+	if resp, ok := checkPasswordPolicy(u.passwordPolicy, "new_password", props.NewPassword, user.Username, user.Email); !ok {
+		return resp
+	}
+
+	hashedPassword, err := pkg.HashPassword(props.NewPassword)
+	if err != nil {
+		u.log.Errorf("pkg.HashPassword: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	sessions, err := u.authRepo.GetActiveRefreshTokensByUserId(userID, db)
+	if err != nil {
+		u.log.Errorf("authRepo.GetActiveRefreshTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// read before revoking so every other session still holding a live access token is covered
+	liveTokens, err := u.authRepo.GetLiveAccessTokensByUserId(userID, db)
+	if err != nil {
+		u.log.Errorf("authRepo.GetLiveAccessTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	// the access token may predate the last refresh of its session, so the family is looked
+	// up among the live access tokens rather than the active refresh tokens
+	var currentFamilyID string
+	for _, token := range liveTokens {
+		if token.AccessTokenId == accessTokenID {
+			currentFamilyID = token.FamilyId
+			break
+		}
+	}
+
+	// a session that was signed out meanwhile would be revoked with the others, the password
+	// is left as it is instead
+	if !hasSessionInFamily(sessions, currentFamilyID) {
+		return pkg.NewResponse(http.StatusUnauthorized, pkg.ErrNotAuthorized.Error(), nil, nil)
+	}
+
+	otherTokens := make([]entity.RefreshToken, 0, len(liveTokens))
+	for _, token := range liveTokens {
+		if token.FamilyId != currentFamilyID {
+			otherTokens = append(otherTokens, token)
+		}
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	if err := u.userRepo.UpdatePassword(userID, hashedPassword, tx); err != nil {
+		u.log.Errorf("userRepo.UpdatePassword: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := u.authRepo.RevokeRefreshTokensByUserIdExceptFamily(userID, currentFamilyID, tx); err != nil {
+		u.log.Errorf("authRepo.RevokeRefreshTokensByUserIdExceptFamily: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	revokeAccessTokens(u.revocationStore, otherTokens, u.log)
+
+	u.audit.Record(entity.AuditEvent{Event: entity.AuditEventPasswordChanged, UserId: auditUser(userID)}, client)
+
+	return pkg.NewResponse(http.StatusOK, "password has been changed", nil, nil)
+}
+
+// hasSessionInFamily reports whether familyID names one of the active sessions
+func hasSessionInFamily(sessions []entity.RefreshToken, familyID string) bool {
+	if familyID == "" {
+		return false
+	}
+
+	for _, session := range sessions {
+		if session.FamilyId == familyID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,196 @@
+package usecase_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func newPasswordUsecase(t *testing.T) (usecase.PasswordUsecase, *authTestSuite) {
+	t.Helper()
+
+	s := newAuthTestSuite(t)
+	config.Set("PASSWORD_RESET_EXP_MINUTE", 30)
+	config.Set("APP_URL", "https://app.example.com")
+
+	return usecase.NewPasswordUsecase(s.userRepo, s.authRepo, newFakePasswordResetRepo(), s.mailer, s.revocationStore, s.attemptStore, s.audit, s.passwordPolicy, s.background), s
+}
+
+func TestForgotPasswordDoesNotRevealUnknownEmail(t *testing.T) {
+	uc, s := newPasswordUsecase(t)
+
+	resp := uc.Forgot(&entity.ForgotPasswordRequest{Email: "nobody@example.com"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected unknown email to look successful, got %d", resp.Code)
+	}
+	s.waitBackground(t)
+
+	if got := len(s.mailer.Messages()); got != 0 {
+		t.Fatalf("expected no email, got %d", got)
+	}
+
+	event, ok := s.audit.last(entity.AuditEventPasswordResetRequested)
+	if !ok || event.UserId != nil || event.Username != "nobody@example.com" || event.Reason != entity.AuditReasonUnknownUser {
+		t.Fatalf("expected the request for an unknown email to be recorded, got %+v", event)
+	}
+}
+
+func TestResetPasswordRevokesEverySession(t *testing.T) {
+	uc, s := newPasswordUsecase(t)
+
+	s.login(t)
+	s.login(t)
+
+	s.expectTx()
+	uc.Forgot(&entity.ForgotPasswordRequest{Email: "alice@example.com"}, entity.ClientInfo{})
+	s.waitBackground(t)
+
+	if event, ok := s.audit.last(entity.AuditEventPasswordResetRequested); !ok || event.UserId == nil || *event.UserId != 1 {
+		t.Fatalf("expected the reset request to be recorded for the user, got %+v", event)
+	}
+
+	msg, ok := s.mailer.Last("alice@example.com")
+	if !ok {
+		t.Fatal("expected a reset email")
+	}
+	_, rest, _ := strings.Cut(msg.Body, "/reset-password?token=")
+	token := strings.Fields(rest)[0]
+
+	// a refused password leaves the token usable
+	resp := uc.Reset(&entity.ResetPasswordRequest{Token: token, Password: "alice-2024"}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected weak password to be refused, got %d", resp.Code)
+	}
+
+	s.expectTx()
+	resp = uc.Reset(&entity.ResetPasswordRequest{Token: token, Password: "N3w-Passw0rd"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected reset to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if !pkg.CheckPasswordHash("N3w-Passw0rd", s.userRepo.users[1].Password) {
+		t.Error("password should be updated")
+	}
+
+	for _, token := range s.authRepo.tokens {
+		if token.RevokedAt == nil {
+			t.Errorf("refresh token %d should be revoked", token.ID)
+		}
+		if revoked, _ := s.revocationStore.IsRevoked(token.AccessTokenId); !revoked {
+			t.Errorf("access token of refresh token %d should be revoked", token.ID)
+		}
+	}
+
+	resp = uc.Reset(&entity.ResetPasswordRequest{Token: token, Password: "An0ther-Passw0rd"}, entity.ClientInfo{})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected used token to be rejected, got %d", resp.Code)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangePasswordKeepsCurrentSession(t *testing.T) {
+	uc, s := newPasswordUsecase(t)
+
+	s.login(t)
+	s.login(t)
+	current := s.authRepo.tokens[1]
+
+	resp := uc.Change(1, current.AccessTokenId, &entity.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "N3w-Passw0rd"}, entity.ClientInfo{})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected wrong current password to be rejected, got %d", resp.Code)
+	}
+
+	s.expectTx()
+	resp = uc.Change(1, current.AccessTokenId, &entity.ChangePasswordRequest{CurrentPassword: "secret", NewPassword: "N3w-Passw0rd"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected change to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if !pkg.CheckPasswordHash("N3w-Passw0rd", s.userRepo.users[1].Password) {
+		t.Error("password should be updated")
+	}
+
+	for _, token := range s.authRepo.tokens {
+		revoked, _ := s.revocationStore.IsRevoked(token.AccessTokenId)
+		if token.FamilyId == current.FamilyId {
+			if token.RevokedAt != nil || revoked {
+				t.Errorf("current session token %d should stay valid", token.ID)
+			}
+			continue
+		}
+		if token.RevokedAt == nil || !revoked {
+			t.Errorf("other session token %d should be revoked", token.ID)
+		}
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangePasswordRefusesUnknownSession(t *testing.T) {
+	uc, s := newPasswordUsecase(t)
+
+	s.login(t)
+	password := s.userRepo.users[1].Password
+
+	resp := uc.Change(1, "signed-out-token", &entity.ChangePasswordRequest{CurrentPassword: "secret", NewPassword: "N3w-Passw0rd"}, entity.ClientInfo{})
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a session that is no longer active to be refused, got %d", resp.Code)
+	}
+
+	if s.userRepo.users[1].Password != password {
+		t.Error("password should be left as it is")
+	}
+
+	for _, token := range s.authRepo.tokens {
+		if token.RevokedAt != nil {
+			t.Errorf("refresh token %d should stay valid", token.ID)
+		}
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangePasswordGuessesCountAsFailedLogins(t *testing.T) {
+	uc, s := newPasswordUsecase(t)
+	config.Set("LOGIN_MAX_ATTEMPTS_PER_USERNAME", 3)
+	config.Set("LOGIN_ATTEMPT_WINDOW_MINUTE", 15)
+	config.Set("LOGIN_LOCKOUT_BASE_SECOND", 30)
+	t.Cleanup(func() { config.Set("LOGIN_MAX_ATTEMPTS_PER_USERNAME", 0) })
+
+	s.login(t)
+	current := s.authRepo.tokens[0]
+
+	for range 3 {
+		resp := uc.Change(1, current.AccessTokenId, &entity.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "N3w-Passw0rd"}, entity.ClientInfo{})
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected wrong current password to be rejected, got %d", resp.Code)
+		}
+	}
+
+	failed, ok := s.audit.last(entity.AuditEventLoginFailed)
+	if !ok || failed.Reason != entity.AuditReasonInvalidPassword || failed.UserId == nil || *failed.UserId != 1 {
+		t.Fatalf("expected the wrong current password to be audited, got %+v", failed)
+	}
+
+	// the right password is refused once the account is locked, for a login as well
+	resp := uc.Change(1, current.AccessTokenId, &entity.ChangePasswordRequest{CurrentPassword: "secret", NewPassword: "N3w-Passw0rd"}, entity.ClientInfo{})
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a locked account to be refused, got %d", resp.Code)
+	}
+
+	if resp := s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{}); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected login of the locked account to be refused, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,571 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+type RoleUsecase interface {
+	ListRoles() (resp pkg.Response)
+	CreateRole(props *entity.CreateRoleRequest) (resp pkg.Response)
+	DeleteRole(roleID uint) (resp pkg.Response)
+	SetRolePermissions(roleID uint, props *entity.SetRolePermissionsRequest) (resp pkg.Response)
+	ListPermissions() (resp pkg.Response)
+	ListUserRoles(userID uint) (resp pkg.Response)
+	AssignRole(actorID, userID uint, props *entity.AssignRoleRequest) (resp pkg.Response)
+	RemoveRole(actorID, userID uint, roleName string) (resp pkg.Response)
+	Seed(adminUsername string) error
+}
+
+type roleUsecase struct {
+	userRepo        repository.UserRepository
+	roleRepo        repository.RoleRepository
+	authRepo        repository.AuthRepository
+	revocationStore store.RevocationStore
+	log             *logrus.Logger
+}
+
+func NewRoleUsecase(userRepo repository.UserRepository, roleRepo repository.RoleRepository, authRepo repository.AuthRepository, revocationStore store.RevocationStore) RoleUsecase {
+	log := logger.Get()
+
+	return &roleUsecase{
+		userRepo,
+		roleRepo,
+		authRepo,
+		revocationStore,
+		log,
+	}
+}
+
+// userAccess returns the names of the roles and permissions of the user
+func userAccess(roleRepo repository.RoleRepository, userID uint, db *sqlx.DB) (roles, permissions []string, err error) {
+	userRoles, err := roleRepo.GetUserRoles(userID, db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("roleRepo.GetUserRoles: %w", err)
+	}
+
+	for _, role := range userRoles {
+		roles = append(roles, role.Name)
+	}
+
+	permissions, err = roleRepo.GetUserPermissions(userID, db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("roleRepo.GetUserPermissions: %w", err)
+	}
+
+	return roles, permissions, nil
+}
+
+func (u *roleUsecase) ListRoles() (resp pkg.Response) {
+	db := database.Get()
+
+	roles, err := u.roleRepo.GetAll(db)
+	if err != nil {
+		u.log.Errorf("roleRepo.GetAll: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	result, err := u.roleResponses(roles, db)
+	if err != nil {
+		u.log.Errorf("u.roleResponses: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", result, nil)
+}
+
+func (u *roleUsecase) CreateRole(props *entity.CreateRoleRequest) (resp pkg.Response) {
+	db := database.Get()
+
+	permissions, resp, ok := u.resolvePermissions(props.Permissions, db)
+	if !ok {
+		return resp
+	}
+
+	role := entity.Role{
+		Name:        pkg.NormalizeIdentifier(props.Name),
+		Description: props.Description,
+		CreatedAt:   time.Now(),
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	role.ID, err = u.roleRepo.Insert(role, tx)
+	if errors.Is(err, pkg.ErrRoleExists) {
+		return pkg.NewResponse(http.StatusConflict, err.Error(), nil, nil)
+	} else if err != nil {
+		u.log.Errorf("roleRepo.Insert: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := u.roleRepo.SetRolePermissions(role.ID, permissionIDs(permissions), tx); err != nil {
+		u.log.Errorf("roleRepo.SetRolePermissions: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return pkg.NewResponse(http.StatusCreated, "role created", roleResponse(role, permissions), nil)
+}
+
+// DeleteRole removes the role from every user, the admin role is kept so the app stays manageable.
+// The live access tokens of the holders are revoked since they carry the role in their claims
+func (u *roleUsecase) DeleteRole(roleID uint) (resp pkg.Response) {
+	db := database.Get()
+
+	role, resp, ok := u.getRole(roleID)
+	if !ok {
+		return resp
+	}
+
+	if role.Name == entity.RoleAdmin {
+		return pkg.NewResponse(http.StatusConflict, "the admin role can't be deleted", nil, nil)
+	}
+
+	tokens, err := u.roleHolderTokens(role.ID, db)
+	if err != nil {
+		u.log.Errorf("u.roleHolderTokens: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	if _, err := u.roleRepo.Delete(role.ID, tx); err != nil {
+		u.log.Errorf("roleRepo.Delete: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	revokeAccessTokens(u.revocationStore, tokens, u.log)
+
+	return pkg.NewResponse(http.StatusOK, "role deleted", nil, nil)
+}
+
+// SetRolePermissions replaces the permissions of a role, the admin role holds every default
+// permission and is only changed by the seed. The live access tokens of the holders are revoked
+// so the new permissions apply from their next refresh
+func (u *roleUsecase) SetRolePermissions(roleID uint, props *entity.SetRolePermissionsRequest) (resp pkg.Response) {
+	db := database.Get()
+
+	role, resp, ok := u.getRole(roleID)
+	if !ok {
+		return resp
+	}
+
+	if role.Name == entity.RoleAdmin {
+		return pkg.NewResponse(http.StatusConflict, "the permissions of the admin role can't be changed", nil, nil)
+	}
+
+	permissions, resp, ok := u.resolvePermissions(props.Permissions, db)
+	if !ok {
+		return resp
+	}
+
+	tokens, err := u.roleHolderTokens(role.ID, db)
+	if err != nil {
+		u.log.Errorf("u.roleHolderTokens: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	if err := u.roleRepo.SetRolePermissions(role.ID, permissionIDs(permissions), tx); err != nil {
+		u.log.Errorf("roleRepo.SetRolePermissions: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	revokeAccessTokens(u.revocationStore, tokens, u.log)
+
+	return pkg.NewResponse(http.StatusOK, "success", roleResponse(role, permissions), nil)
+}
+
+func (u *roleUsecase) ListPermissions() (resp pkg.Response) {
+	permissions, err := u.roleRepo.GetPermissions(database.Get())
+	if err != nil {
+		u.log.Errorf("roleRepo.GetPermissions: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if permissions == nil {
+		permissions = []entity.Permission{}
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", permissions, nil)
+}
+
+func (u *roleUsecase) ListUserRoles(userID uint) (resp pkg.Response) {
+	db := database.Get()
+
+	if _, resp, ok := u.getUser(userID); !ok {
+		return resp
+	}
+
+	roles, err := u.roleRepo.GetUserRoles(userID, db)
+	if err != nil {
+		u.log.Errorf("roleRepo.GetUserRoles: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	result, err := u.roleResponses(roles, db)
+	if err != nil {
+		u.log.Errorf("u.roleResponses: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", result, nil)
+}
+
+// AssignRole grants a role, the user holds it in access tokens issued from the next refresh. The
+// actor can only grant a role whose permissions they hold, and only an admin grants the admin role
+func (u *roleUsecase) AssignRole(actorID, userID uint, props *entity.AssignRoleRequest) (resp pkg.Response) {
+	db := database.Get()
+
+	if _, resp, ok := u.getUser(userID); !ok {
+		return resp
+	}
+
+	role, err := u.roleRepo.GetByName(pkg.NormalizeIdentifier(props.Role), db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusNotFound, "role not found", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("roleRepo.GetByName: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if resp, ok := u.canGrant(actorID, role, db); !ok {
+		return resp
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	assigned, err := u.roleRepo.AssignUserRole(userID, role.ID, tx)
+	if err != nil {
+		u.log.Errorf("roleRepo.AssignUserRole: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !assigned {
+		return pkg.NewResponse(http.StatusOK, "user already has the role", nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	u.log.WithFields(logrus.Fields{
+		"event":    "role_assigned",
+		"actor_id": actorID,
+		"user_id":  userID,
+		"role":     role.Name,
+	}).Info("role assigned")
+
+	return pkg.NewResponse(http.StatusOK, "role assigned", nil, nil)
+}
+
+// RemoveRole takes a role away, the live access tokens of the user are revoked so the role
+// stops working right away instead of when they expire
+func (u *roleUsecase) RemoveRole(actorID, userID uint, roleName string) (resp pkg.Response) {
+	db := database.Get()
+	roleName = pkg.NormalizeIdentifier(roleName)
+
+	// an admin locking themselves out could leave nobody to manage the app
+	if actorID == userID && roleName == entity.RoleAdmin {
+		return pkg.NewResponse(http.StatusConflict, "you can't remove your own admin role", nil, nil)
+	}
+
+	role, err := u.roleRepo.GetByName(roleName, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusNotFound, "role not found", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("roleRepo.GetByName: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tokens, err := u.authRepo.GetLiveAccessTokensByUserId(userID, db)
+	if err != nil {
+		u.log.Errorf("authRepo.GetLiveAccessTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	removed, err := u.roleRepo.RemoveUserRole(userID, role.ID, tx)
+	if err != nil {
+		u.log.Errorf("roleRepo.RemoveUserRole: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !removed {
+		return pkg.NewResponse(http.StatusNotFound, "user doesn't have the role", nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	revokeAccessTokens(u.revocationStore, tokens, u.log)
+
+	u.log.WithFields(logrus.Fields{
+		"event":    "role_removed",
+		"actor_id": actorID,
+		"user_id":  userID,
+		"role":     role.Name,
+	}).Info("role removed")
+
+	return pkg.NewResponse(http.StatusOK, "role removed", nil, nil)
+}
+
+// Seed creates the default permissions and the admin role holding all of them, it is safe
+// to run again. adminUsername, when set, is assigned the admin role
+func (u *roleUsecase) Seed(adminUsername string) error {
+	db := database.Get()
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := u.roleRepo.InsertPermissions(entity.DefaultPermissions, tx); err != nil {
+		return fmt.Errorf("roleRepo.InsertPermissions: %w", err)
+	}
+
+	admin := entity.Role{Name: entity.RoleAdmin, Description: "Manages users, roles and the app", CreatedAt: time.Now()}
+	if _, err := u.roleRepo.Insert(admin, tx); err != nil && !errors.Is(err, pkg.ErrRoleExists) {
+		return fmt.Errorf("roleRepo.Insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	if admin, err = u.roleRepo.GetByName(entity.RoleAdmin, db); err != nil {
+		return fmt.Errorf("roleRepo.GetByName: %w", err)
+	}
+
+	permissions, err := u.roleRepo.GetPermissionsByNames(entity.DefaultPermissions, db)
+	if err != nil {
+		return fmt.Errorf("roleRepo.GetPermissionsByNames: %w", err)
+	}
+
+	var user entity.User
+	if adminUsername != "" {
+		if user, err = u.userRepo.GetByUsername(pkg.NormalizeIdentifier(adminUsername), db); err != nil {
+			return fmt.Errorf("userRepo.GetByUsername: %w", err)
+		}
+	}
+
+	tx, err = db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := u.roleRepo.SetRolePermissions(admin.ID, permissionIDs(permissions), tx); err != nil {
+		return fmt.Errorf("roleRepo.SetRolePermissions: %w", err)
+	}
+
+	if user.ID != 0 {
+		if _, err := u.roleRepo.AssignUserRole(user.ID, admin.ID, tx); err != nil {
+			return fmt.Errorf("roleRepo.AssignUserRole: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (u *roleUsecase) getRole(roleID uint) (role entity.Role, resp pkg.Response, ok bool) {
+	role, err := u.roleRepo.GetById(roleID, database.Get())
+	if errors.Is(err, sql.ErrNoRows) {
+		return role, pkg.NewResponse(http.StatusNotFound, "role not found", nil, nil), false
+	} else if err != nil {
+		u.log.Errorf("roleRepo.GetById: %s", err.Error())
+		return role, pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil), false
+	}
+
+	return role, resp, true
+}
+
+// canGrant keeps users:write from escalating, the actor must already hold every permission of
+// the role
+func (u *roleUsecase) canGrant(actorID uint, role entity.Role, db *sqlx.DB) (resp pkg.Response, ok bool) {
+	actorRoles, actorPermissions, err := userAccess(u.roleRepo, actorID, db)
+	if err != nil {
+		u.log.Errorf("userAccess: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil), false
+	}
+
+	if role.Name == entity.RoleAdmin && !slices.Contains(actorRoles, entity.RoleAdmin) {
+		return pkg.NewResponse(http.StatusForbidden, "only an admin can grant the admin role", nil, nil), false
+	}
+
+	rolePermissions, err := u.roleRepo.GetRolePermissions([]uint{role.ID}, db)
+	if err != nil {
+		u.log.Errorf("roleRepo.GetRolePermissions: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil), false
+	}
+
+	for _, permission := range rolePermissions {
+		if !slices.Contains(actorPermissions, permission.Name) {
+			return pkg.NewResponse(http.StatusForbidden, "you can't grant a role with permissions you don't hold", nil, nil), false
+		}
+	}
+
+	return resp, true
+}
+
+// roleHolderTokens returns the live access tokens of every user holding the role
+func (u *roleUsecase) roleHolderTokens(roleID uint, db *sqlx.DB) (tokens []entity.RefreshToken, err error) {
+	userIDs, err := u.roleRepo.GetRoleUserIds(roleID, db)
+	if err != nil {
+		return nil, fmt.Errorf("roleRepo.GetRoleUserIds: %w", err)
+	}
+
+	for _, userID := range userIDs {
+		userTokens, err := u.authRepo.GetLiveAccessTokensByUserId(userID, db)
+		if err != nil {
+			return nil, fmt.Errorf("authRepo.GetLiveAccessTokensByUserId: %w", err)
+		}
+		tokens = append(tokens, userTokens...)
+	}
+
+	return tokens, nil
+}
+
+func (u *roleUsecase) getUser(userID uint) (user entity.User, resp pkg.Response, ok bool) {
+	user, err := u.userRepo.GetById(userID, database.Get())
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, pkg.NewResponse(http.StatusNotFound, "user not found", nil, nil), false
+	} else if err != nil {
+		u.log.Errorf("userRepo.GetById: %s", err.Error())
+		return user, pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil), false
+	}
+
+	return user, resp, true
+}
+
+// resolvePermissions looks up the permissions by name, unknown names are a validation error
+func (u *roleUsecase) resolvePermissions(names []string, db *sqlx.DB) (permissions []entity.Permission, resp pkg.Response, ok bool) {
+	permissions, err := u.roleRepo.GetPermissionsByNames(names, db)
+	if err != nil {
+		u.log.Errorf("roleRepo.GetPermissionsByNames: %s", err.Error())
+		return nil, pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil), false
+	}
+
+	var unknown []string
+	for _, name := range names {
+		if !slices.ContainsFunc(permissions, func(p entity.Permission) bool { return p.Name == name }) {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		data := map[string]any{
+			"unknown_permissions": unknown,
+		}
+		return nil, pkg.NewResponse(http.StatusUnprocessableEntity, "unknown permissions", data, nil), false
+	}
+
+	return permissions, resp, true
+}
+
+func (u *roleUsecase) roleResponses(roles []entity.Role, db *sqlx.DB) ([]entity.RoleResponse, error) {
+	roleIDs := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	rolePermissions, err := u.roleRepo.GetRolePermissions(roleIDs, db)
+	if err != nil {
+		return nil, fmt.Errorf("roleRepo.GetRolePermissions: %w", err)
+	}
+
+	result := make([]entity.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		response := roleResponse(role, nil)
+		for _, permission := range rolePermissions {
+			if permission.RoleId == role.ID {
+				response.Permissions = append(response.Permissions, permission.Name)
+			}
+		}
+		result = append(result, response)
+	}
+
+	return result, nil
+}
+
+func roleResponse(role entity.Role, permissions []entity.Permission) entity.RoleResponse {
+	response := entity.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: []string{},
+	}
+
+	for _, permission := range permissions {
+		response.Permissions = append(response.Permissions, permission.Name)
+	}
+
+	return response
+}
+
+func permissionIDs(permissions []entity.Permission) []uint {
+	ids := make([]uint, 0, len(permissions))
+	for _, permission := range permissions {
+		ids = append(ids, permission.ID)
+	}
+
+	return ids
+}
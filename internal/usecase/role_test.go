@@ -0,0 +1,355 @@
+package usecase_test
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+type fakeRoleRepo struct {
+	roles           []entity.Role
+	permissions     []entity.Permission
+	rolePermissions map[uint][]uint
+	userRoles       map[uint][]uint
+}
+
+func newFakeRoleRepo() *fakeRoleRepo {
+	return &fakeRoleRepo{rolePermissions: map[uint][]uint{}, userRoles: map[uint][]uint{}}
+}
+
+func (r *fakeRoleRepo) GetAll(db *sqlx.DB) ([]entity.Role, error) {
+	return slices.Clone(r.roles), nil
+}
+
+func (r *fakeRoleRepo) GetById(id uint, db *sqlx.DB) (entity.Role, error) {
+	for _, role := range r.roles {
+		if role.ID == id {
+			return role, nil
+		}
+	}
+	return entity.Role{}, sql.ErrNoRows
+}
+
+func (r *fakeRoleRepo) GetByName(name string, db *sqlx.DB) (entity.Role, error) {
+	for _, role := range r.roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+	return entity.Role{}, sql.ErrNoRows
+}
+
+func (r *fakeRoleRepo) Insert(data entity.Role, tx *sqlx.Tx) (uint, error) {
+	if _, err := r.GetByName(data.Name, nil); err == nil {
+		return 0, pkg.ErrRoleExists
+	}
+	data.ID = uint(len(r.roles) + 1)
+	r.roles = append(r.roles, data)
+	return data.ID, nil
+}
+
+func (r *fakeRoleRepo) Delete(id uint, tx *sqlx.Tx) (bool, error) {
+	for i, role := range r.roles {
+		if role.ID == id {
+			r.roles = append(r.roles[:i], r.roles[i+1:]...)
+			delete(r.rolePermissions, id)
+			for userID, roleIDs := range r.userRoles {
+				r.userRoles[userID] = slices.DeleteFunc(roleIDs, func(roleID uint) bool { return roleID == id })
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRoleRepo) GetPermissions(db *sqlx.DB) ([]entity.Permission, error) {
+	return slices.Clone(r.permissions), nil
+}
+
+func (r *fakeRoleRepo) GetPermissionsByNames(names []string, db *sqlx.DB) (result []entity.Permission, err error) {
+	for _, permission := range r.permissions {
+		if slices.Contains(names, permission.Name) {
+			result = append(result, permission)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRoleRepo) InsertPermissions(names []string, tx *sqlx.Tx) error {
+	for _, name := range names {
+		if !slices.ContainsFunc(r.permissions, func(p entity.Permission) bool { return p.Name == name }) {
+			r.permissions = append(r.permissions, entity.Permission{ID: uint(len(r.permissions) + 1), Name: name})
+		}
+	}
+	return nil
+}
+
+func (r *fakeRoleRepo) GetRolePermissions(roleIds []uint, db *sqlx.DB) (result []entity.RolePermission, err error) {
+	for _, roleID := range roleIds {
+		for _, permission := range r.permissions {
+			if slices.Contains(r.rolePermissions[roleID], permission.ID) {
+				result = append(result, entity.RolePermission{RoleId: roleID, Name: permission.Name})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRoleRepo) SetRolePermissions(roleId uint, permissionIds []uint, tx *sqlx.Tx) error {
+	r.rolePermissions[roleId] = slices.Clone(permissionIds)
+	return nil
+}
+
+func (r *fakeRoleRepo) GetUserRoles(userId uint, db *sqlx.DB) (result []entity.Role, err error) {
+	for _, role := range r.roles {
+		if slices.Contains(r.userRoles[userId], role.ID) {
+			result = append(result, role)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRoleRepo) GetUserPermissions(userId uint, db *sqlx.DB) (result []string, err error) {
+	for _, permission := range r.permissions {
+		for _, roleID := range r.userRoles[userId] {
+			if slices.Contains(r.rolePermissions[roleID], permission.ID) {
+				result = append(result, permission.Name)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRoleRepo) GetRoleUserIds(roleId uint, db *sqlx.DB) (result []uint, err error) {
+	for userID, roleIDs := range r.userRoles {
+		if slices.Contains(roleIDs, roleId) {
+			result = append(result, userID)
+		}
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+func (r *fakeRoleRepo) AssignUserRole(userId, roleId uint, tx *sqlx.Tx) (bool, error) {
+	if slices.Contains(r.userRoles[userId], roleId) {
+		return false, nil
+	}
+	r.userRoles[userId] = append(r.userRoles[userId], roleId)
+	return true, nil
+}
+
+func (r *fakeRoleRepo) RemoveUserRole(userId, roleId uint, tx *sqlx.Tx) (bool, error) {
+	if !slices.Contains(r.userRoles[userId], roleId) {
+		return false, nil
+	}
+	r.userRoles[userId] = slices.DeleteFunc(r.userRoles[userId], func(id uint) bool { return id == roleId })
+	return true, nil
+}
+
+func newRoleUsecase(s *authTestSuite) usecase.RoleUsecase {
+	return usecase.NewRoleUsecase(s.userRepo, s.roleRepo, s.authRepo, s.revocationStore)
+}
+
+// seeds the roles with bob as the admin, alice has no role
+func (s *authTestSuite) seedRoles(t *testing.T) usecase.RoleUsecase {
+	t.Helper()
+
+	s.userRepo.users[2] = entity.User{ID: 2, Name: "Bob", Username: "bob", Email: "bob@example.com"}
+
+	roleUC := newRoleUsecase(s)
+	s.expectTx()
+	s.expectTx()
+	if err := roleUC.Seed("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	return roleUC
+}
+
+func TestRoleSeedIsRepeatable(t *testing.T) {
+	s := newAuthTestSuite(t)
+	roleUC := s.seedRoles(t)
+
+	s.expectTx()
+	s.expectTx()
+	if err := roleUC.Seed("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.roleRepo.roles) != 1 || len(s.roleRepo.permissions) != len(entity.DefaultPermissions) {
+		t.Fatalf("expected a single admin role and the default permissions, got %d roles and %d permissions", len(s.roleRepo.roles), len(s.roleRepo.permissions))
+	}
+
+	permissions, _ := s.roleRepo.GetUserPermissions(2, nil)
+	if !slices.Equal(permissions, entity.DefaultPermissions) {
+		t.Fatalf("expected bob to hold every default permission, got %v", permissions)
+	}
+}
+
+func TestLoginCarriesRoleClaims(t *testing.T) {
+	s := newAuthTestSuite(t)
+	roleUC := s.seedRoles(t)
+
+	s.expectTx()
+	resp := roleUC.CreateRole(&entity.CreateRoleRequest{Name: "Support", Permissions: []string{entity.PermissionUsersRead}})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected create role to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	s.expectTx()
+	if resp := roleUC.AssignRole(2, 1, &entity.AssignRoleRequest{Role: "support"}); resp.Code != http.StatusOK {
+		t.Fatalf("expected assign to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	s.expectTx()
+	resp = s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected login to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	claims, err := newTestJWT(t).VerifyAccessToken(resp.Data.(map[string]any)["access_token"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(claims.Roles, []string{"support"}) || !slices.Equal(claims.Permissions, []string{entity.PermissionUsersRead}) {
+		t.Fatalf("expected support role claims, got %v %v", claims.Roles, claims.Permissions)
+	}
+}
+
+func TestRemoveRoleRevokesLiveAccessTokens(t *testing.T) {
+	s := newAuthTestSuite(t)
+	roleUC := s.seedRoles(t)
+
+	s.expectTx()
+	roleUC.AssignRole(2, 1, &entity.AssignRoleRequest{Role: entity.RoleAdmin})
+	s.login(t)
+
+	s.expectTx()
+	if resp := roleUC.RemoveRole(2, 1, entity.RoleAdmin); resp.Code != http.StatusOK {
+		t.Fatalf("expected remove to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	revoked, _ := s.revocationStore.IsRevoked(s.authRepo.tokens[0].AccessTokenId)
+	if !revoked {
+		t.Fatal("expected the live access token to be revoked")
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectRollback()
+	if resp := roleUC.RemoveRole(2, 1, entity.RoleAdmin); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected second removal to be not found, got %d", resp.Code)
+	}
+}
+
+func TestRoleChangesRevokeHolderAccessTokens(t *testing.T) {
+	s := newAuthTestSuite(t)
+	roleUC := s.seedRoles(t)
+
+	s.expectTx()
+	roleUC.CreateRole(&entity.CreateRoleRequest{Name: "support", Permissions: []string{entity.PermissionUsersRead}})
+	support, _ := s.roleRepo.GetByName("support", nil)
+
+	s.expectTx()
+	roleUC.AssignRole(2, 1, &entity.AssignRoleRequest{Role: "support"})
+
+	tests := []struct {
+		name string
+		resp func() pkg.Response
+	}{
+		{"set role permissions", func() pkg.Response {
+			return roleUC.SetRolePermissions(support.ID, &entity.SetRolePermissionsRequest{Permissions: []string{entity.PermissionAuditRead}})
+		}},
+		{"delete role", func() pkg.Response { return roleUC.DeleteRole(support.ID) }},
+	}
+
+	for _, tt := range tests {
+		s.login(t)
+		token := s.authRepo.tokens[len(s.authRepo.tokens)-1]
+
+		s.expectTx()
+		if resp := tt.resp(); resp.Code != http.StatusOK {
+			t.Fatalf("%s: expected success, got %d: %s", tt.name, resp.Code, resp.Message)
+		}
+
+		if revoked, _ := s.revocationStore.IsRevoked(token.AccessTokenId); !revoked {
+			t.Fatalf("%s: expected the live access token of the holder to be revoked", tt.name)
+		}
+	}
+}
+
+func TestAssignRoleRefusesEscalation(t *testing.T) {
+	s := newAuthTestSuite(t)
+	roleUC := s.seedRoles(t)
+
+	s.expectTx()
+	roleUC.CreateRole(&entity.CreateRoleRequest{Name: "support", Permissions: []string{entity.PermissionUsersRead, entity.PermissionUsersWrite}})
+	s.expectTx()
+	roleUC.CreateRole(&entity.CreateRoleRequest{Name: "auditor", Permissions: []string{entity.PermissionAuditRead}})
+	s.expectTx()
+	roleUC.CreateRole(&entity.CreateRoleRequest{Name: "viewer", Permissions: []string{entity.PermissionUsersRead}})
+
+	s.expectTx()
+	if resp := roleUC.AssignRole(2, 1, &entity.AssignRoleRequest{Role: "support"}); resp.Code != http.StatusOK {
+		t.Fatalf("expected the admin to assign support, got %d: %s", resp.Code, resp.Message)
+	}
+
+	// alice holds users:write through support
+	for _, role := range []string{entity.RoleAdmin, "auditor"} {
+		if resp := roleUC.AssignRole(1, 1, &entity.AssignRoleRequest{Role: role}); resp.Code != http.StatusForbidden {
+			t.Fatalf("expected granting %s to be refused, got %d: %s", role, resp.Code, resp.Message)
+		}
+	}
+
+	roles, _ := s.roleRepo.GetUserRoles(1, nil)
+	if len(roles) != 1 || roles[0].Name != "support" {
+		t.Fatalf("expected alice to only hold support, got %+v", roles)
+	}
+
+	s.expectTx()
+	if resp := roleUC.AssignRole(1, 2, &entity.AssignRoleRequest{Role: "viewer"}); resp.Code != http.StatusOK {
+		t.Fatalf("expected a role within the permissions of alice to be granted, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoleGuards(t *testing.T) {
+	s := newAuthTestSuite(t)
+	roleUC := s.seedRoles(t)
+	admin, _ := s.roleRepo.GetByName(entity.RoleAdmin, nil)
+
+	tests := []struct {
+		name string
+		resp func() pkg.Response
+		want int
+	}{
+		{"remove own admin role", func() pkg.Response { return roleUC.RemoveRole(2, 2, "Admin") }, http.StatusConflict},
+		{"delete admin role", func() pkg.Response { return roleUC.DeleteRole(admin.ID) }, http.StatusConflict},
+		{"change admin permissions", func() pkg.Response {
+			return roleUC.SetRolePermissions(admin.ID, &entity.SetRolePermissionsRequest{Permissions: []string{}})
+		}, http.StatusConflict},
+		{"unknown permission", func() pkg.Response {
+			return roleUC.CreateRole(&entity.CreateRoleRequest{Name: "ops", Permissions: []string{"servers:reboot"}})
+		}, http.StatusUnprocessableEntity},
+		{"unknown role", func() pkg.Response { return roleUC.AssignRole(2, 1, &entity.AssignRoleRequest{Role: "ops"}) }, http.StatusNotFound},
+		{"unknown user", func() pkg.Response {
+			return roleUC.AssignRole(2, 99, &entity.AssignRoleRequest{Role: entity.RoleAdmin})
+		}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if resp := tt.resp(); resp.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, resp.Code, resp.Message)
+		}
+	}
+}
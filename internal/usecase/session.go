@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/store"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+type SessionUsecase interface {
+	List(userID uint, accessTokenID string) (resp pkg.Response)
+	Revoke(userID, sessionID uint) (resp pkg.Response)
+}
+
+type sessionUsecase struct {
+	authRepo        repository.AuthRepository
+	revocationStore store.RevocationStore
+	log             *logrus.Logger
+}
+
+func NewSessionUsecase(authRepo repository.AuthRepository, revocationStore store.RevocationStore) SessionUsecase {
+	log := logger.Get()
+
+	return &sessionUsecase{
+		authRepo,
+		revocationStore,
+		log,
+	}
+}
+
+// List returns the active sessions of the user, the session that issued accessTokenID is flagged as current
+func (u *sessionUsecase) List(userID uint, accessTokenID string) (resp pkg.Response) {
+	db := database.Get()
+
+	tokens, err := u.authRepo.GetActiveRefreshTokensByUserId(userID, db)
+	if err != nil {
+		u.log.Errorf("authRepo.GetActiveRefreshTokensByUserId: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	sessions := make([]entity.SessionResponse, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, entity.SessionResponse{
+			ID:          token.ID,
+			DeviceLabel: token.DeviceLabel,
+			UserAgent:   token.UserAgent,
+			IPAddress:   token.IPAddress,
+			CreatedAt:   token.CreatedAt,
+			LastUsedAt:  token.LastUsedAt,
+			ExpiredAt:   token.ExpiredAt,
+			Current:     token.AccessTokenId == accessTokenID,
+		})
+	}
+
+	return pkg.NewResponse(http.StatusOK, "success", sessions, nil)
+}
+
+func (u *sessionUsecase) Revoke(userID, sessionID uint) (resp pkg.Response) {
+	db := database.Get()
+
+	session, err := u.authRepo.GetActiveRefreshTokenById(sessionID, userID, db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusNotFound, "session not found", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("authRepo.GetActiveRefreshTokenById: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	err = u.authRepo.RevokeRefreshTokenFamily(session.FamilyId, tx)
+	if err != nil {
+		u.log.Errorf("authRepo.RevokeRefreshTokenFamily: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tokens, err := u.authRepo.GetLiveAccessTokensByFamily(session.FamilyId, db)
+	if err != nil {
+		u.log.Errorf("authRepo.GetLiveAccessTokensByFamily: %s", err.Error())
+		return pkg.NewResponse(http.StatusOK, "success", nil, nil)
+	}
+
+	revokeAccessTokens(u.revocationStore, tokens, u.log)
+
+	return pkg.NewResponse(http.StatusOK, "success", nil, nil)
+}
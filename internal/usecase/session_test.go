@@ -0,0 +1,72 @@
+package usecase_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func TestSessionListFlagsCurrentSession(t *testing.T) {
+	s := newAuthTestSuite(t)
+	uc := usecase.NewSessionUsecase(s.authRepo, s.revocationStore)
+
+	s.login(t)
+	s.login(t)
+	// a session of another user
+	s.authRepo.tokens = append(s.authRepo.tokens, entity.RefreshToken{ID: 3, UserId: 2, FamilyId: "other", ExpiredAt: time.Now().Add(time.Hour)})
+
+	current := s.authRepo.tokens[1]
+	resp := uc.List(1, current.AccessTokenId)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected list to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	sessions := resp.Data.([]entity.SessionResponse)
+	if len(sessions) != 2 {
+		t.Fatalf("expected the 2 sessions of alice, got %+v", sessions)
+	}
+
+	for _, session := range sessions {
+		if session.Current != (session.ID == current.ID) {
+			t.Fatalf("expected only session %d to be current, got %+v", current.ID, sessions)
+		}
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	s := newAuthTestSuite(t)
+	uc := usecase.NewSessionUsecase(s.authRepo, s.revocationStore)
+
+	s.login(t)
+	s.login(t)
+	s.authRepo.tokens = append(s.authRepo.tokens, entity.RefreshToken{ID: 3, UserId: 2, FamilyId: "other", ExpiredAt: time.Now().Add(time.Hour)})
+
+	// the session of another user is not found, so its existence is not revealed
+	if resp := uc.Revoke(1, 3); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected revoking a session of another user to be refused, got %d", resp.Code)
+	}
+	if s.authRepo.tokens[2].RevokedAt != nil {
+		t.Fatal("expected the session of another user to stay active")
+	}
+
+	revoked := s.authRepo.tokens[0]
+	s.expectTx()
+	if resp := uc.Revoke(1, revoked.ID); resp.Code != http.StatusOK {
+		t.Fatalf("expected revoke to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if s.authRepo.tokens[0].RevokedAt == nil || s.authRepo.tokens[1].RevokedAt != nil {
+		t.Fatalf("expected only the revoked session to end, got %+v", s.authRepo.tokens)
+	}
+
+	if ok, _ := s.revocationStore.IsRevoked(revoked.AccessTokenId); !ok {
+		t.Fatal("expected the access token of the revoked session to be revoked")
+	}
+
+	if resp := uc.Revoke(1, revoked.ID); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected a revoked session not to be found again, got %d", resp.Code)
+	}
+}
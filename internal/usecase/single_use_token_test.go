@@ -0,0 +1,141 @@
+package usecase_test
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/jmoiron/sqlx"
+)
+
+// singleUseToken points at the fields every single use token has, secret is the token or
+// the code
+type singleUseToken struct {
+	id        *uint
+	userId    uint
+	secret    string
+	expiredAt time.Time
+	usedAt    **time.Time
+}
+
+// fakeSingleUseTokenRepo keeps the rows of a repository.SingleUseTokenRepository in memory
+type fakeSingleUseTokenRepo[T any] struct {
+	tokens []T
+	fields func(row *T) singleUseToken
+}
+
+func (r *fakeSingleUseTokenRepo[T]) Insert(data T, tx *sqlx.Tx) (uint, error) {
+	id := uint(len(r.tokens) + 1)
+	*r.fields(&data).id = id
+	r.tokens = append(r.tokens, data)
+	return id, nil
+}
+
+// getValid returns the latest unused and unexpired row match accepts
+func (r *fakeSingleUseTokenRepo[T]) getValid(match func(row singleUseToken) bool) (result T, err error) {
+	for i := len(r.tokens) - 1; i >= 0; i-- {
+		if row := r.fields(&r.tokens[i]); match(row) && *row.usedAt == nil && row.expiredAt.After(time.Now()) {
+			return r.tokens[i], nil
+		}
+	}
+	return result, sql.ErrNoRows
+}
+
+func (r *fakeSingleUseTokenRepo[T]) GetValidByToken(token string, db *sqlx.DB) (T, error) {
+	return r.getValid(func(row singleUseToken) bool { return row.secret == token })
+}
+
+func (r *fakeSingleUseTokenRepo[T]) MarkUsed(id uint, tx *sqlx.Tx) (bool, error) {
+	for i := range r.tokens {
+		if row := r.fields(&r.tokens[i]); *row.id == id && *row.usedAt == nil {
+			now := time.Now()
+			*row.usedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeSingleUseTokenRepo[T]) InvalidateByUserId(userId uint, tx *sqlx.Tx) error {
+	for i := range r.tokens {
+		if row := r.fields(&r.tokens[i]); row.userId == userId && *row.usedAt == nil {
+			now := time.Now()
+			*row.usedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakePasswordResetRepo struct {
+	fakeSingleUseTokenRepo[entity.PasswordResetToken]
+}
+
+func newFakePasswordResetRepo() *fakePasswordResetRepo {
+	return &fakePasswordResetRepo{fakeSingleUseTokenRepo[entity.PasswordResetToken]{
+		fields: func(t *entity.PasswordResetToken) singleUseToken {
+			return singleUseToken{&t.ID, t.UserId, t.Token, t.ExpiredAt, &t.UsedAt}
+		},
+	}}
+}
+
+type fakeMagicLinkRepo struct {
+	fakeSingleUseTokenRepo[entity.MagicLinkToken]
+}
+
+func newFakeMagicLinkRepo() *fakeMagicLinkRepo {
+	return &fakeMagicLinkRepo{fakeSingleUseTokenRepo[entity.MagicLinkToken]{
+		fields: func(t *entity.MagicLinkToken) singleUseToken {
+			return singleUseToken{&t.ID, t.UserId, t.Token, t.ExpiredAt, &t.UsedAt}
+		},
+	}}
+}
+
+type fakeEmailVerificationRepo struct {
+	fakeSingleUseTokenRepo[entity.EmailVerificationToken]
+}
+
+func newFakeEmailVerificationRepo() *fakeEmailVerificationRepo {
+	return &fakeEmailVerificationRepo{fakeSingleUseTokenRepo[entity.EmailVerificationToken]{
+		fields: func(t *entity.EmailVerificationToken) singleUseToken {
+			return singleUseToken{&t.ID, t.UserId, t.Token, t.ExpiredAt, &t.UsedAt}
+		},
+	}}
+}
+
+func (r *fakeEmailVerificationRepo) GetLatestByUserId(userId uint, db *sqlx.DB) (result entity.EmailVerificationToken, err error) {
+	for _, t := range r.tokens {
+		if t.UserId == userId {
+			result = t
+		}
+	}
+	if result.ID == 0 {
+		return result, sql.ErrNoRows
+	}
+	return result, nil
+}
+
+type fakeMFAEmailCodeRepo struct {
+	fakeSingleUseTokenRepo[entity.MFAEmailCode]
+}
+
+func newFakeMFAEmailCodeRepo() *fakeMFAEmailCodeRepo {
+	return &fakeMFAEmailCodeRepo{fakeSingleUseTokenRepo[entity.MFAEmailCode]{
+		fields: func(c *entity.MFAEmailCode) singleUseToken {
+			return singleUseToken{&c.ID, c.UserId, c.Code, c.ExpiredAt, &c.UsedAt}
+		},
+	}}
+}
+
+func (r *fakeMFAEmailCodeRepo) GetValidByUserId(userId uint, db *sqlx.DB) (entity.MFAEmailCode, error) {
+	return r.getValid(func(row singleUseToken) bool { return row.userId == userId })
+}
+
+func (r *fakeMFAEmailCodeRepo) AddAttempt(id, maxAttempts uint, db *sqlx.DB) (bool, error) {
+	for i, c := range r.tokens {
+		if c.ID == id && c.Attempts < maxAttempts {
+			r.tokens[i].Attempts++
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/database"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/logger"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/mailer"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/repository"
+	"github.com/fazriegi/go-boilerplate/internal/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+type EmailVerificationUsecase interface {
+	SendVerification(user entity.User) error
+	Verify(props *entity.VerifyEmailRequest) (resp pkg.Response)
+	Resend(props *entity.ResendVerificationRequest) (resp pkg.Response)
+}
+
+type emailVerificationUsecase struct {
+	userRepo         repository.UserRepository
+	verificationRepo repository.EmailVerificationRepository
+	mailer           mailer.Mailer
+	log              *logrus.Logger
+}
+
+func NewEmailVerificationUsecase(userRepo repository.UserRepository, verificationRepo repository.EmailVerificationRepository, mailer mailer.Mailer) EmailVerificationUsecase {
+	log := logger.Get()
+
+	return &emailVerificationUsecase{
+		userRepo,
+		verificationRepo,
+		mailer,
+		log,
+	}
+}
+
+// SendVerification replaces any pending verification token of the user and emails a new one
+func (u *emailVerificationUsecase) SendVerification(user entity.User) error {
+	db := database.Get()
+
+	token, err := pkg.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := u.verificationRepo.InvalidateByUserId(user.ID, tx); err != nil {
+		return fmt.Errorf("verificationRepo.InvalidateByUserId: %w", err)
+	}
+
+	_, err = u.verificationRepo.Insert(entity.EmailVerificationToken{
+		UserId:    user.ID,
+		Token:     hashToken(token),
+		ExpiredAt: time.Now().Add(time.Duration(config.GetUint("EMAIL_VERIFICATION_EXP_HOUR")) * time.Hour),
+		CreatedAt: time.Now(),
+	}, tx)
+	if err != nil {
+		return fmt.Errorf("verificationRepo.Insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	return u.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease verify your email address by opening the link below:\n\n%s/verify-email?token=%s\n\nThe link expires in %d hours.\n",
+			user.Name,
+			config.GetString("APP_URL"),
+			token,
+			config.GetUint("EMAIL_VERIFICATION_EXP_HOUR"),
+		),
+	})
+}
+
+func (u *emailVerificationUsecase) Verify(props *entity.VerifyEmailRequest) (resp pkg.Response) {
+	db := database.Get()
+
+	verification, err := u.verificationRepo.GetValidByToken(hashToken(props.Token), db)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return pkg.NewResponse(http.StatusBadRequest, "invalid or expired verification token", nil, nil)
+	} else if err != nil {
+		u.log.Errorf("verificationRepo.GetValidByToken: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		u.log.Errorf("error start transaction: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+	defer tx.Rollback()
+
+	used, err := u.verificationRepo.MarkUsed(verification.ID, tx)
+	if err != nil {
+		u.log.Errorf("verificationRepo.MarkUsed: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if !used {
+		return pkg.NewResponse(http.StatusBadRequest, "invalid or expired verification token", nil, nil)
+	}
+
+	if err := u.userRepo.MarkEmailVerified(verification.UserId, tx); err != nil {
+		u.log.Errorf("userRepo.MarkEmailVerified: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.log.Errorf("failed commit tx: %s", err.Error())
+		return pkg.NewResponse(http.StatusInternalServerError, pkg.ErrServer.Error(), nil, nil)
+	}
+
+	return pkg.NewResponse(http.StatusOK, "email verified", nil, nil)
+}
+
+// Resend always answers the same way so it cannot be used to find registered emails,
+// requests within the cooldown of the previous email are silently dropped
+func (u *emailVerificationUsecase) Resend(props *entity.ResendVerificationRequest) (resp pkg.Response) {
+	db := database.Get()
+	resp = pkg.NewResponse(http.StatusOK, "if the email belongs to an unverified account, a verification email has been sent", nil, nil)
+
+	user, err := u.userRepo.GetByEmail(pkg.NormalizeIdentifier(props.Email), db)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			u.log.Errorf("userRepo.GetByEmail: %s", err.Error())
+		}
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return
+	}
+
+	latest, err := u.verificationRepo.GetLatestByUserId(user.ID, db)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Errorf("verificationRepo.GetLatestByUserId: %s", err.Error())
+		return
+	}
+
+	cooldown := time.Duration(config.GetUint("EMAIL_VERIFICATION_RESEND_COOLDOWN_SECOND")) * time.Second
+	if err == nil && time.Since(latest.CreatedAt) < cooldown {
+		return
+	}
+
+	if err := u.SendVerification(user); err != nil {
+		u.log.Errorf("SendVerification: %s", err.Error())
+	}
+
+	return
+}
@@ -0,0 +1,125 @@
+package usecase_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fazriegi/go-boilerplate/internal/entity"
+	"github.com/fazriegi/go-boilerplate/internal/infrastructure/config"
+	"github.com/fazriegi/go-boilerplate/internal/usecase"
+)
+
+func newVerificationUsecase(t *testing.T) (usecase.EmailVerificationUsecase, *authTestSuite) {
+	t.Helper()
+
+	s := newAuthTestSuite(t)
+	config.Set("EMAIL_VERIFICATION_EXP_HOUR", 24)
+	config.Set("EMAIL_VERIFICATION_RESEND_COOLDOWN_SECOND", 60)
+	config.Set("APP_URL", "https://app.example.com")
+
+	return usecase.NewEmailVerificationUsecase(s.userRepo, newFakeEmailVerificationRepo(), s.mailer), s
+}
+
+// extracts the token from the link of the latest verification email
+func verificationToken(t *testing.T, s *authTestSuite, to string) string {
+	t.Helper()
+
+	msg, ok := s.mailer.Last(to)
+	if !ok {
+		t.Fatalf("expected a verification email to %s", to)
+	}
+
+	_, rest, ok := strings.Cut(msg.Body, "/verify-email?token=")
+	if !ok {
+		t.Fatalf("verification link not found in %q", msg.Body)
+	}
+
+	return strings.Fields(rest)[0]
+}
+
+func TestVerifyEmail(t *testing.T) {
+	uc, s := newVerificationUsecase(t)
+
+	s.expectTx()
+	resp := uc.Resend(&entity.ResendVerificationRequest{Email: "alice@example.com"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected resend to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	token := verificationToken(t, s, "alice@example.com")
+
+	s.expectTx()
+	resp = uc.Verify(&entity.VerifyEmailRequest{Token: token})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected verify to succeed, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if s.userRepo.users[1].EmailVerifiedAt == nil {
+		t.Fatal("email should be verified")
+	}
+
+	resp = uc.Verify(&entity.VerifyEmailRequest{Token: token})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected used token to be rejected, got %d", resp.Code)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	uc, s := newVerificationUsecase(t)
+
+	s.expectTx()
+	uc.Resend(&entity.ResendVerificationRequest{Email: "alice@example.com"})
+	first := verificationToken(t, s, "alice@example.com")
+
+	// within the cooldown nothing is sent
+	resp := uc.Resend(&entity.ResendVerificationRequest{Email: "alice@example.com"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected throttled resend to look successful, got %d", resp.Code)
+	}
+	if got := len(s.mailer.Messages()); got != 1 {
+		t.Fatalf("expected 1 email, got %d", got)
+	}
+
+	// unknown emails get the same answer
+	resp = uc.Resend(&entity.ResendVerificationRequest{Email: "nobody@example.com"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected unknown email to look successful, got %d", resp.Code)
+	}
+
+	config.Set("EMAIL_VERIFICATION_RESEND_COOLDOWN_SECOND", 0)
+	s.expectTx()
+	uc.Resend(&entity.ResendVerificationRequest{Email: "alice@example.com"})
+	second := verificationToken(t, s, "alice@example.com")
+
+	if resp := uc.Verify(&entity.VerifyEmailRequest{Token: first}); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected superseded token to be rejected, got %d", resp.Code)
+	}
+
+	s.expectTx()
+	if resp := uc.Verify(&entity.VerifyEmailRequest{Token: second}); resp.Code != http.StatusOK {
+		t.Fatalf("expected latest token to verify, got %d: %s", resp.Code, resp.Message)
+	}
+
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	s := newAuthTestSuite(t)
+	config.Set("AUTH_REQUIRE_VERIFIED_EMAIL", true)
+	t.Cleanup(func() { config.Set("AUTH_REQUIRE_VERIFIED_EMAIL", false) })
+
+	resp := s.usecase.Login(&entity.LoginRequest{Username: "alice", Password: "secret"}, entity.ClientInfo{})
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected unverified login to be rejected, got %d", resp.Code)
+	}
+
+	s.userRepo.MarkEmailVerified(1, nil)
+	s.login(t)
+}